@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/config"
+	"github.com/dustedcodes/blog/internal/disqus"
+	"github.com/dustedcodes/blog/internal/newsletter"
+	"github.com/dustedcodes/blog/internal/redirects"
+	"github.com/dustedcodes/blog/internal/safehttp"
+	"github.com/dustedcodes/blog/internal/webmention"
+)
+
+const usage = `Usage:
+  blog                              Start the web server
+  blog import-disqus <export.xml>   Import comments from a Disqus XML export
+  blog send-webmentions             Send webmentions for links in blog posts
+  blog send-digest                  Email new blog posts to newsletter subscribers
+  blog validate                     Check blog posts against the local CDN mirror and published anchors
+  blog images                       Generate responsive variants of images in the local CDN mirror
+  blog syntax-css                   Generate the stylesheet for syntax highlighting`
+
+func runCommand(ctx context.Context, config *config.Config, args []string) error {
+	switch args[0] {
+	case "import-disqus":
+		if len(args) != 2 {
+			return fmt.Errorf("missing path to Disqus export\n%s", usage)
+		}
+		return importDisqus(ctx, config, args[1])
+	case "send-webmentions":
+		store, err := webmention.Open(config.WebmentionStorePath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = store.Close()
+		}()
+		blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
+		if err != nil {
+			return err
+		}
+		sender := webmention.NewSender(
+			store,
+			safehttp.NewClient(!config.IsProduction()),
+			config.UserAgent())
+		return sendWebmentions(ctx, config, sender, blogPosts)
+	case "send-digest":
+		subscriptions, err := openNewsletter(config)
+		if err != nil {
+			return err
+		}
+		if subscriptions == nil {
+			return errors.New("the newsletter is disabled, because SMTP_ADDRESS is not set")
+		}
+		blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
+		if err != nil {
+			return err
+		}
+		return subscriptions.SendDigest(ctx, slogctx.GetLogger(ctx), blogPosts)
+	case "validate":
+		blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
+		if err != nil {
+			return err
+		}
+		imageWarnings, err := blog.DetectImages(ctx, blogPosts, config.CDN, config.CDNMirrorPath)
+		if err != nil {
+			return err
+		}
+		anchorWarnings, err := blog.CheckAnchors(ctx, blogPosts, blog.DefaultAnchorsPath)
+		if err != nil {
+			return err
+		}
+		for _, warning := range imageWarnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+		for _, warning := range anchorWarnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+		if n := len(imageWarnings) + len(anchorWarnings); n > 0 {
+			return fmt.Errorf("found %d validation warnings", n)
+		}
+		return nil
+	case "images":
+		blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
+		if err != nil {
+			return err
+		}
+		manifest, err := blog.LoadImageManifest(blog.DefaultImageManifestPath)
+		if err != nil {
+			return err
+		}
+		err = blog.GenerateImageVariants(ctx, blogPosts, config.CDN, config.CDNMirrorPath, config.ImageWidths, manifest)
+		if err != nil {
+			return err
+		}
+		return manifest.Save(blog.DefaultImageManifestPath)
+	case "syntax-css":
+		css, err := blog.SyntaxCSS()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(blog.SyntaxStylesheetPath, []byte(css), 0o644)
+	default:
+		return fmt.Errorf("unknown command '%s'\n%s", args[0], usage)
+	}
+}
+
+func importDisqus(ctx context.Context, config *config.Config, exportPath string) error {
+	logger := slogctx.GetLogger(ctx)
+
+	file, err := os.Open(exportPath)
+	if err != nil {
+		return fmt.Errorf("error opening Disqus export: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	export, err := disqus.Parse(file)
+	if err != nil {
+		return err
+	}
+
+	imported, err := export.Comments()
+	if err != nil {
+		return err
+	}
+
+	blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
+	if err != nil {
+		return err
+	}
+	redirectTable, err := redirects.Load(redirects.DefaultRedirectsPath)
+	if err != nil {
+		return err
+	}
+	resolve := newPostResolver(blogPosts, redirectTable)
+
+	valid := []*comments.Comment{}
+	for _, c := range imported {
+		blogPostID, ok := resolve(c.PostID)
+		if !ok {
+			logger.Warn("Skipping comment because its blog post doesn't exist.",
+				"blogPostID", c.PostID,
+				"commentID", c.ID)
+			continue
+		}
+		c.PostID = blogPostID
+		valid = append(valid, c)
+	}
+
+	store, err := comments.Open(config.CommentsStorePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+
+	err = store.AddAll(valid)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Finished importing Disqus comments.",
+		"imported", len(valid),
+		"skipped", len(imported)-len(valid))
+	return nil
+}
+
+// newPostResolver returns a function which maps old slugs of renamed
+// blog posts onto their current ID, either via the aliases of a blog
+// post or the redirects table.
+func newPostResolver(blogPosts []*blog.Post, redirectTable *redirects.Table) func(string) (string, bool) {
+	knownPosts := map[string]string{}
+	for _, blogPost := range blogPosts {
+		knownPosts[blogPost.ID] = blogPost.ID
+	}
+	for _, blogPost := range blogPosts {
+		for _, alias := range blogPost.Aliases {
+			if _, ok := knownPosts[alias]; !ok {
+				knownPosts[alias] = blogPost.ID
+			}
+		}
+	}
+
+	return func(blogPostID string) (string, bool) {
+		// Redirects can point to other redirects, but not endlessly:
+		for range 10 {
+			if id, ok := knownPosts[blogPostID]; ok {
+				return id, true
+			}
+			target, _, ok := redirectTable.Match("/" + blogPostID)
+			if !ok || !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+				return "", false
+			}
+			target, _, _ = strings.Cut(target, "?")
+			target, _, _ = strings.Cut(target, "#")
+			blogPostID = strings.Trim(target, "/")
+		}
+		return "", false
+	}
+}
+
+// sendWebmentions notifies all sites which are linked from a published
+// blog post and haven't been notified before.
+func sendWebmentions(
+	ctx context.Context,
+	config *config.Config,
+	sender *webmention.Sender,
+	blogPosts []*blog.Post,
+) error {
+	logger := slogctx.GetLogger(ctx)
+	urls := &model.URLs{BaseURL: config.BaseURL}
+
+	for _, blogPost := range blogPosts {
+		if blogPost.Retired {
+			continue
+		}
+		err := sender.Send(ctx, logger, urls.BlogPostURL(blogPost.ID), string(blogPost.HTML))
+		if err != nil {
+			return fmt.Errorf("error sending webmentions for blog post '%s': %w", blogPost.ID, err)
+		}
+	}
+
+	logger.Info("Finished sending webmentions.")
+	return nil
+}
+
+// openNewsletter returns nil when the newsletter is disabled.
+func openNewsletter(config *config.Config) (*newsletter.Newsletter, error) {
+	if !config.NewsletterEnabled() {
+		return nil, nil
+	}
+	store, err := newsletter.Open(config.NewsletterStorePath)
+	if err != nil {
+		return nil, err
+	}
+	return newsletter.New(
+		store,
+		newsletter.NewSMTPMailer(config.SMTPAddress, config.SMTPUsername, config.SMTPPassword),
+		newsletter.DefaultTemplatePath,
+		config.NewsletterFrom,
+		config.BaseURL)
+}
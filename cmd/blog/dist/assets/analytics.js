@@ -0,0 +1,10 @@
+// Reports a page view without cookies or any other client side state.
+(function () {
+    if (navigator.doNotTrack === "1" || navigator.globalPrivacyControl || !navigator.sendBeacon) {
+        return;
+    }
+    navigator.sendBeacon("/beacon", JSON.stringify({
+        path: window.location.pathname,
+        referrer: document.referrer
+    }));
+})();
@@ -0,0 +1,30 @@
+// Adds a copy button to code blocks which have the data-copy attribute.
+(function () {
+    if (!navigator.clipboard) {
+        return;
+    }
+    function addButtons() {
+        document.querySelectorAll("[data-copy]").forEach(function (block) {
+            var button = document.createElement("button");
+            button.type = "button";
+            button.className = "code-block-copy";
+            button.textContent = "Copy";
+            button.addEventListener("click", function () {
+                // With line numbers the code is in the last pre:
+                var pres = block.querySelectorAll("pre");
+                navigator.clipboard.writeText(pres[pres.length - 1].textContent).then(function () {
+                    button.textContent = "Copied";
+                    setTimeout(function () {
+                        button.textContent = "Copy";
+                    }, 2000);
+                });
+            });
+            block.appendChild(button);
+        });
+    }
+    if (document.readyState === "loading") {
+        document.addEventListener("DOMContentLoaded", addButtons);
+    } else {
+        addButtons();
+    }
+})();
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"syscall"
 	"time"
 
 	"github.com/dusted-go/config/dotenv"
@@ -22,15 +26,22 @@ import (
 
 	"github.com/dustedcodes/blog/cmd/blog/model"
 	"github.com/dustedcodes/blog/cmd/blog/web"
+	"github.com/dustedcodes/blog/internal/activitypub"
+	"github.com/dustedcodes/blog/internal/analytics"
 	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
 	"github.com/dustedcodes/blog/internal/config"
+	"github.com/dustedcodes/blog/internal/ogimage"
+	"github.com/dustedcodes/blog/internal/redirects"
+	"github.com/dustedcodes/blog/internal/safehttp"
+	"github.com/dustedcodes/blog/internal/webmention"
 )
 
 func main() {
 	// -----------------------------
 	// Load config
 	// -----------------------------
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	err := dotenv.Load(".env", true)
@@ -57,6 +68,19 @@ func main() {
 	logger := slog.New(logHandler)
 	slog.SetDefault(logger)
 
+	// ----------------------------------------
+	// Run a command instead of the web server:
+	// ----------------------------------------
+	if len(os.Args) > 1 {
+		err = runCommand(ctx, config, os.Args[1:])
+		cancel()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// ----------------------------------------
 	// Bootstrap:
 	// ----------------------------------------
@@ -73,7 +97,7 @@ func main() {
 		CSSPath: assetMiddleware.CSS.VirtualFileName,
 		JSPath:  assetMiddleware.JS.VirtualFileName,
 	}
-	blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath)
+	blogPosts, err := blog.ReadPosts(ctx, blog.DefaultBlogPostPath, config.BlogOptions())
 	if err != nil {
 		panic(err)
 	}
@@ -81,10 +105,160 @@ func main() {
 	sort.Slice(blogPosts, func(i, j int) bool {
 		return blogPosts[i].PublishDate.After(blogPosts[j].PublishDate)
 	})
+	_, err = blog.DetectImages(ctx, blogPosts, config.CDN, config.CDNMirrorPath)
+	if err != nil {
+		panic(err)
+	}
+	imageManifest, err := blog.LoadImageManifest(blog.DefaultImageManifestPath)
+	if err != nil {
+		panic(err)
+	}
+	for _, blogPost := range blogPosts {
+		blog.UseResponsiveImages(blogPost, imageManifest, config.CDN)
+	}
+	// Blog posts without an image of their own get a generated Open Graph image
+	ogRenderer, err := ogimage.NewRenderer("Dusted Codes")
+	if err != nil {
+		panic(err)
+	}
+	ogImages := ogimage.NewCache(ogRenderer)
+	urls := &model.URLs{BaseURL: config.BaseURL, CDN: config.CDN}
+	for _, blogPost := range blogPosts {
+		if blogPost.Retired {
+			continue
+		}
+		err = ogImages.Assign(blogPost, urls.BlogPostImageURL(blogPost.ID))
+		if err != nil {
+			panic(err)
+		}
+	}
+	redirectTable, err := redirects.Load(redirects.DefaultRedirectsPath)
+	if err != nil {
+		panic(err)
+	}
+	// Redirects of published blog posts would make them unreachable,
+	// retired blog posts can be redirected instead of responding with 410
+	postPaths := []string{}
+	for _, blogPost := range blogPosts {
+		if !blogPost.Retired {
+			postPaths = append(postPaths, "/"+blogPost.ID)
+		}
+	}
+	err = redirectTable.CheckShadowing(postPaths)
+	if err != nil {
+		panic(err)
+	}
+	// Redirect old slugs which blog posts claimed via the Aliases metadata key
+	err = blog.CheckAliases(blogPosts)
+	if err != nil {
+		panic(err)
+	}
+	for _, blogPost := range blogPosts {
+		for _, alias := range blogPost.Aliases {
+			err = redirectTable.Add(redirects.Rule{
+				From: "/" + alias,
+				To:   "/" + blogPost.ID,
+			})
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+	commentStore, err := comments.Open(config.CommentsStorePath)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = commentStore.Close()
+	}()
+	mentionStore, err := webmention.Open(config.WebmentionStorePath)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = mentionStore.Close()
+	}()
+	mentionClient := safehttp.NewClient(!config.IsProduction())
+	mentionReceiver := webmention.NewReceiver(mentionStore, mentionClient, config.UserAgent())
+	go mentionReceiver.Run(ctx, logger)
+	if config.SendWebmentions {
+		mentionSender := webmention.NewSender(mentionStore, mentionClient, config.UserAgent())
+		go func() {
+			err := sendWebmentions(ctx, config, mentionSender, blogPosts)
+			if err != nil {
+				logger.Error("Failed to send webmentions.", "error", err)
+			}
+		}()
+	}
+	activityPubStore, err := activitypub.Open(config.ActivityPubStorePath)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = activityPubStore.Close()
+	}()
+	activityPubKey, err := activitypub.LoadOrCreateKey(config.ActivityPubKeyPath)
+	if err != nil {
+		panic(err)
+	}
+	activityPub, err := activitypub.NewService(
+		activitypub.Settings{
+			BaseURL:  config.BaseURL,
+			Host:     config.PublicHost,
+			Username: config.ActivityPubUsername,
+			Name:     "Dusted Codes",
+			Summary:  "Programming, Coffee and Indie Hacking",
+			IconURL:  urls.Logo(),
+		},
+		activityPubKey,
+		activityPubStore,
+		// Actors are fetched while handling unauthenticated requests,
+		// therefore private networks are off limits even during development:
+		safehttp.NewClient(false),
+		config.UserAgent())
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		publishedPosts := []*blog.Post{}
+		for _, blogPost := range blogPosts {
+			if !blogPost.Retired {
+				publishedPosts = append(publishedPosts, blogPost)
+			}
+		}
+		err := activityPub.DeliverNewPosts(ctx, logger, publishedPosts)
+		if err != nil {
+			logger.Error("Failed to deliver blog posts to ActivityPub followers.", "error", err)
+		}
+	}()
+	subscriptions, err := openNewsletter(config)
+	if err != nil {
+		panic(err)
+	}
+	analyticsStore, err := analytics.Open(config.AnalyticsStorePath)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = analyticsStore.Close()
+	}()
+	tracker, err := analytics.NewTracker(analyticsStore)
+	if err != nil {
+		panic(err)
+	}
+	go tracker.Run(ctx, logger, time.Minute)
 	webHandler := web.NewHandler(
 		config,
 		siteAssets,
-		blogPosts)
+		blogPosts,
+		redirectTable,
+		commentStore,
+		mentionStore,
+		mentionReceiver,
+		activityPub,
+		subscriptions,
+		tracker,
+		ogImages)
 
 	// ----------------------------------------
 	// Web Server:
@@ -127,8 +301,23 @@ func main() {
 		Handler:        webApp,
 		MaxHeaderBytes: int(config.MaxRequestSize),
 	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down server gracefully.", "error", err)
+		}
+	}()
 	err = httpServer.ListenAndServe()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
+
+	// Persist the latest statistics before exiting:
+	logger.Info("Shutting down server...")
+	err = tracker.Flush()
+	if err != nil {
+		logger.Error("Failed to persist statistics.", "error", err)
+	}
 }
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Structured data (JSON-LD) which describes pages to search engines,
+// see https://schema.org for the vocabulary.
+
+const (
+	schemaContext = "https://schema.org"
+	siteName      = "Dusted Codes"
+	authorName    = "Dustin Moris Gorski"
+)
+
+type Person struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type ImageObject struct {
+	Type   string `json:"@type"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitzero"`
+	Height int    `json:"height,omitzero"`
+}
+
+type Organization struct {
+	Type string       `json:"@type"`
+	Name string       `json:"name"`
+	URL  string       `json:"url"`
+	Logo *ImageObject `json:"logo,omitempty"`
+}
+
+type WebPage struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id"`
+}
+
+type BlogPosting struct {
+	Context          string       `json:"@context"`
+	Type             string       `json:"@type"`
+	Headline         string       `json:"headline"`
+	Description      string       `json:"description,omitempty"`
+	URL              string       `json:"url"`
+	MainEntityOfPage WebPage      `json:"mainEntityOfPage"`
+	Image            *ImageObject `json:"image,omitempty"`
+	DatePublished    string       `json:"datePublished"`
+	DateModified     string       `json:"dateModified"`
+	Keywords         string       `json:"keywords,omitempty"`
+	Author           Person       `json:"author"`
+	Publisher        Organization `json:"publisher"`
+}
+
+type EntryPoint struct {
+	Type        string `json:"@type"`
+	URLTemplate string `json:"urlTemplate"`
+}
+
+type SearchAction struct {
+	Type       string     `json:"@type"`
+	Target     EntryPoint `json:"target"`
+	QueryInput string     `json:"query-input"`
+}
+
+type WebSite struct {
+	Context         string       `json:"@context"`
+	Type            string       `json:"@type"`
+	Name            string       `json:"name"`
+	Description     string       `json:"description"`
+	URL             string       `json:"url"`
+	PotentialAction SearchAction `json:"potentialAction"`
+}
+
+type ListItem struct {
+	Type     string `json:"@type"`
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	Item     string `json:"item"`
+}
+
+type BreadcrumbList struct {
+	Context         string     `json:"@context"`
+	Type            string     `json:"@type"`
+	ItemListElement []ListItem `json:"itemListElement"`
+}
+
+// Breadcrumb is a single step of the navigation path to a page.
+type Breadcrumb struct {
+	Name string
+	URL  string
+}
+
+// WithStructuredData adds JSON-LD objects, which get rendered into the head of a page.
+func (b Base) WithStructuredData(data ...any) Base {
+	b.StructuredData = append(b.StructuredData[:len(b.StructuredData):len(b.StructuredData)], data...)
+	return b
+}
+
+func (b Base) author() Person {
+	return Person{
+		Type: "Person",
+		Name: authorName,
+		URL:  b.URLs.About(),
+	}
+}
+
+func (b Base) publisher() Organization {
+	return Organization{
+		Type: "Organization",
+		Name: siteName,
+		URL:  b.URLs.BaseURL,
+		Logo: &ImageObject{
+			Type: "ImageObject",
+			URL:  b.URLs.Logo(),
+		},
+	}
+}
+
+// WebSite describes the website including its search.
+func (b Base) WebSite() WebSite {
+	return WebSite{
+		Context:     schemaContext,
+		Type:        "WebSite",
+		Name:        siteName,
+		Description: b.SubTitle,
+		URL:         b.URLs.BaseURL,
+		PotentialAction: SearchAction{
+			Type: "SearchAction",
+			Target: EntryPoint{
+				Type:        "EntryPoint",
+				URLTemplate: b.URLs.Search() + "?q={search_term_string}",
+			},
+			QueryInput: "required name=search_term_string",
+		},
+	}
+}
+
+// Breadcrumbs describes the navigation path from the home page to the current page.
+func (b Base) Breadcrumbs(crumbs ...Breadcrumb) BreadcrumbList {
+	list := BreadcrumbList{
+		Context: schemaContext,
+		Type:    "BreadcrumbList",
+		ItemListElement: []ListItem{{
+			Type:     "ListItem",
+			Position: 1,
+			Name:     "Home",
+			Item:     b.URLs.BaseURL + "/",
+		}},
+	}
+	for i, crumb := range crumbs {
+		list.ItemListElement = append(list.ItemListElement, ListItem{
+			Type:     "ListItem",
+			Position: i + 2,
+			Name:     crumb.Name,
+			Item:     crumb.URL,
+		})
+	}
+	return list
+}
+
+func (b Base) blogPosting(permalink string, publishDate time.Time, lastModified time.Time, tags []string) BlogPosting {
+	posting := BlogPosting{
+		Context:     schemaContext,
+		Type:        "BlogPosting",
+		Headline:    b.Title,
+		Description: b.Description,
+		URL:         permalink,
+		MainEntityOfPage: WebPage{
+			Type: "WebPage",
+			ID:   permalink,
+		},
+		DatePublished: publishDate.UTC().Format(time.RFC3339),
+		DateModified:  lastModified.UTC().Format(time.RFC3339),
+		Keywords:      strings.Join(tags, ", "),
+		Author:        b.author(),
+		Publisher:     b.publisher(),
+	}
+	if len(b.OpenGraphImage.URL) > 0 {
+		posting.Image = &ImageObject{
+			Type:   "ImageObject",
+			URL:    b.OpenGraphImage.URL,
+			Width:  b.OpenGraphImage.Width,
+			Height: b.OpenGraphImage.Height,
+		}
+	}
+	return posting
+}
@@ -7,7 +7,10 @@ import (
 	"sort"
 	"time"
 
+	"github.com/dustedcodes/blog/internal/analytics"
 	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/webmention"
 )
 
 type Assets struct {
@@ -16,13 +19,29 @@ type Assets struct {
 }
 
 type Base struct {
-	Title           string
-	SubTitle        string
-	Year            int
-	Assets          *Assets
-	URLs            *URLs
-	DisqusShortname string
-	OpenGraphImage  blog.OpenGraphImage
+	Title          string
+	SubTitle       string
+	Year           int
+	Assets         *Assets
+	URLs           *URLs
+	OpenGraphImage blog.OpenGraphImage
+
+	// NewsletterEnabled shows the subscribe form
+	// when email delivery has been configured.
+	NewsletterEnabled bool
+
+	// PopularPosts is shown on pages with a "most read" section.
+	PopularPosts *PopularPosts
+
+	// StructuredData holds JSON-LD objects which describe the page.
+	StructuredData []any
+
+	// Description, Canonical and Robots override
+	// the defaults of the SEO meta tags.
+	Description string
+	Canonical   string
+	Robots      string
+	article     *articleMeta
 }
 
 func (b Base) WithTitle(title string) Base {
@@ -40,37 +59,134 @@ func (b Base) WithOpenGraphImage(img blog.OpenGraphImage) Base {
 	return b
 }
 
+// WithPopularPosts ranks blog posts by their request counts
+// and keeps the given number of the most read posts.
+func (b Base) WithPopularPosts(
+	blogPosts []*blog.Post,
+	requestCounts map[string]int,
+	windows []PopularWindow,
+	limit int,
+) Base {
+	ranked := []*blog.Post{}
+	for _, post := range blogPosts {
+		if requestCounts[post.ID] > 0 {
+			ranked = append(ranked, post)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ci, cj := requestCounts[ranked[i].ID], requestCounts[ranked[j].ID]
+		if ci == cj {
+			return ranked[i].PublishDate.After(ranked[j].PublishDate)
+		}
+		return ci > cj
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	popular := &PopularPosts{
+		Windows:   windows,
+		BlogPosts: []BlogPostLink{},
+	}
+	for _, post := range ranked {
+		popular.BlogPosts = append(popular.BlogPosts, BlogPostLink{
+			ID:          post.ID,
+			Title:       post.Title,
+			Permalink:   b.URLs.BlogPostURL(post.ID),
+			PublishDate: post.PublishDate,
+		})
+	}
+	b.PopularPosts = popular
+	return b
+}
+
 type Empty struct {
 	Base Base
 }
 
+type Gone struct {
+	Base         Base
+	ArticleTitle string
+	Replacement  string
+}
+
 type UserMessage struct {
 	Base     Base
 	Messages []template.HTML
 }
 
+type Unsubscribe struct {
+	Base  Base
+	Token string
+}
+
 type Tag struct {
 	Value string
 	URL   string
 }
 
 type BlogPostLink struct {
-	Title       string
-	Permalink   string
-	PublishDate time.Time
-	Tags        []Tag
+	ID           string
+	Title        string
+	Permalink    string
+	PublishDate  time.Time
+	Tags         []Tag
+	CommentCount int
+	ViewCount    int
 }
 
 func (b BlogPostLink) PublishedOn() string {
 	return b.PublishDate.Format("02 Jan 2006")
 }
 
+// PopularWindow is a time window which
+// the most read blog posts can be ranked by.
+type PopularWindow struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+type PopularPosts struct {
+	Windows   []PopularWindow
+	BlogPosts []BlogPostLink
+}
+
 type Blog struct {
 	Base        Base
 	Catalog     map[int][]BlogPostLink
 	SortedYears []int
 }
 
+type Comment struct {
+	ID        string
+	Author    string
+	Website   string
+	HTML      template.HTML
+	CreatedAt time.Time
+	Replies   []Comment
+}
+
+func (c Comment) PostedOn() string {
+	return c.CreatedAt.Format("02 Jan 2006, 15:04")
+}
+
+type CommentForm struct {
+	ActionURL string
+	ParentID  string
+	Author    string
+	Website   string
+	Content   string
+	Error     string
+	Received  bool
+}
+
+type Mention struct {
+	Source string
+	Title  string
+	Host   string
+}
+
 type BlogPost struct {
 	Base             Base
 	ID               string
@@ -80,6 +196,22 @@ type BlogPost struct {
 	EncodedTitle     string
 	Permalink        string
 	EncodedPermalink string
+	CommentCount     int
+	ViewCount        int
+	Comments         []Comment
+	CommentForm      CommentForm
+	Mentions         []Mention
+}
+
+type PendingComment struct {
+	PostID    string
+	Permalink string
+	Comment   Comment
+}
+
+type Moderation struct {
+	Base     Base
+	Comments []PendingComment
 }
 
 type Tagged struct {
@@ -87,6 +219,27 @@ type Tagged struct {
 	BlogPosts []BlogPostLink
 }
 
+type Search struct {
+	Base      Base
+	Query     string
+	BlogPosts []BlogPostLink
+}
+
+type StatsEntry struct {
+	Label string
+	URL   string
+	Count int
+}
+
+type Stats struct {
+	Base         Base
+	Days         []*analytics.Day
+	PageViews    int
+	Visitors     int
+	TopPosts     []StatsEntry
+	TopReferrers []StatsEntry
+}
+
 func (b BlogPost) PublishedOn() string {
 	return b.PublishDate.Format("02 Jan 2006")
 }
@@ -99,6 +252,14 @@ func (b Base) Empty() Empty {
 	return Empty{Base: b}
 }
 
+func (b Base) Gone(articleTitle string, replacement string) Gone {
+	return Gone{
+		Base:         b,
+		ArticleTitle: articleTitle,
+		Replacement:  replacement,
+	}
+}
+
 func (b Base) UserMessage(msg template.HTML) UserMessage {
 	return UserMessage{
 		Base:     b,
@@ -113,7 +274,18 @@ func (b Base) UserMessages(msgs ...template.HTML) UserMessage {
 	}
 }
 
-func (b Base) Blog(blogPosts []*blog.Post) Blog {
+func (b Base) Unsubscribe(token string) Unsubscribe {
+	return Unsubscribe{
+		Base:  b,
+		Token: token,
+	}
+}
+
+func (b Base) Blog(
+	blogPosts []*blog.Post,
+	commentCounts map[string]int,
+	viewCounts map[string]int,
+) Blog {
 	catalog := map[int][]BlogPostLink{}
 	years := []int{}
 
@@ -132,10 +304,12 @@ func (b Base) Blog(blogPosts []*blog.Post) Blog {
 		catalog[year] = append(
 			catalog[year],
 			BlogPostLink{
-				Title:       post.Title,
-				Permalink:   b.URLs.BlogPostURL(post.ID),
-				PublishDate: post.PublishDate,
-				Tags:        tags,
+				Title:        post.Title,
+				Permalink:    b.URLs.BlogPostURL(post.ID),
+				PublishDate:  post.PublishDate,
+				Tags:         tags,
+				CommentCount: commentCounts[post.ID],
+				ViewCount:    viewCounts[post.ID],
 			})
 	}
 
@@ -149,6 +323,9 @@ func (b Base) Blog(blogPosts []*blog.Post) Blog {
 		})
 	}
 
+	b = b.WithStructuredData(b.Breadcrumbs(
+		Breadcrumb{Name: "Blog", URL: b.URLs.Blog()}))
+
 	return Blog{
 		Base:        b,
 		Catalog:     catalog,
@@ -156,7 +333,12 @@ func (b Base) Blog(blogPosts []*blog.Post) Blog {
 	}
 }
 
-func (b Base) Tagged(blogPosts []*blog.Post) Tagged {
+// blogPostLinks lists blog posts in reverse chronological order.
+func (b Base) blogPostLinks(
+	blogPosts []*blog.Post,
+	commentCounts map[string]int,
+	viewCounts map[string]int,
+) []BlogPostLink {
 	blogPostLinks := []BlogPostLink{}
 
 	for _, post := range blogPosts {
@@ -168,10 +350,12 @@ func (b Base) Tagged(blogPosts []*blog.Post) Tagged {
 			})
 		}
 		blogPostLinks = append(blogPostLinks, BlogPostLink{
-			Title:       post.Title,
-			Permalink:   b.URLs.BlogPostURL(post.ID),
-			PublishDate: post.PublishDate,
-			Tags:        tags,
+			Title:        post.Title,
+			Permalink:    b.URLs.BlogPostURL(post.ID),
+			PublishDate:  post.PublishDate,
+			Tags:         tags,
+			CommentCount: commentCounts[post.ID],
+			ViewCount:    viewCounts[post.ID],
 		})
 	}
 
@@ -179,9 +363,35 @@ func (b Base) Tagged(blogPosts []*blog.Post) Tagged {
 		return blogPostLinks[i].PublishDate.After(blogPostLinks[j].PublishDate)
 	})
 
+	return blogPostLinks
+}
+
+func (b Base) Tagged(
+	tagName string,
+	blogPosts []*blog.Post,
+	commentCounts map[string]int,
+	viewCounts map[string]int,
+) Tagged {
+	b = b.WithStructuredData(b.Breadcrumbs(
+		Breadcrumb{Name: "Blog", URL: b.URLs.Blog()},
+		Breadcrumb{Name: b.Title, URL: b.URLs.TagURL(tagName)}))
+
 	return Tagged{
 		Base:      b,
-		BlogPosts: blogPostLinks,
+		BlogPosts: b.blogPostLinks(blogPosts, commentCounts, viewCounts),
+	}
+}
+
+func (b Base) Search(
+	query string,
+	blogPosts []*blog.Post,
+	commentCounts map[string]int,
+	viewCounts map[string]int,
+) Search {
+	return Search{
+		Base:      b,
+		Query:     query,
+		BlogPosts: b.blogPostLinks(blogPosts, commentCounts, viewCounts),
 	}
 }
 
@@ -189,6 +399,7 @@ func (b Base) BlogPost(
 	blogPostID string,
 	content template.HTML,
 	publishDate time.Time,
+	lastModified time.Time,
 	tags []string,
 ) BlogPost {
 	permalink := b.URLs.BlogPostURL(blogPostID)
@@ -199,6 +410,20 @@ func (b Base) BlogPost(
 			URL:   b.URLs.TagURL(tag),
 		})
 	}
+	// Blog posts may override the canonical URL and description:
+	if len(b.Canonical) == 0 {
+		b.Canonical = permalink
+	}
+	if len(b.Description) == 0 {
+		b.Description = excerpt(content)
+	}
+	b.article = &articleMeta{publishDate: publishDate, lastModified: lastModified, tags: tags}
+	b = b.WithStructuredData(
+		b.blogPosting(permalink, publishDate, lastModified, tags),
+		b.Breadcrumbs(
+			Breadcrumb{Name: "Blog", URL: b.URLs.Blog()},
+			Breadcrumb{Name: b.Title, URL: permalink}))
+
 	return BlogPost{
 		Base:             b,
 		ID:               blogPostID,
@@ -208,5 +433,152 @@ func (b Base) BlogPost(
 		EncodedTitle:     url.QueryEscape(b.Title),
 		Permalink:        permalink,
 		EncodedPermalink: url.QueryEscape(permalink),
+		CommentForm: CommentForm{
+			ActionURL: b.URLs.BlogPostCommentsFormURL(blogPostID),
+		},
+	}
+}
+
+func newComment(c *comments.Comment) Comment {
+	return Comment{
+		ID:        c.ID,
+		Author:    c.Author,
+		Website:   c.Website,
+		HTML:      c.HTML,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// threadComments nests replies underneath their parent comment.
+// Replies to a comment which isn't visible are shown at the top level.
+func threadComments(list []*comments.Comment) []Comment {
+	ids := map[string]bool{}
+	children := map[string][]*comments.Comment{}
+	for _, c := range list {
+		ids[c.ID] = true
+	}
+	roots := []*comments.Comment{}
+	for _, c := range list {
+		if len(c.ParentID) > 0 && ids[c.ParentID] {
+			children[c.ParentID] = append(children[c.ParentID], c)
+			continue
+		}
+		roots = append(roots, c)
+	}
+
+	var build func(list []*comments.Comment) []Comment
+	build = func(list []*comments.Comment) []Comment {
+		result := []Comment{}
+		for _, c := range list {
+			comment := newComment(c)
+			comment.Replies = build(children[c.ID])
+			result = append(result, comment)
+		}
+		return result
+	}
+
+	return build(roots)
+}
+
+func (b BlogPost) WithComments(list []*comments.Comment) BlogPost {
+	b.CommentCount = len(list)
+	b.Comments = threadComments(list)
+	return b
+}
+
+func (b BlogPost) WithCommentForm(form CommentForm) BlogPost {
+	form.ActionURL = b.CommentForm.ActionURL
+	b.CommentForm = form
+	return b
+}
+
+func (b BlogPost) WithViewCount(count int) BlogPost {
+	b.ViewCount = count
+	return b
+}
+
+func (b BlogPost) WithMentions(list []*webmention.Mention) BlogPost {
+	mentions := []Mention{}
+	for _, m := range list {
+		host := m.Source
+		if u, err := url.Parse(m.Source); err == nil {
+			host = u.Host
+		}
+		mentions = append(mentions, Mention{
+			Source: m.Source,
+			Title:  m.Title,
+			Host:   host,
+		})
+	}
+	b.Mentions = mentions
+	return b
+}
+
+func (b Base) Moderation(list []*comments.Comment) Moderation {
+	pending := []PendingComment{}
+	for _, c := range list {
+		pending = append(pending, PendingComment{
+			PostID:    c.PostID,
+			Permalink: b.URLs.BlogPostURL(c.PostID),
+			Comment:   newComment(c),
+		})
+	}
+	return Moderation{
+		Base:     b,
+		Comments: pending,
+	}
+}
+
+func topEntries(counts map[string]int, limit int, entry func(key string) StatsEntry) []StatsEntry {
+	entries := []StatsEntry{}
+	for key, count := range counts {
+		e := entry(key)
+		e.Count = count
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count == entries[j].Count {
+			return entries[i].Label < entries[j].Label
+		}
+		return entries[i].Count > entries[j].Count
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// Stats summarises the given days. Blog post titles are looked up
+// by ID and fall back to the ID for posts which no longer exist.
+func (b Base) Stats(days []*analytics.Day, titles map[string]string) Stats {
+	stats := Stats{
+		Base: b,
+		Days: days,
 	}
+	posts := map[string]int{}
+	referrers := map[string]int{}
+	for _, day := range days {
+		stats.PageViews += day.PageViews
+		stats.Visitors += day.Visitors
+		for postID, count := range day.Posts {
+			posts[postID] += count
+		}
+		for host, count := range day.Referrers {
+			referrers[host] += count
+		}
+	}
+	stats.TopPosts = topEntries(posts, 20, func(postID string) StatsEntry {
+		title, ok := titles[postID]
+		if !ok {
+			title = postID
+		}
+		return StatsEntry{Label: title, URL: b.URLs.BlogPostURL(postID)}
+	})
+	stats.TopReferrers = topEntries(referrers, 20, func(host string) StatsEntry {
+		if host == analytics.OtherReferrers {
+			return StatsEntry{Label: "Other"}
+		}
+		return StatsEntry{Label: host, URL: "https://" + host}
+	})
+	return stats
 }
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+const (
+	twitterHandle        = "@dustedcodes"
+	maxDescriptionLength = 160
+)
+
+// Meta describes a page for search engines and social networks.
+// It gets rendered into Open Graph, Twitter card, canonical link
+// and robots tags by the master template.
+type Meta struct {
+	Title         string
+	Description   string
+	Canonical     string
+	Robots        string
+	Type          string
+	SiteName      string
+	Locale        string
+	Image         blog.OpenGraphImage
+	ImageAlt      string
+	TwitterCard   string
+	TwitterSite   string
+	PublishedTime string
+	ModifiedTime  string
+	Author        string
+	Tags          []string
+}
+
+// Indexed reports whether search engines may index the page,
+// only indexed pages declare a canonical URL.
+func (m Meta) Indexed() bool {
+	return !strings.Contains(strings.ToLower(m.Robots), "noindex")
+}
+
+type articleMeta struct {
+	publishDate  time.Time
+	lastModified time.Time
+	tags         []string
+}
+
+// WithDescription overrides the default description of a page.
+func (b Base) WithDescription(description string) Base {
+	if len(description) > 0 {
+		b.Description = description
+	}
+	return b
+}
+
+// WithCanonical overrides the canonical URL of a page.
+func (b Base) WithCanonical(canonical string) Base {
+	if len(canonical) > 0 {
+		b.Canonical = canonical
+	}
+	return b
+}
+
+// WithRobots sets the robots directives of a page, e.g. "noindex, follow".
+func (b Base) WithRobots(robots string) Base {
+	if len(robots) > 0 {
+		b.Robots = robots
+	}
+	return b
+}
+
+// NoIndex prevents search engines from indexing a page.
+func (b Base) NoIndex() Base {
+	return b.WithRobots("noindex")
+}
+
+// canonicalURL returns the absolute URL of the current page without
+// query parameters, which only change the presentation of a page.
+func (b Base) canonicalURL() string {
+	if len(b.Canonical) > 0 {
+		return b.Canonical
+	}
+	u, err := url.Parse(b.URLs.RequestURL)
+	if err != nil || len(u.Path) == 0 {
+		return b.URLs.BaseURL + "/"
+	}
+	return b.URLs.BaseURL + u.Path
+}
+
+// Meta builds the SEO meta tags of a page.
+func (b Base) Meta() Meta {
+	description := b.Description
+	if len(description) == 0 {
+		description = b.SubTitle
+	}
+
+	meta := Meta{
+		Title:       b.Title,
+		Description: description,
+		Canonical:   b.canonicalURL(),
+		Robots:      b.Robots,
+		Type:        "website",
+		SiteName:    siteName,
+		Locale:      "en_GB",
+		Image:       b.OpenGraphImage,
+		ImageAlt:    b.Title,
+		TwitterCard: "summary_large_image",
+		TwitterSite: twitterHandle,
+	}
+	if b.article != nil {
+		meta.Type = "article"
+		meta.PublishedTime = b.article.publishDate.UTC().Format(time.RFC3339)
+		if b.article.lastModified.After(b.article.publishDate) {
+			meta.ModifiedTime = b.article.lastModified.UTC().Format(time.RFC3339)
+		}
+		meta.Author = b.URLs.About()
+		meta.Tags = b.article.tags
+	}
+	return meta
+}
+
+// excerpt returns the text of the first paragraph, shortened
+// at a word boundary to the length of a meta description.
+func excerpt(content template.HTML) string {
+	var sb strings.Builder
+	inParagraph := false
+	z := html.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return ""
+			}
+			break
+		}
+		token := z.Token()
+		if token.DataAtom == atom.P {
+			if tt == html.EndTagToken && sb.Len() > 0 {
+				break
+			}
+			inParagraph = tt == html.StartTagToken
+			continue
+		}
+		if inParagraph && tt == html.TextToken {
+			sb.WriteString(token.Data)
+		}
+	}
+
+	text := strings.Join(strings.Fields(sb.String()), " ")
+	if len(text) <= maxDescriptionLength {
+		return text
+	}
+	cut := strings.LastIndex(text[:maxDescriptionLength-1], " ")
+	if cut <= 0 {
+		cut = maxDescriptionLength - 1
+		for !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+	}
+	return strings.TrimRight(text[:cut], ",.;:") + "…"
+}
@@ -3,10 +3,17 @@ package model
 import "fmt"
 
 type URLs struct {
-	RequestURL      string
-	BaseURL         string
-	CDN             string
-	DisqusShortname string
+	RequestURL string
+	BaseURL    string
+	CDN        string
+}
+
+func (u *URLs) Blog() string {
+	return u.BaseURL + "/blog"
+}
+
+func (u *URLs) Search() string {
+	return u.BaseURL + "/search"
 }
 
 func (u *URLs) Products() string {
@@ -33,20 +40,36 @@ func (u *URLs) AtomFeed() string {
 	return u.BaseURL + "/feed/atom"
 }
 
+func (u *URLs) Webmention() string {
+	return u.BaseURL + "/webmention"
+}
+
+func (u *URLs) NewsletterSubscribe() string {
+	return u.BaseURL + "/newsletter/subscribe"
+}
+
+func (u *URLs) NewsletterUnsubscribe() string {
+	return u.BaseURL + "/newsletter/unsubscribe"
+}
+
 func (u *URLs) BlogPostURL(blogPostID string) string {
 	return fmt.Sprintf("%s/%s", u.BaseURL, blogPostID)
 }
 
+func (u *URLs) BlogPostImageURL(blogPostID string) string {
+	return u.BlogPostURL(blogPostID) + "/og.png"
+}
+
 func (u *URLs) BlogPostCommentsURL(blogPostID string) string {
-	return u.BlogPostURL(blogPostID) + "#disqus_thread"
+	return u.BlogPostURL(blogPostID) + "#comments"
 }
 
-func (u *URLs) TagURL(tagName string) string {
-	return fmt.Sprintf("%s/tagged/%s", u.BaseURL, tagName)
+func (u *URLs) BlogPostCommentsFormURL(blogPostID string) string {
+	return u.BlogPostURL(blogPostID) + "/comments"
 }
 
-func (u *URLs) DisqusCountScript() string {
-	return fmt.Sprintf("//%s.disqus.com/count.js", u.DisqusShortname)
+func (u *URLs) TagURL(tagName string) string {
+	return fmt.Sprintf("%s/tagged/%s", u.BaseURL, tagName)
 }
 
 func (u *URLs) Logo() string {
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/internal/activitypub"
+)
+
+func (h *Handler) writeJSON(
+	w http.ResponseWriter,
+	r *http.Request,
+	contentType string,
+	v any,
+) {
+	err := h.writeResponse(
+		w, r,
+		http.StatusOK,
+		contentType,
+		func(buf io.Writer) error {
+			return json.NewEncoder(buf).Encode(v)
+		})
+	h.handleErr(w, r, err)
+}
+
+func (h *Handler) webFinger(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	webFinger, ok := h.activityPub.WebFinger(r.URL.Query().Get("resource"))
+	if !ok {
+		h.notFound(w, r)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	h.writeJSON(w, r, activitypub.JRDType, webFinger)
+}
+
+func (h *Handler) activityPubActor(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.setCacheDirective(w, 60*60, h.config.ApplicationVersion)
+	h.writeJSON(w, r, activitypub.ContentType, h.activityPub.Actor())
+}
+
+func (h *Handler) activityPubOutbox(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	h.setCacheDirective(w, 60*60, h.config.ApplicationVersion)
+	h.setLastModified(w, h.blogPosts[0].PublishDate)
+	h.writeJSON(w, r, activitypub.ContentType, h.activityPub.Outbox(h.blogPosts))
+}
+
+func (h *Handler) activityPubFollowers(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	followers, err := h.activityPub.FollowersCollection()
+	if h.handleErr(w, r, err) {
+		return
+	}
+	h.writeJSON(w, r, activitypub.ContentType, followers)
+}
+
+func (h *Handler) activityPubInbox(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, "The request body could not be read.")
+		return
+	}
+
+	err = h.activityPub.HandleInbox(r.Context(), slogctx.GetLogger(r.Context()), r, body)
+	if errors.Is(err, activitypub.ErrInvalidSignature) {
+		slogctx.GetLogger(r.Context()).Warn(
+			"Rejected ActivityPub activity.",
+			"error", err)
+		h.writeText(w, r, http.StatusUnauthorized, "The request signature is invalid.")
+		return
+	}
+	if errors.Is(err, activitypub.ErrUnsupportedActivity) {
+		slogctx.GetLogger(r.Context()).Debug(
+			"Ignored ActivityPub activity.",
+			"error", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/internal/analytics"
+	"github.com/dustedcodes/blog/internal/comments"
+)
+
+const (
+	maxBeaconSize    = 2048
+	statsDays        = 30
+	beaconsPerIP     = 30
+	beaconsRateLimit = 10 * time.Minute
+)
+
+type beaconEvent struct {
+	Path     string `json:"path"`
+	Referrer string `json:"referrer"`
+}
+
+// doNotTrack reports whether the visitor opted out of tracking
+// with the Do Not Track or Global Privacy Control headers.
+func doNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
+
+// beacon counts a page view which has been reported by the analytics script.
+// It always responds with 204 No Content, so that visitors can't tell
+// whether their page view has been counted or not. Page views beyond
+// the rate limit of an IP address are not counted either.
+func (h *Handler) beacon(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	userAgent := r.UserAgent()
+	if doNotTrack(r) || analytics.IsBot(userAgent) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ip := comments.ClientIP(r.RemoteAddr)
+	if !h.beaconLimiter.Allow(ip, time.Now()) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	event := &beaconEvent{}
+	err := json.NewDecoder(io.LimitReader(r.Body, maxBeaconSize)).Decode(event)
+	if err != nil || !strings.HasPrefix(event.Path, "/") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	postID := ""
+	if blogPost, ok := h.findBlogPost(strings.Trim(event.Path, "/")); ok {
+		postID = blogPost.ID
+	}
+
+	err = h.analytics.Track(analytics.Visit{
+		Time:      time.Now(),
+		IP:        ip,
+		UserAgent: userAgent,
+		PostID:    postID,
+		Referrer:  analytics.ReferrerHost(event.Referrer, h.config.PublicHost),
+	})
+	if err != nil {
+		slogctx.GetLogger(r.Context()).Error(
+			"Failed to track page view.",
+			"error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stats(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	titles := map[string]string{}
+	for _, blogPost := range h.blogPosts {
+		titles[blogPost.ID] = blogPost.Title
+	}
+	since := time.Now().UTC().AddDate(0, 0, -(statsDays - 1))
+
+	h.renderView(w, r, 200, "stats",
+		h.newBaseModel(r).WithTitle("Statistics").NoIndex().Stats(h.analytics.Days(since), titles))
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
+)
+
+const (
+	commentsPerIP     = 5
+	commentsRateLimit = 10 * time.Minute
+	honeypotField     = "email_confirm"
+)
+
+func (h *Handler) commentCounts(r *http.Request) map[string]int {
+	counts, err := h.comments.Counts()
+	if err != nil {
+		slogctx.GetLogger(r.Context()).Error(
+			"Failed to count comments.",
+			"error", err)
+		return map[string]int{}
+	}
+	return counts
+}
+
+func (h *Handler) commentFormFromQuery(r *http.Request) model.CommentForm {
+	query := r.URL.Query()
+	return model.CommentForm{
+		ParentID: query.Get("replyTo"),
+		Received: query.Get("comment") == "received",
+	}
+}
+
+func (h *Handler) postComment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	blogPostID := r.PathValue("post")
+	blogPost, ok := h.findBlogPost(blogPostID)
+	if !ok {
+		h.notFound(w, r)
+		return
+	}
+
+	if !h.commentLimiter.Allow(comments.ClientIP(r.RemoteAddr), time.Now()) {
+		h.writeText(w, r,
+			http.StatusTooManyRequests,
+			"You have submitted too many comments. Please try again later.")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, "The submitted form could not be read.")
+		return
+	}
+
+	form := model.CommentForm{
+		ParentID: strings.TrimSpace(r.PostForm.Get("parent")),
+		Author:   strings.TrimSpace(r.PostForm.Get("author")),
+		Website:  strings.TrimSpace(r.PostForm.Get("website")),
+		Content:  strings.TrimSpace(r.PostForm.Get("content")),
+	}
+
+	if len(form.Website) > 0 {
+		website, err := url.Parse(form.Website)
+		if err != nil || (website.Scheme != "http" && website.Scheme != "https") {
+			form.Error = "Website must be a valid http or https URL."
+			h.renderBlogPost(w, r, http.StatusUnprocessableEntity, blogPost, form)
+			return
+		}
+	}
+
+	comment := &comments.Comment{
+		PostID:    blogPost.ID,
+		ParentID:  form.ParentID,
+		Author:    form.Author,
+		Website:   form.Website,
+		Content:   form.Content,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = comment.Validate()
+	if err != nil {
+		form.Error = strings.ToUpper(err.Error()[:1]) + err.Error()[1:] + "."
+		h.renderBlogPost(w, r, http.StatusUnprocessableEntity, blogPost, form)
+		return
+	}
+
+	// Replies must belong to a visible comment of the same blog post:
+	if len(comment.ParentID) > 0 {
+		parent, err := h.comments.Get(blogPost.ID, comment.ParentID)
+		if errors.Is(err, comments.ErrCommentNotFound) || err == nil && parent.Status != comments.StatusApproved {
+			form.ParentID = ""
+			form.Error = "The comment you are replying to doesn't exist."
+			h.renderBlogPost(w, r, http.StatusUnprocessableEntity, blogPost, form)
+			return
+		}
+		if h.handleErr(w, r, err) {
+			return
+		}
+	}
+
+	html, err := blog.RenderUntrustedMarkdown(comment.Content)
+	if h.handleErr(w, r, err) {
+		return
+	}
+	comment.HTML = html
+	comment.Status = comments.Classify(comment, r.PostForm.Get(honeypotField))
+
+	err = h.comments.Add(comment)
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	slogctx.GetLogger(r.Context()).Info(
+		"Received new comment.",
+		"blogPostID", blogPost.ID,
+		"commentID", comment.ID,
+		"status", comment.Status)
+
+	http.Redirect(w, r,
+		"/"+blogPost.ID+"?comment=received#comments",
+		http.StatusSeeOther)
+}
+
+// requireAdmin protects admin pages with HTTP basic authentication.
+// Unsafe requests must also originate from the same site, because
+// browsers send basic auth credentials along with cross-site forms.
+func (h *Handler) requireAdmin(
+	w http.ResponseWriter,
+	r *http.Request,
+) bool {
+	_, password, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(h.config.AdminPassword)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+		h.writeText(w, r, http.StatusUnauthorized, "Unauthorized.")
+		return false
+	}
+
+	err := http.NewCrossOriginProtection().Check(r)
+	if err != nil {
+		h.writeText(w, r, http.StatusForbidden, "Cross-origin requests are not allowed.")
+		return false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	return true
+}
+
+func (h *Handler) moderation(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	pending, err := h.comments.ListAll(comments.StatusPending)
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	h.renderView(w, r, 200, "moderation",
+		h.newBaseModel(r).WithTitle("Comment moderation").NoIndex().Moderation(pending))
+}
+
+func (h *Handler) moderateComment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	status, err := comments.ParseStatus(r.FormValue("status"))
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.comments.SetStatus(r.PathValue("post"), r.PathValue("comment"), status)
+	if errors.Is(err, comments.ErrCommentNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	http.Redirect(w, r, "/admin/comments", http.StatusSeeOther)
+}
@@ -2,27 +2,70 @@ package web
 
 import (
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/dusted-go/http/v6/htmlview"
-	"github.com/dusted-go/http/v6/route"
 
 	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/activitypub"
+	"github.com/dustedcodes/blog/internal/analytics"
 	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
 	"github.com/dustedcodes/blog/internal/config"
+	"github.com/dustedcodes/blog/internal/newsletter"
+	"github.com/dustedcodes/blog/internal/ogimage"
+	"github.com/dustedcodes/blog/internal/redirects"
+	"github.com/dustedcodes/blog/internal/router"
+	"github.com/dustedcodes/blog/internal/webmention"
 )
 
 type Handler struct {
-	config     *config.Config
-	assets     *model.Assets
-	viewWriter *htmlview.Writer
-	blogPosts  []*blog.Post
+	config       *config.Config
+	assets       *model.Assets
+	viewWriter   *htmlview.Writer
+	blogPosts    []*blog.Post
+	retiredPosts []*blog.Post
+	redirects    *redirects.Table
+	router       *router.Router
+
+	comments       *comments.Store
+	commentLimiter *comments.RateLimiter
+
+	mentions        *webmention.Store
+	mentionReceiver *webmention.Receiver
+	mentionLimiter  *comments.RateLimiter
+
+	activityPub *activitypub.Service
+
+	// newsletter is nil when email delivery hasn't been configured.
+	newsletter          *newsletter.Newsletter
+	subscriptionLimiter *comments.RateLimiter
+
+	analytics     *analytics.Tracker
+	beaconLimiter *comments.RateLimiter
+
+	ogImages *ogimage.Cache
+
+	// contentLengths of rendered responses for HEAD requests:
+	contentLengths contentLengths
+
+	// startedAt is the Last-Modified date of all pages
+	// which aren't derived from a blog post.
+	startedAt time.Time
 }
 
 func NewHandler(
 	config *config.Config,
 	assets *model.Assets,
 	blobPosts []*blog.Post,
+	redirects *redirects.Table,
+	commentStore *comments.Store,
+	mentionStore *webmention.Store,
+	mentionReceiver *webmention.Receiver,
+	activityPub *activitypub.Service,
+	newsletter *newsletter.Newsletter,
+	tracker *analytics.Tracker,
+	ogImages *ogimage.Cache,
 ) *Handler {
 	masterFiles := []string{
 		"dist/templates/components/branding.html",
@@ -44,25 +87,39 @@ func NewHandler(
 		"index": append(masterFiles,
 			"dist/templates/svgs/illustrations/dustin-tshirt.svg",
 			"dist/templates/pages/index.html",
+			"dist/templates/components/popular.html",
 		),
 		"blog": append(masterFiles,
 			"dist/templates/pages/_page.html",
 			"dist/templates/svgs/illustrations/blogging.svg",
 			"dist/templates/pages/blog.html",
+			"dist/templates/components/popular.html",
+			"dist/templates/components/newsletter.html",
 		),
 		"tagged": append(masterFiles,
 			"dist/templates/pages/_page.html",
 			"dist/templates/pages/tagged.html",
 			"dist/templates/components/tags.html",
 		),
+		"search": append(masterFiles,
+			"dist/templates/pages/_page.html",
+			"dist/templates/pages/search.html",
+			"dist/templates/components/tags.html",
+		),
 		"404": append(masterFiles,
 			"dist/templates/svgs/illustrations/404.svg",
 			"dist/templates/pages/404.html",
 		),
+		"410": append(masterFiles,
+			"dist/templates/svgs/illustrations/404.svg",
+			"dist/templates/pages/410.html",
+		),
 		"blogPost": append(masterFiles,
 			"dist/templates/pages/_page.html",
 			"dist/templates/pages/article.html",
 			"dist/templates/components/tags.html",
+			"dist/templates/components/comment.html",
+			"dist/templates/components/newsletter.html",
 		),
 		"products": append(masterFiles,
 			"dist/templates/pages/_page.html",
@@ -91,109 +148,128 @@ func NewHandler(
 			"dist/templates/pages/_page.html",
 			"dist/templates/pages/about.html",
 		),
+		"message": append(masterFiles,
+			"dist/templates/pages/_page.html",
+			"dist/templates/pages/message.html",
+		),
+		"unsubscribe": append(masterFiles,
+			"dist/templates/pages/_page.html",
+			"dist/templates/pages/unsubscribe.html",
+		),
+		"stats": append(masterFiles,
+			"dist/templates/pages/_page.html",
+			"dist/templates/pages/stats.html",
+		),
+		"moderation": append(masterFiles,
+			"dist/templates/pages/_page.html",
+			"dist/templates/pages/moderation.html",
+			"dist/templates/components/comment.html",
+		),
 	}
 	viewWriter := htmlview.NewWriter(
 		config.HotReload(),
 		"layout",
 		templateFiles)
 
-	return &Handler{
-		config:     config,
-		assets:     assets,
-		viewWriter: viewWriter,
-		blogPosts:  blobPosts,
-	}
-}
-
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	verb := r.Method
-	path := r.URL.Path
-
-	if verb != "GET" && verb != "HEAD" {
-		h.notFound(w, r)
-		return
+	// Retired blog posts respond with 410 Gone and
+	// must not appear in listings, feeds or the sitemap:
+	publishedPosts := []*blog.Post{}
+	retiredPosts := []*blog.Post{}
+	for _, blogPost := range blobPosts {
+		if blogPost.Retired {
+			retiredPosts = append(retiredPosts, blogPost)
+			continue
+		}
+		publishedPosts = append(publishedPosts, blogPost)
 	}
 
-	if path == "/" {
-		h.index(w, r)
-		return
-	}
+	h := &Handler{
+		config:       config,
+		assets:       assets,
+		viewWriter:   viewWriter,
+		blogPosts:    publishedPosts,
+		retiredPosts: retiredPosts,
+		redirects:    redirects,
+		startedAt:    time.Now(),
 
-	if path == "/version" {
-		h.version(w, r)
-		return
-	}
+		comments:       commentStore,
+		commentLimiter: comments.NewRateLimiter(commentsPerIP, commentsRateLimit),
 
-	if path == "/ping" {
-		h.ping(w, r)
-		return
-	}
-
-	if path == "/panic" && !h.config.IsProduction() {
-		h.panic(w, r)
-		return
-	}
+		mentions:        mentionStore,
+		mentionReceiver: mentionReceiver,
+		mentionLimiter:  comments.NewRateLimiter(mentionsPerIP, mentionsRateLimit),
 
-	if path == "/blog" {
-		h.blog(w, r)
-		return
-	}
+		activityPub: activityPub,
 
-	if path == "/products" {
-		h.products(w, r)
-		return
-	}
+		newsletter:          newsletter,
+		subscriptionLimiter: comments.NewRateLimiter(subscriptionsPerIP, subscriptionsRateLimit),
 
-	if path == "/open-source" {
-		h.oss(w, r)
-		return
-	}
+		analytics:     tracker,
+		beaconLimiter: comments.NewRateLimiter(beaconsPerIP, beaconsRateLimit),
 
-	if path == "/hire" {
-		h.hire(w, r)
-		return
+		ogImages: ogImages,
 	}
+	h.router = router.New(h.notFound, h.methodNotAllowed)
+	h.registerRoutes()
 
-	if path == "/about" {
-		h.about(w, r)
-		return
-	}
+	return h
+}
 
-	if path == "/feed/rss" {
-		h.rss(w, r)
-		return
-	}
+func (h *Handler) registerRoutes() {
+	h.router.GET("/", h.index)
+	h.router.GET("/version", h.version)
+	h.router.GET("/ping", h.ping)
+	h.router.GET("/blog", h.blog)
+	h.router.GET("/products", h.products)
+	h.router.GET("/open-source", h.oss)
+	h.router.GET("/hire", h.hire)
+	h.router.GET("/about", h.about)
+	h.router.GET("/feed/rss", h.rss)
+	h.router.GET("/feed/atom", h.atom)
+	h.router.GET("/sitemap.xml", h.sitemap)
+	h.router.GET("/robots.txt", h.robots)
+	h.router.GET("/tagged/{tag}", h.tagged)
+	h.router.GET("/search", h.search)
+	h.router.GET("/.well-known/webfinger", h.webFinger)
+	h.router.GET("/activitypub/actor", h.activityPubActor)
+	h.router.GET("/activitypub/outbox", h.activityPubOutbox)
+	h.router.GET("/activitypub/followers", h.activityPubFollowers)
+	h.router.POST("/activitypub/inbox", h.activityPubInbox)
+	h.router.GET("/{post}", h.blogPost)
+	h.router.POST("/{post}/comments", h.postComment)
+	h.router.GET("/{post}/og.png", h.openGraphImage)
+	h.router.POST("/webmention", h.receiveWebmention)
+	h.router.POST("/beacon", h.beacon)
 
-	if path == "/feed/atom" {
-		h.atom(w, r)
-		return
+	if h.newsletter != nil {
+		h.router.POST("/newsletter/subscribe", h.subscribe)
+		h.router.GET("/newsletter/confirm", h.confirmSubscription)
+		h.router.GET("/newsletter/unsubscribe", h.unsubscribe)
+		h.router.POST("/newsletter/unsubscribe", h.confirmUnsubscribe)
 	}
 
-	if path == "/sitemap.xml" {
-		h.sitemap(w, r)
-		return
+	if h.config.AdminEnabled() {
+		h.router.GET("/admin/comments", h.moderation)
+		h.router.POST("/admin/comments/{post}/{comment}", h.moderateComment)
+		h.router.GET("/admin/stats", h.stats)
 	}
 
-	if path == "/robots.txt" {
-		h.robots(w, r)
-		return
+	if !h.config.IsProduction() {
+		h.router.GET("/panic", h.panic)
 	}
+}
 
-	head, tail := route.ShiftPath(path)
-	if head == "tagged" {
-		tagName := strings.TrimLeft(tail, "/")
-		h.tagged(w, r, tagName)
-		return
-	}
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	verb := r.Method
+	path := r.URL.Path
 
 	// Support for legacy URLs:
-	if head == "demystifying-aspnet-mvc-5-error-pages" {
-		http.Redirect(
-			w, r,
-			"/demystifying-aspnet-mvc-5-error-pages-and-error-logging",
-			http.StatusMovedPermanently)
-		return
+	if verb == http.MethodGet || verb == http.MethodHead {
+		if target, statusCode, ok := h.redirects.Match(path); ok {
+			http.Redirect(w, r, target, statusCode)
+			return
+		}
 	}
 
-	h.blogPost(w, r)
+	h.router.ServeHTTP(w, r)
 }
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/activitypub"
+	"github.com/dustedcodes/blog/internal/analytics"
+	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/config"
+	"github.com/dustedcodes/blog/internal/ogimage"
+	"github.com/dustedcodes/blog/internal/redirects"
+	"github.com/dustedcodes/blog/internal/safehttp"
+	"github.com/dustedcodes/blog/internal/webmention"
+)
+
+// newTestHandler returns a production handler for the blog posts,
+// which must be sorted newest first, with empty stores.
+func newTestHandler(t *testing.T, blogPosts ...*blog.Post) *Handler {
+	t.Helper()
+	// Templates are relative to cmd/blog:
+	t.Chdir("..")
+	data := t.TempDir()
+	config := &config.Config{
+		EnvironmentName:     "production",
+		ApplicationName:     "blog",
+		ApplicationVersion:  "1.0.0",
+		PublicHost:          "dusted.codes",
+		BaseURL:             "https://dusted.codes",
+		CDN:                 "https://cdn.dusted.codes",
+		ActivityPubUsername: "blog",
+	}
+
+	commentStore, err := comments.Open(filepath.Join(data, "comments.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = commentStore.Close()
+	})
+	mentionStore, err := webmention.Open(filepath.Join(data, "webmentions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = mentionStore.Close()
+	})
+	activityPubStore, err := activitypub.Open(filepath.Join(data, "activitypub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = activityPubStore.Close()
+	})
+	activityPubKey, err := activitypub.LoadOrCreateKey(filepath.Join(data, "activitypub.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := safehttp.NewClient(false)
+	activityPub, err := activitypub.NewService(
+		activitypub.Settings{BaseURL: config.BaseURL, Host: config.PublicHost, Username: config.ActivityPubUsername},
+		activityPubKey,
+		activityPubStore,
+		client,
+		config.UserAgent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyticsStore, err := analytics.Open(filepath.Join(data, "analytics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = analyticsStore.Close()
+	})
+	tracker, err := analytics.NewTracker(analyticsStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ogRenderer, err := ogimage.NewRenderer("Dusted Codes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHandler(
+		config,
+		&model.Assets{CSSPath: "/output.css", JSPath: "/script.js"},
+		blogPosts,
+		redirects.NewTable(),
+		commentStore,
+		mentionStore,
+		webmention.NewReceiver(mentionStore, client, config.UserAgent()),
+		activityPub,
+		nil,
+		tracker,
+		ogimage.NewCache(ogRenderer))
+}
+
+func newTestPost(id string, title string, publishDate time.Time, tags ...string) *blog.Post {
+	return &blog.Post{
+		ID:          id,
+		Title:       title,
+		PublishDate: publishDate,
+		Tags:        tags,
+		HTML:        template.HTML("<p>" + title + "</p>"), //nolint: gosec // test content
+		HashCode:    id,
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequestWithContext(context.Background(), http.MethodGet, path, nil)
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRetiredBlogPosts(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	published := newTestPost("current-post", "Current post", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "dotnet")
+	retired := newTestPost("outdated-post", "Outdated post", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "dotnet")
+	retired.Retired = true
+	retired.Replacement = "/current-post"
+	withoutReplacement := newTestPost("removed-post", "Removed post", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), "dotnet")
+	withoutReplacement.Retired = true
+	h := newTestHandler(t, published, retired, withoutReplacement)
+
+	t.Run("gone", func(t *testing.T) {
+		w := get(t, h, "/outdated-post")
+		if w.Code != http.StatusGone {
+			t.Fatalf("expected status %d, got %d", http.StatusGone, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "<em>Outdated post</em>") {
+			t.Error("expected the title of the retired blog post")
+		}
+		if !strings.Contains(body, `href="/current-post"`) {
+			t.Error("expected a link to the replacement")
+		}
+	})
+
+	t.Run("gone without replacement", func(t *testing.T) {
+		w := get(t, h, "/removed-post")
+		if w.Code != http.StatusGone {
+			t.Fatalf("expected status %d, got %d", http.StatusGone, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "this article</a>") {
+			t.Error("expected no link to a replacement")
+		}
+	})
+
+	t.Run("unknown blog post", func(t *testing.T) {
+		if w := get(t, h, "/unknown-post"); w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	for _, path := range []string{"/blog", "/tagged/dotnet", "/feed/rss", "/feed/atom", "/sitemap.xml"} {
+		t.Run(path, func(t *testing.T) {
+			w := get(t, h, path)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "/current-post") {
+				t.Error("expected the published blog post")
+			}
+			for _, id := range []string{"outdated-post", "removed-post"} {
+				if strings.Contains(body, id) {
+					t.Errorf("expected no link to the retired blog post %s", id)
+				}
+			}
+		})
+	}
+}
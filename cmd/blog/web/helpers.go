@@ -1,8 +1,12 @@
 package web
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dusted-go/logging/v2/slogctx"
@@ -22,22 +26,22 @@ var (
 
 func (h *Handler) getURLs(r *http.Request) *model.URLs {
 	return &model.URLs{
-		RequestURL:      r.URL.Redacted(),
-		BaseURL:         h.config.BaseURL,
-		CDN:             h.config.CDN,
-		DisqusShortname: h.config.DisqusShortname,
+		RequestURL: r.URL.Redacted(),
+		BaseURL:    h.config.BaseURL,
+		CDN:        h.config.CDN,
 	}
 }
 
 func (h *Handler) newBaseModel(r *http.Request) model.Base {
 	return model.Base{
-		Title:           "Dusted Codes",
-		SubTitle:        "Programming, Coffee and Indie Hacking",
-		Year:            time.Now().Year(),
-		Assets:          h.assets,
-		URLs:            h.getURLs(r),
-		DisqusShortname: h.config.DisqusShortname,
-		OpenGraphImage:  defaultOpenGraphImage,
+		Title:          "Dusted Codes",
+		SubTitle:       "Programming, Coffee and Indie Hacking",
+		Year:           time.Now().Year(),
+		Assets:         h.assets,
+		URLs:           h.getURLs(r),
+		OpenGraphImage: defaultOpenGraphImage,
+
+		NewsletterEnabled: h.config.NewsletterEnabled(),
 	}
 }
 
@@ -57,12 +61,110 @@ func (h *Handler) internalError(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	w.Header().Del("Cache-Control")
+	w.Header().Del("ETag")
+	w.Header().Del("Last-Modified")
 	h.writeText(w, r,
 		http.StatusInternalServerError,
 		"Oops, something went wrong. The server encountered an internal error "+
 			"or misconfiguration and was unable to complete your request.")
 }
 
+// bodyWriter adapts an io.Writer to a http.ResponseWriter,
+// so that views can be rendered into a buffer first.
+type bodyWriter struct {
+	io.Writer
+	header http.Header
+}
+
+func (b bodyWriter) Header() http.Header {
+	return b.header
+}
+
+func (b bodyWriter) WriteHeader(int) {}
+
+// contentLengths remembers the Content-Length of rendered responses by
+// their ETag, so that HEAD requests can send it without rendering.
+type contentLengths struct {
+	mu      sync.Mutex
+	lengths map[string]int
+}
+
+// maxContentLengths bounds the memory of remembered lengths,
+// because query strings make the number of URLs unlimited.
+const maxContentLengths = 10000
+
+// contentLengthKey returns the key of a response,
+// responses without an ETag aren't remembered.
+func contentLengthKey(w http.ResponseWriter, r *http.Request, statusCode int) (string, bool) {
+	eTag := w.Header().Get("ETag")
+	if len(eTag) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d %s %s", statusCode, r.URL.RequestURI(), eTag), true
+}
+
+func (c *contentLengths) get(key string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	length, ok := c.lengths[key]
+	return length, ok
+}
+
+func (c *contentLengths) set(key string, length int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lengths == nil || len(c.lengths) >= maxContentLengths {
+		c.lengths = map[string]int{}
+	}
+	c.lengths[key] = length
+}
+
+// writeResponse renders a response body into a buffer before writing it,
+// which allows to set an accurate Content-Length header.
+// HEAD requests only send the headers without rendering the body.
+// Their Content-Length is the one of an earlier GET request
+// with the same ETag, or missing if there hasn't been any.
+func (h *Handler) writeResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	statusCode int,
+	contentType string,
+	render func(w io.Writer) error,
+) error {
+	w.Header().Set("Content-Type", contentType)
+	key, hasKey := contentLengthKey(w, r, statusCode)
+
+	if r.Method == http.MethodHead {
+		if length, ok := h.contentLengths.get(key); ok {
+			w.Header().Set("Content-Length", strconv.Itoa(length))
+		}
+		w.WriteHeader(statusCode)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := render(&buf)
+	if err != nil {
+		w.Header().Del("Content-Type")
+		return err
+	}
+
+	if hasKey {
+		h.contentLengths.set(key, buf.Len())
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(statusCode)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		slogctx.GetLogger(r.Context()).Error(
+			"Failed to write response body.",
+			"error", err,
+			"contentType", contentType)
+	}
+	return nil
+}
+
 func (h *Handler) renderView(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -70,11 +172,17 @@ func (h *Handler) renderView(
 	viewKey string,
 	viewModel any,
 ) {
-	err := h.viewWriter.WriteView(
-		w,
+	err := h.writeResponse(
+		w, r,
 		statusCode,
-		viewKey,
-		viewModel)
+		"text/html; charset=utf-8",
+		func(buf io.Writer) error {
+			return h.viewWriter.WriteView(
+				bodyWriter{Writer: buf, header: w.Header()},
+				statusCode,
+				viewKey,
+				viewModel)
+		})
 	if err != nil {
 		slogctx.GetLogger(r.Context()).Error(
 			"Failed to write html view response.",
@@ -84,6 +192,31 @@ func (h *Handler) renderView(
 	}
 }
 
+// writeFeed responds with a syndication feed or sitemap.
+func (h *Handler) writeFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	contentType string,
+	build func(r *http.Request) ([]byte, error),
+) {
+	latestPost := h.blogPosts[0]
+	h.setCacheDirective(w, 60*60, h.config.ApplicationVersion)
+	h.setLastModified(w, latestPost.PublishDate)
+	err := h.writeResponse(
+		w, r,
+		http.StatusOK,
+		contentType,
+		func(buf io.Writer) error {
+			bytes, err := build(r)
+			if err != nil {
+				return err
+			}
+			_, err = buf.Write(bytes)
+			return err
+		})
+	h.handleErr(w, r, err)
+}
+
 func (h *Handler) handleErr(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -107,7 +240,39 @@ func (h *Handler) notFound(
 		w, r,
 		http.StatusNotFound,
 		"404",
-		h.newBaseModel(r).WithTitle("Page not found").Empty())
+		h.newBaseModel(r).WithTitle("Page not found").NoIndex().Empty())
+}
+
+func (h *Handler) methodNotAllowed(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	// The blog post routes match any path, but only existing
+	// blog posts can have methods which aren't allowed:
+	if blogPostID := r.PathValue("post"); len(blogPostID) > 0 {
+		if _, ok := h.findBlogPost(blogPostID); !ok {
+			w.Header().Del("Allow")
+			h.notFound(w, r)
+			return
+		}
+	}
+	h.writeText(w, r,
+		http.StatusMethodNotAllowed,
+		"The requested method is not allowed for this resource.")
+}
+
+func (h *Handler) gone(
+	w http.ResponseWriter,
+	r *http.Request,
+	blogPost *blog.Post,
+) {
+	h.setCacheDirective(w, 60*60*4, blogPost.HashCode)
+	h.setLastModified(w, blogPost.PublishDate)
+	h.renderView(
+		w, r,
+		http.StatusGone,
+		"410",
+		h.newBaseModel(r).WithTitle("Page removed").NoIndex().Gone(blogPost.Title, blogPost.Replacement))
 }
 
 func (h *Handler) setCacheDirective(
@@ -120,6 +285,20 @@ func (h *Handler) setCacheDirective(
 	w.Header().Add("ETag", fmt.Sprintf("\"%s\"", eTag))
 }
 
+// revisionETag combines an ETag with the revisions of the comment and
+// webmention stores, so that cached pages get invalidated when
+// comments or webmentions change.
+func (h *Handler) revisionETag(eTag string) string {
+	return fmt.Sprintf("%s-%d-%d", eTag, h.comments.Revision(), h.mentions.Revision())
+}
+
+func (h *Handler) setLastModified(
+	w http.ResponseWriter,
+	lastModified time.Time,
+) {
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+}
+
 func (h *Handler) Recover(recovered any) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := fmt.Errorf("panic: %+v", recovered)
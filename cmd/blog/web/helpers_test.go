@@ -0,0 +1,98 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/config"
+)
+
+func TestWriteResponseDoesNotRenderHead(t *testing.T) {
+	h := &Handler{}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/about", nil)
+	h.setCacheDirective(w, 60, "v1")
+
+	err := h.writeResponse(w, r, http.StatusOK, "text/html; charset=utf-8",
+		func(io.Writer) error {
+			t.Fatal("expected HEAD not to render the body")
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected an empty body, got %q", w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"v1"` {
+		t.Errorf("expected ETag \"v1\", got %s", etag)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("expected Content-Type text/html, got %s", contentType)
+	}
+	if length := w.Header().Get("Content-Length"); len(length) > 0 {
+		t.Errorf("expected no Content-Length before a GET request, got %s", length)
+	}
+}
+
+func TestWriteResponseContentLengthOfHead(t *testing.T) {
+	h := &Handler{}
+	render := func(buf io.Writer) error {
+		_, err := buf.Write([]byte("<h1>About</h1>"))
+		return err
+	}
+	request := func(method string, eTag string, render func(io.Writer) error) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.setCacheDirective(w, 60, eTag)
+		err := h.writeResponse(w, httptest.NewRequest(method, "/about", nil), http.StatusOK, "text/html", render)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	noRender := func(io.Writer) error {
+		t.Fatal("expected HEAD not to render the body")
+		return nil
+	}
+
+	get := request(http.MethodGet, "v1", render)
+	if length := get.Header().Get("Content-Length"); length != "14" {
+		t.Errorf("expected Content-Length 14 of GET, got %s", length)
+	}
+	if length := request(http.MethodHead, "v1", noRender).Header().Get("Content-Length"); length != "14" {
+		t.Errorf("expected Content-Length 14 of HEAD, got %s", length)
+	}
+	if length := request(http.MethodHead, "v2", noRender).Header().Get("Content-Length"); len(length) > 0 {
+		t.Errorf("expected no Content-Length for another ETag, got %s", length)
+	}
+}
+
+func TestWriteFeedDoesNotBuildHead(t *testing.T) {
+	h := &Handler{
+		config:    &config.Config{ApplicationVersion: "1.0.0"},
+		blogPosts: []*blog.Post{{ID: "example", PublishDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/feed/rss", nil)
+
+	h.writeFeed(w, r, "application/rss+xml", func(*http.Request) ([]byte, error) {
+		t.Fatal("expected HEAD not to build the feed")
+		return nil, nil
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != "Mon, 01 Jan 2024 00:00:00 GMT" {
+		t.Errorf("expected Last-Modified of the latest blog post, got %s", lastModified)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected an empty body, got %q", w.Body.String())
+	}
+}
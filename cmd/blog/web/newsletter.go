@@ -0,0 +1,146 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/newsletter"
+)
+
+const (
+	subscriptionsPerIP     = 3
+	subscriptionsRateLimit = time.Hour
+)
+
+func (h *Handler) renderNewsletterMessage(
+	w http.ResponseWriter,
+	r *http.Request,
+	statusCode int,
+	title string,
+	msgs ...template.HTML,
+) {
+	w.Header().Set("Cache-Control", "no-store")
+	h.renderView(w, r, statusCode, "message",
+		h.newBaseModel(r).WithTitle(title).NoIndex().UserMessages(msgs...))
+}
+
+func (h *Handler) subscribe(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	err := r.ParseForm()
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, "The submitted form could not be read.")
+		return
+	}
+
+	email, err := newsletter.ParseEmail(r.PostForm.Get("email"))
+	if err != nil {
+		msg := template.HTMLEscapeString(strings.ToUpper(err.Error()[:1]) + err.Error()[1:] + ".")
+		h.renderNewsletterMessage(w, r,
+			http.StatusUnprocessableEntity,
+			"Subscription failed",
+			template.HTML(msg), //nolint: gosec // escaped above
+			"Please go back and try again.")
+		return
+	}
+
+	if !h.subscriptionLimiter.Allow(comments.ClientIP(r.RemoteAddr), time.Now()) {
+		h.writeText(w, r,
+			http.StatusTooManyRequests,
+			"You have submitted too many subscriptions. Please try again later.")
+		return
+	}
+
+	// Bots which fill in the honeypot get the same
+	// response, but no confirmation email is sent:
+	if len(r.PostForm.Get(honeypotField)) == 0 {
+		err = h.newsletter.Subscribe(r.Context(), email)
+		if h.handleErr(w, r, err) {
+			return
+		}
+		slogctx.GetLogger(r.Context()).Info("New newsletter subscription.")
+	}
+
+	h.renderNewsletterMessage(w, r,
+		http.StatusOK,
+		"Check your inbox",
+		"Thank you for subscribing!",
+		"Please confirm your subscription by clicking the link in the email which has just been sent to you.")
+}
+
+func (h *Handler) confirmSubscription(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	_, err := h.newsletter.Confirm(r.URL.Query().Get("token"))
+	if errors.Is(err, newsletter.ErrInvalidToken) {
+		h.renderNewsletterMessage(w, r,
+			http.StatusNotFound,
+			"Invalid link",
+			"This confirmation link is invalid or has already been replaced by a newer one.")
+		return
+	}
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	h.renderNewsletterMessage(w, r,
+		http.StatusOK,
+		"Subscription confirmed",
+		"Thank you! You will receive an email when new posts get published.")
+}
+
+// unsubscribe asks for a confirmation before removing a subscriber,
+// because email security scanners open every link of an email.
+func (h *Handler) unsubscribe(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	w.Header().Set("Cache-Control", "no-store")
+	h.renderView(w, r, http.StatusOK, "unsubscribe",
+		h.newBaseModel(r).WithTitle("Unsubscribe").NoIndex().Unsubscribe(r.URL.Query().Get("token")))
+}
+
+// confirmUnsubscribe handles the unsubscribe form as well as
+// one-click unsubscribe requests from email clients (RFC 8058).
+func (h *Handler) confirmUnsubscribe(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	err := r.ParseForm()
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, "The submitted form could not be read.")
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if len(token) == 0 {
+		token = r.PostForm.Get("token")
+	}
+
+	_, err = h.newsletter.Unsubscribe(token)
+	if errors.Is(err, newsletter.ErrInvalidToken) {
+		h.renderNewsletterMessage(w, r,
+			http.StatusNotFound,
+			"Invalid link",
+			"This unsubscribe link is invalid or you have already unsubscribed.")
+		return
+	}
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	slogctx.GetLogger(r.Context()).Info("Newsletter subscriber unsubscribed.")
+
+	h.renderNewsletterMessage(w, r,
+		http.StatusOK,
+		"Unsubscribed",
+		"You have been unsubscribed and won't receive any more emails.")
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/ogimage"
+)
+
+func (h *Handler) openGraphImage(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	blogPostID := r.PathValue("post")
+
+	blogPost, ok := h.findBlogPost(blogPostID)
+	if !h.config.IsProduction() {
+		post, err := blog.ReadPost(r.Context(), blog.DefaultBlogPostPath, blogPostID, h.config.BlogOptions())
+		if errors.Is(err, blog.ErrBlogPostNotFound) {
+			h.notFound(w, r)
+			return
+		}
+		if h.handleErr(w, r, err) {
+			return
+		}
+		blogPost, ok = post, !post.Retired
+	}
+	if !ok {
+		h.notFound(w, r)
+		return
+	}
+
+	// The application version invalidates images when the design changes:
+	h.setCacheDirective(w, 60*60*24*7, h.config.ApplicationVersion+"-"+blogPost.HashCode)
+	h.setLastModified(w, blogPost.PublishDate)
+	err := h.writeResponse(w, r, http.StatusOK, ogimage.MimeType,
+		func(buf io.Writer) error {
+			img, err := h.ogImages.PostImage(blogPost)
+			if err != nil {
+				return err
+			}
+			_, err = buf.Write(img)
+			return err
+		})
+	h.handleErr(w, r, err)
+}
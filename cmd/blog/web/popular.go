@@ -0,0 +1,87 @@
+package web
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"time"
+
+	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/analytics"
+)
+
+const popularPostsLimit = 5
+
+type popularWindow struct {
+	Key   string
+	Label string
+	// Days is the number of days including today,
+	// zero means all time.
+	Days int
+}
+
+var (
+	popularWindows = []popularWindow{
+		{Key: "7d", Label: "7 days", Days: 7},
+		{Key: "30d", Label: "30 days", Days: 30},
+		{Key: "all", Label: "All time", Days: 0},
+	}
+	defaultPopularWindow = popularWindows[1]
+)
+
+func (p popularWindow) since(now time.Time) time.Time {
+	if p.Days == 0 {
+		return time.Time{}
+	}
+	return now.UTC().AddDate(0, 0, -(p.Days - 1))
+}
+
+// countRequest counts a request of a blog post towards the
+// most read posts. Crawlers and HEAD requests are ignored.
+func (h *Handler) countRequest(r *http.Request, blogPostID string) {
+	if r.Method != http.MethodGet || analytics.IsBot(r.UserAgent()) {
+		return
+	}
+	h.analytics.CountRequest(blogPostID, time.Now())
+}
+
+// withPopularPosts adds the most read blog posts of the
+// window selected by the "popular" query parameter.
+func (h *Handler) withPopularPosts(r *http.Request, b model.Base) model.Base {
+	selected := defaultPopularWindow
+	key := r.URL.Query().Get("popular")
+	for _, window := range popularWindows {
+		if window.Key == key {
+			selected = window
+		}
+	}
+
+	windows := []model.PopularWindow{}
+	for _, window := range popularWindows {
+		windows = append(windows, model.PopularWindow{
+			Label:  window.Label,
+			URL:    fmt.Sprintf("%s?popular=%s#popular", r.URL.Path, window.Key),
+			Active: window.Key == selected.Key,
+		})
+	}
+
+	return b.WithPopularPosts(
+		h.blogPosts,
+		h.analytics.PostRequests(selected.since(time.Now())),
+		windows,
+		popularPostsLimit)
+}
+
+// popularETag combines an ETag with the ranking of the most read
+// blog posts, so that cached pages get invalidated when it changes.
+func popularETag(eTag string, b model.Base) string {
+	if b.PopularPosts == nil {
+		return eTag
+	}
+	hash := fnv.New32a()
+	for _, post := range b.PopularPosts.BlogPosts {
+		hash.Write([]byte(post.ID))
+		hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s-%x", eTag, hash.Sum32())
+}
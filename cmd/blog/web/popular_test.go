@@ -0,0 +1,156 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustedcodes/blog/cmd/blog/model"
+	"github.com/dustedcodes/blog/internal/analytics"
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+func TestPopularWindowSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 1, 30, 0, 0, time.FixedZone("CET", 60*60))
+	tests := []struct {
+		key      string
+		expected time.Time
+	}{
+		// Windows include today and start on the same
+		// time of the day, which is in UTC:
+		{"7d", time.Date(2024, 3, 4, 0, 30, 0, 0, time.UTC)},
+		{"30d", time.Date(2024, 2, 10, 0, 30, 0, 0, time.UTC)},
+		{"all", time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			for _, window := range popularWindows {
+				if window.Key != test.key {
+					continue
+				}
+				if actual := window.since(now); !actual.Equal(test.expected) {
+					t.Errorf("expected %s, got %s", test.expected, actual)
+				}
+				return
+			}
+			t.Fatalf("expected a window %s", test.key)
+		})
+	}
+}
+
+func TestWithPopularPosts(t *testing.T) {
+	store, err := analytics.Open(filepath.Join(t.TempDir(), "analytics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+	tracker, err := analytics.NewTracker(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(days int) time.Time {
+		return time.Date(2024, 1, 1+days, 0, 0, 0, 0, time.UTC)
+	}
+	blogPosts := []*blog.Post{
+		{ID: "p7", PublishDate: day(7)},
+		{ID: "p6", PublishDate: day(6)},
+		{ID: "p5", PublishDate: day(5)},
+		{ID: "p4", PublishDate: day(4)},
+		{ID: "p3", PublishDate: day(3)},
+		{ID: "p2", PublishDate: day(2)},
+		{ID: "p1", PublishDate: day(1)},
+		{ID: "unread", PublishDate: day(0)},
+	}
+	now := time.Now()
+	count := func(postID string, daysAgo int, requests int) {
+		for range requests {
+			tracker.CountRequest(postID, now.AddDate(0, 0, -daysAgo))
+		}
+	}
+	count("p1", 0, 3)
+	count("p2", 6, 2)
+	count("p3", 7, 4)
+	count("p4", 29, 1)
+	count("p5", 30, 9)
+	count("p6", 1, 1)
+	count("p7", 2, 1)
+
+	h := &Handler{blogPosts: blogPosts, analytics: tracker}
+	tests := []struct {
+		query    string
+		expected string
+		active   string
+	}{
+		// Ties are ranked by the newest blog post:
+		{"?popular=7d", "p1 p2 p7 p6", "7 days"},
+		{"?popular=30d", "p3 p1 p2 p7 p6", "30 days"},
+		{"", "p3 p1 p2 p7 p6", "30 days"},
+		{"?popular=unknown", "p3 p1 p2 p7 p6", "30 days"},
+		{"?popular=all", "p5 p3 p1 p2 p7", "All time"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/about"+test.query, nil)
+			b := h.withPopularPosts(r, model.Base{URLs: &model.URLs{BaseURL: "https://dusted.codes"}})
+
+			ids := []string{}
+			for _, post := range b.PopularPosts.BlogPosts {
+				ids = append(ids, post.ID)
+			}
+			if actual := strings.Join(ids, " "); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+			for _, window := range b.PopularPosts.Windows {
+				if window.Active != (window.Label == test.active) {
+					t.Errorf("expected %s to be the active window, got %s", test.active, window.Label)
+				}
+			}
+			if url := b.PopularPosts.Windows[0].URL; url != "/about?popular=7d#popular" {
+				t.Errorf("expected /about?popular=7d#popular, got %s", url)
+			}
+		})
+	}
+}
+
+func TestPopularETag(t *testing.T) {
+	popular := func(ids ...string) model.Base {
+		b := model.Base{PopularPosts: &model.PopularPosts{}}
+		for _, id := range ids {
+			b.PopularPosts.BlogPosts = append(b.PopularPosts.BlogPosts, model.BlogPostLink{ID: id})
+		}
+		return b
+	}
+
+	if eTag := popularETag("v1", model.Base{}); eTag != "v1" {
+		t.Errorf("expected v1 without popular blog posts, got %s", eTag)
+	}
+	eTag := popularETag("v1", popular("a", "b"))
+	if !strings.HasPrefix(eTag, "v1-") {
+		t.Errorf("expected an ETag starting with v1-, got %s", eTag)
+	}
+	if other := popularETag("v1", popular("a", "b")); other != eTag {
+		t.Errorf("expected the same ETag %s for the same ranking, got %s", eTag, other)
+	}
+	for _, b := range []model.Base{
+		popular("b", "a"),
+		popular("a"),
+		popular("a", "b", "c"),
+		popular("ab"),
+		popular(),
+	} {
+		if other := popularETag("v1", b); other == eTag {
+			t.Errorf("expected a different ETag for the ranking %v", b.PopularPosts.BlogPosts)
+		}
+	}
+	if other := popularETag("v2", popular("a", "b")); other == eTag {
+		t.Error("expected a different ETag for another version")
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/dusted-go/http/v6/rss"
 	"github.com/dusted-go/http/v6/sitemap"
 
+	"github.com/dustedcodes/blog/cmd/blog/model"
 	"github.com/dustedcodes/blog/internal/blog"
+	"github.com/dustedcodes/blog/internal/comments"
 )
 
 func (h *Handler) panic(
@@ -46,8 +48,10 @@ func (h *Handler) index(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	model := h.newBaseModel(r).Empty()
-	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	base := h.newBaseModel(r)
+	model := h.withPopularPosts(r, base.WithStructuredData(base.WebSite())).Empty()
+	h.setCacheDirective(w, 60*60, popularETag(h.config.ApplicationVersion, model.Base))
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "index", model)
 }
 
@@ -55,52 +59,132 @@ func (h *Handler) blog(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	model := h.newBaseModel(r).Blog(h.blogPosts)
-	h.setCacheDirective(w, 60*60, h.config.ApplicationVersion)
+	model := h.withPopularPosts(r, h.newBaseModel(r)).Blog(h.blogPosts, h.commentCounts(r), h.analytics.PostViews(time.Time{}))
+	h.setCacheDirective(w, 60*60, popularETag(h.revisionETag(h.config.ApplicationVersion), model.Base))
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "blog", model)
 }
 
 func (h *Handler) tagged(
 	w http.ResponseWriter,
 	r *http.Request,
-	tagName string,
 ) {
+	tagName := r.PathValue("tag")
 	filtered := []*blog.Post{}
 	for _, b := range h.blogPosts {
 		if slices.Contains(b.Tags, tagName) {
 			filtered = append(filtered, b)
 		}
 	}
-	model := h.newBaseModel(r).WithTitle(fmt.Sprintf("Tagged with '%s'", tagName)).Tagged(filtered)
-	h.setCacheDirective(w, 60*60*4, h.config.ApplicationVersion)
+	model := h.newBaseModel(r).WithTitle(fmt.Sprintf("Tagged with '%s'", tagName)).Tagged(tagName, filtered, h.commentCounts(r), h.analytics.PostViews(time.Time{}))
+	h.setCacheDirective(w, 60*60*4, h.revisionETag(h.config.ApplicationVersion))
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "tagged", model)
 }
 
+// matchesQuery reports whether every search term
+// appears in the title or tags of a blog post.
+func matchesQuery(blogPost *blog.Post, terms []string) bool {
+	text := strings.ToLower(blogPost.Title + " " + strings.Join(blogPost.Tags, " "))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) search(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	terms := strings.Fields(strings.ToLower(query))
+	results := []*blog.Post{}
+	if len(terms) > 0 {
+		for _, b := range h.blogPosts {
+			if matchesQuery(b, terms) {
+				results = append(results, b)
+			}
+		}
+	}
+	title := "Search"
+	if len(query) > 0 {
+		title = fmt.Sprintf("Search results for '%s'", query)
+	}
+	// Search results are thin content, but link to blog posts:
+	model := h.newBaseModel(r).WithTitle(title).WithRobots("noindex, follow").Search(query, results, h.commentCounts(r), h.analytics.PostViews(time.Time{}))
+	h.setCacheDirective(w, 60*60, h.revisionETag(h.config.ApplicationVersion))
+	h.setLastModified(w, h.startedAt)
+	h.renderView(w, r, 200, "search", model)
+}
+
 func (h *Handler) renderBlogPost(
 	w http.ResponseWriter,
 	r *http.Request,
+	statusCode int,
 	blogPost *blog.Post,
+	commentForm model.CommentForm,
 ) {
+	// Blog posts which are read from disk on every request
+	// haven't been assigned a generated image yet:
+	// ---
+	err := h.ogImages.Assign(blogPost, h.getURLs(r).BlogPostImageURL(blogPost.ID))
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	// Load approved comments and verified webmentions:
+	// ---
+	approved, err := h.comments.List(blogPost.ID, comments.StatusApproved)
+	if h.handleErr(w, r, err) {
+		return
+	}
+	mentions, err := h.mentions.Verified(blogPost.ID)
+	if h.handleErr(w, r, err) {
+		return
+	}
+
 	// Respond with view:
 	// ---
 	model := h.
 		newBaseModel(r).
 		WithTitle(blogPost.Title).
 		WithOpenGraphImage(blogPost.OpenGraphImage).
-		BlogPost(blogPost.ID, blogPost.HTML, blogPost.PublishDate, blogPost.Tags)
-	h.setCacheDirective(w, 60*60*4, blogPost.HashCode)
+		WithDescription(blogPost.Description).
+		WithCanonical(blogPost.Canonical).
+		WithRobots(blogPost.Robots).
+		BlogPost(blogPost.ID, blogPost.HTML, blogPost.PublishDate, blogPost.LastModified(), blogPost.Tags).
+		WithComments(approved).
+		WithCommentForm(commentForm).
+		WithMentions(mentions).
+		WithViewCount(h.analytics.PostViews(time.Time{})[blogPost.ID])
+	if statusCode == http.StatusOK {
+		h.setCacheDirective(w, 60*60*4, h.revisionETag(blogPost.HashCode))
+		h.setLastModified(w, blogPost.LastModified())
+	}
 	h.renderView(
-		w, r, 200, "blogPost", model)
+		w, r, statusCode, "blogPost", model)
+}
+
+// findBlogPost returns a published blog post by its ID.
+func (h *Handler) findBlogPost(blogPostID string) (*blog.Post, bool) {
+	for _, blogPost := range h.blogPosts {
+		if blogPost.ID == blogPostID {
+			return blogPost, true
+		}
+	}
+	return nil, false
 }
 
 func (h *Handler) blogPost(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	blogPostID := strings.TrimPrefix(r.URL.Path, "/")
+	blogPostID := r.PathValue("post")
 
 	if !h.config.IsProduction() {
-		blogPost, err := blog.ReadPost(r.Context(), blog.DefaultBlogPostPath, blogPostID)
+		blogPost, err := blog.ReadPost(r.Context(), blog.DefaultBlogPostPath, blogPostID, h.config.BlogOptions())
 		if errors.Is(err, blog.ErrBlogPostNotFound) {
 			h.notFound(w, r)
 			return
@@ -109,15 +193,35 @@ func (h *Handler) blogPost(
 		if h.handleErr(w, r, err) {
 			return
 		}
+		if blogPost != nil && blogPost.Retired {
+			h.gone(w, r, blogPost)
+			return
+		}
 		if blogPost != nil {
-			h.renderBlogPost(w, r, blogPost)
+			_, err = blog.DetectImages(r.Context(), []*blog.Post{blogPost}, h.config.CDN, h.config.CDNMirrorPath)
+			if h.handleErr(w, r, err) {
+				return
+			}
+			imageManifest, err := blog.LoadImageManifest(blog.DefaultImageManifestPath)
+			if h.handleErr(w, r, err) {
+				return
+			}
+			blog.UseResponsiveImages(blogPost, imageManifest, h.config.CDN)
+			h.countRequest(r, blogPost.ID)
+			h.renderBlogPost(w, r, http.StatusOK, blogPost, h.commentFormFromQuery(r))
 			return
 		}
 	}
 
-	for _, blogPost := range h.blogPosts {
+	if blogPost, ok := h.findBlogPost(blogPostID); ok {
+		h.countRequest(r, blogPost.ID)
+		h.renderBlogPost(w, r, http.StatusOK, blogPost, h.commentFormFromQuery(r))
+		return
+	}
+
+	for _, blogPost := range h.retiredPosts {
 		if blogPost.ID == blogPostID {
-			h.renderBlogPost(w, r, blogPost)
+			h.gone(w, r, blogPost)
 			return
 		}
 	}
@@ -130,6 +234,7 @@ func (h *Handler) products(
 	r *http.Request,
 ) {
 	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "products", h.newBaseModel(r).WithTitle("Products").Empty())
 }
 
@@ -138,6 +243,7 @@ func (h *Handler) oss(
 	r *http.Request,
 ) {
 	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "oss", h.newBaseModel(r).WithTitle("Open Source").Empty())
 }
 
@@ -146,6 +252,7 @@ func (h *Handler) hire(
 	r *http.Request,
 ) {
 	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "hire", h.newBaseModel(r).WithTitle("Hire").Empty())
 }
 
@@ -154,6 +261,7 @@ func (h *Handler) about(
 	r *http.Request,
 ) {
 	h.setCacheDirective(w, 60*60*24, h.config.ApplicationVersion)
+	h.setLastModified(w, h.startedAt)
 	h.renderView(w, r, 200, "about", h.newBaseModel(r).WithTitle("About").Empty())
 }
 
@@ -161,6 +269,10 @@ func (h *Handler) rss(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	h.writeFeed(w, r, "application/rss+xml", h.buildRSSFeed)
+}
+
+func (h *Handler) buildRSSFeed(r *http.Request) ([]byte, error) {
 	urls := h.getURLs(r)
 	latestPost := h.blogPosts[0]
 	rssFeed := rss.NewFeed(
@@ -191,7 +303,7 @@ func (h *Handler) rss(
 			SetPubDate(blogPost.PublishDate, time.UTC).
 			SetAuthor("dustin@dusted.codes", "Dustin Moris Gorski").
 			SetComments(comments).
-			SetDescription(string(blogPost.HTML)).
+			SetDescription(string(blog.StripHeadingAnchors(blog.InlineSyntaxStyles(blogPost.HTML)))).
 			SetEnclosure(ogImage.URL, ogImage.Size, ogImage.MimeType)
 		for _, t := range blogPost.Tags {
 			rssItem.AddCategory(t, urls.TagURL(t))
@@ -199,20 +311,17 @@ func (h *Handler) rss(
 		rssFeed.Channel.AddItem(rssItem)
 	}
 
-	bytes, err := rssFeed.ToXML(true, true)
-	if h.handleErr(w, r, err) {
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/rss+xml")
-	_, _ = w.Write(bytes)
+	return rssFeed.ToXML(true, true)
 }
 
 func (h *Handler) atom(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	h.writeFeed(w, r, "application/atom+xml", h.buildAtomFeed)
+}
+
+func (h *Handler) buildAtomFeed(r *http.Request) ([]byte, error) {
 	urls := h.getURLs(r)
 	latestPost := h.blogPosts[0]
 	author := atom.NewPerson(
@@ -241,13 +350,13 @@ func (h *Handler) atom(
 		entry := atom.NewEntry(
 			permalink,
 			atom.NewText(blogPost.Title),
-			blogPost.PublishDate).
+			blogPost.LastModified()).
 			SetAuthor(author).
 			AddLink(atom.NewLink(permalink).SetRel("alternate")).
 			AddLink(atom.NewLink(urls.BlogPostCommentsURL(blogPost.ID)).SetRel("related")).
 			AddLink(atom.NewLink(ogImage.URL).SetRel("enclosure").SetLength(ogImage.Size)).
 			SetPublished(blogPost.PublishDate).
-			SetContent(atom.NewHTML(string(blogPost.HTML)))
+			SetContent(atom.NewHTML(string(blog.StripHeadingAnchors(blog.InlineSyntaxStyles(blogPost.HTML)))))
 
 		for _, t := range blogPost.Tags {
 			entry.AddCategory(atom.NewCategory(t).
@@ -257,20 +366,17 @@ func (h *Handler) atom(
 		atomFeed.AddEntry(entry)
 	}
 
-	bytes, err := atomFeed.ToXML(true, true)
-	if h.handleErr(w, r, err) {
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/atom+xml")
-	_, _ = w.Write(bytes)
+	return atomFeed.ToXML(true, true)
 }
 
 func (h *Handler) sitemap(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	h.writeFeed(w, r, "application/xml; charset=UTF-8", h.buildSitemap)
+}
+
+func (h *Handler) buildSitemap(r *http.Request) ([]byte, error) {
 	urls := h.getURLs(r)
 	urlset := sitemap.NewURLSet().
 		AddURL(
@@ -305,17 +411,10 @@ func (h *Handler) sitemap(
 				NewURL(urls.BlogPostURL(blogPost.ID)).
 				SetPriority("0.9").
 				SetChangeFreq("monthly").
-				SetLastMod(blogPost.PublishDate))
-	}
-
-	bytes, err := urlset.ToXML(true, true)
-	if h.handleErr(w, r, err) {
-		return
+				SetLastMod(blogPost.LastModified()))
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/xml; charset=UTF-8")
-	_, _ = w.Write(bytes)
+	return urlset.ToXML(true, true)
 }
 
 func (h *Handler) robots(
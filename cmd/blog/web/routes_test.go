@@ -0,0 +1,191 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// structuredData returns the JSON-LD scripts of a page.
+func structuredData(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	data := []map[string]any{}
+	z := html.NewTokenizer(strings.NewReader(body))
+	inScript := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				t.Fatal(z.Err())
+			}
+			return data
+		case html.StartTagToken:
+			token := z.Token()
+			inScript = false
+			if token.DataAtom == atom.Script {
+				for _, attr := range token.Attr {
+					if attr.Key == "type" && attr.Val == "application/ld+json" {
+						inScript = true
+					}
+				}
+			}
+		case html.TextToken:
+			if !inScript {
+				continue
+			}
+			var value map[string]any
+			err := json.Unmarshal(z.Text(), &value)
+			if err != nil {
+				t.Fatalf("expected valid JSON-LD, got %v: %s", err, z.Text())
+			}
+			data = append(data, value)
+		default:
+			inScript = false
+		}
+	}
+}
+
+func TestStructuredDataOfBlogPosts(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	title := `Closing </script><script>alert("x")</script> tags & "quotes"`
+	blogPost := newTestPost("escaping", title, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "html")
+	blogPost.HTML = "<p>Escaping</p>"
+	h := newTestHandler(t, blogPost)
+
+	w := get(t, h, "/escaping")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if strings.Contains(w.Body.String(), `<script>alert("x")`) {
+		t.Error("expected the title to be escaped")
+	}
+
+	data := structuredData(t, w.Body.String())
+	types := []string{}
+	for _, value := range data {
+		types = append(types, value["@type"].(string))
+	}
+	if actual := strings.Join(types, " "); actual != "BlogPosting BreadcrumbList" {
+		t.Fatalf("expected BlogPosting BreadcrumbList, got %s", actual)
+	}
+	posting := data[0]
+	if posting["headline"] != title {
+		t.Errorf("expected headline %s, got %v", title, posting["headline"])
+	}
+	if posting["url"] != "https://dusted.codes/escaping" {
+		t.Errorf("expected url https://dusted.codes/escaping, got %v", posting["url"])
+	}
+	if posting["datePublished"] != "2024-01-01T00:00:00Z" {
+		t.Errorf("expected datePublished 2024-01-01T00:00:00Z, got %v", posting["datePublished"])
+	}
+	if posting["keywords"] != "html" {
+		t.Errorf("expected keywords html, got %v", posting["keywords"])
+	}
+	items := data[1]["itemListElement"].([]any)
+	if last := items[len(items)-1].(map[string]any); last["name"] != title {
+		t.Errorf("expected breadcrumb %s, got %v", title, last["name"])
+	}
+}
+
+func TestStructuredDataOfWebSite(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := newTestHandler(t, newTestPost("hello-world", "Hello World", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	w := get(t, h, "/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	data := structuredData(t, w.Body.String())
+	if len(data) != 1 || data[0]["@type"] != "WebSite" {
+		t.Fatalf("expected WebSite, got %v", data)
+	}
+	action := data[0]["potentialAction"].(map[string]any)
+	target := action["target"].(map[string]any)
+	if target["urlTemplate"] != "https://dusted.codes/search?q={search_term_string}" {
+		t.Errorf("expected the URL template of the search, got %v", target["urlTemplate"])
+	}
+}
+
+func TestMetaTags(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	published := newTestPost("hello-world", "Hello World", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "golang")
+	retired := newTestPost("old-post", "Old post", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	retired.Retired = true
+	h := newTestHandler(t, published, retired)
+
+	tests := []struct {
+		path       string
+		statusCode int
+		robots     string
+		contains   []string
+	}{
+		{
+			path:       "/hello-world",
+			statusCode: http.StatusOK,
+			contains: []string{
+				`<link rel="canonical" href="https://dusted.codes/hello-world">`,
+				`<meta property="og:type" content="article" />`,
+				`<meta property="article:published_time" content="2024-01-01T00:00:00Z" />`,
+				`<meta property="article:tag" content="golang" />`,
+			},
+		},
+		{
+			path:       "/blog?popular=7d",
+			statusCode: http.StatusOK,
+			contains: []string{
+				`<link rel="canonical" href="https://dusted.codes/blog">`,
+				`<meta property="og:type" content="website" />`,
+			},
+		},
+		{
+			path:       "/search?q=hello",
+			statusCode: http.StatusOK,
+			robots:     "noindex, follow",
+		},
+		{
+			path:       "/unknown-post",
+			statusCode: http.StatusNotFound,
+			robots:     "noindex",
+		},
+		{
+			path:       "/old-post",
+			statusCode: http.StatusGone,
+			robots:     "noindex",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := get(t, h, test.path)
+			if w.Code != test.statusCode {
+				t.Fatalf("expected status %d, got %d", test.statusCode, w.Code)
+			}
+			body := w.Body.String()
+			if len(test.robots) == 0 {
+				if strings.Contains(body, `<meta name="robots"`) {
+					t.Error("expected no robots meta tag")
+				}
+			} else {
+				if !strings.Contains(body, `<meta name="robots" content="`+test.robots+`">`) {
+					t.Errorf("expected the robots meta tag %s", test.robots)
+				}
+				if strings.Contains(body, `rel="canonical"`) {
+					t.Error("expected no canonical link of a page which isn't indexed")
+				}
+			}
+			for _, s := range test.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected %s", s)
+				}
+			}
+		})
+	}
+}
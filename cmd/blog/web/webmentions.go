@@ -0,0 +1,88 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/webmention"
+)
+
+const (
+	mentionsPerIP     = 10
+	mentionsRateLimit = time.Hour
+)
+
+// blogPostIDFromURL returns the ID of the published blog post
+// which an absolute URL on this site points to.
+func (h *Handler) blogPostIDFromURL(target string) (string, bool) {
+	prefix := h.config.BaseURL + "/"
+	if !strings.HasPrefix(target, prefix) {
+		return "", false
+	}
+	blogPostID := strings.TrimPrefix(target, prefix)
+	blogPostID, _, _ = strings.Cut(blogPostID, "#")
+	blogPostID, _, _ = strings.Cut(blogPostID, "?")
+	blogPost, ok := h.findBlogPost(strings.TrimSuffix(blogPostID, "/"))
+	if !ok {
+		return "", false
+	}
+	return blogPost.ID, true
+}
+
+func (h *Handler) receiveWebmention(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !h.mentionLimiter.Allow(comments.ClientIP(r.RemoteAddr), time.Now()) {
+		h.writeText(w, r,
+			http.StatusTooManyRequests,
+			"You have sent too many webmentions. Please try again later.")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, "The submitted form could not be read.")
+		return
+	}
+
+	source := strings.TrimSpace(r.PostForm.Get("source"))
+	target := strings.TrimSpace(r.PostForm.Get("target"))
+
+	err = webmention.Validate(source, target)
+	if err != nil {
+		h.writeText(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	blogPostID, ok := h.blogPostIDFromURL(target)
+	if !ok {
+		h.writeText(w, r, http.StatusBadRequest, "The target is not a blog post on this site.")
+		return
+	}
+
+	err = h.mentionReceiver.Accept(source, target, blogPostID)
+	if errors.Is(err, webmention.ErrInvalidMention) {
+		h.writeText(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, webmention.ErrTooManyMentions) {
+		h.writeText(w, r, http.StatusTooManyRequests, "Too many webmentions from this source are awaiting verification.")
+		return
+	}
+	if h.handleErr(w, r, err) {
+		return
+	}
+
+	slogctx.GetLogger(r.Context()).Info(
+		"Received webmention.",
+		"source", source,
+		"target", target)
+
+	h.writeText(w, r, http.StatusAccepted, "The webmention has been queued for verification.")
+}
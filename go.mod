@@ -9,6 +9,9 @@ require (
 	github.com/dusted-go/logging/v2 v2.0.0-rc-04
 	github.com/yuin/goldmark v1.7.13
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/image v0.45.0
+	golang.org/x/net v0.57.0
 )
 
 require (
@@ -18,4 +21,6 @@ require (
 	github.com/tdewolff/parse v2.3.4+incompatible // indirect
 	go.opentelemetry.io/otel v1.38.0 // indirect
 	go.opentelemetry.io/otel/trace v1.38.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
 )
@@ -0,0 +1,591 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+const (
+	maxResponseSize     = 1 << 20
+	maxDeliveryAttempts = 5
+)
+
+var (
+	ErrUnsupportedActivity = errors.New("unsupported activity")
+)
+
+// Settings describe the actor which represents the blog.
+type Settings struct {
+	BaseURL  string
+	Host     string
+	Username string
+	Name     string
+	Summary  string
+	IconURL  string
+}
+
+// Service implements a minimal ActivityPub server for a single actor,
+// which publishes blog posts as articles and accepts followers.
+type Service struct {
+	settings     Settings
+	key          *rsa.PrivateKey
+	publicKeyPem string
+	store        *Store
+	client       *http.Client
+	userAgent    string
+
+	keysMu sync.Mutex
+	keys   map[string]*rsa.PublicKey
+}
+
+func NewService(
+	settings Settings,
+	key *rsa.PrivateKey,
+	store *Store,
+	client *http.Client,
+	userAgent string,
+) (*Service, error) {
+	publicKeyPem, err := encodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		settings:     settings,
+		key:          key,
+		publicKeyPem: publicKeyPem,
+		store:        store,
+		client:       client,
+		userAgent:    userAgent,
+		keys:         map[string]*rsa.PublicKey{},
+	}, nil
+}
+
+func (s *Service) ActorURL() string {
+	return s.settings.BaseURL + "/activitypub/actor"
+}
+
+func (s *Service) keyID() string {
+	return s.ActorURL() + "#main-key"
+}
+
+func (s *Service) InboxURL() string {
+	return s.settings.BaseURL + "/activitypub/inbox"
+}
+
+func (s *Service) OutboxURL() string {
+	return s.settings.BaseURL + "/activitypub/outbox"
+}
+
+func (s *Service) FollowersURL() string {
+	return s.settings.BaseURL + "/activitypub/followers"
+}
+
+func (s *Service) account() string {
+	return fmt.Sprintf("acct:%s@%s", s.settings.Username, s.settings.Host)
+}
+
+func (s *Service) Actor() *Actor {
+	actor := &Actor{
+		Context:           []string{activityStreamsContext, securityContext},
+		ID:                s.ActorURL(),
+		Type:              "Service",
+		PreferredUsername: s.settings.Username,
+		Name:              s.settings.Name,
+		Summary:           s.settings.Summary,
+		URL:               s.settings.BaseURL,
+		Inbox:             s.InboxURL(),
+		Outbox:            s.OutboxURL(),
+		Followers:         s.FollowersURL(),
+		PublicKey: &PublicKey{
+			ID:           s.keyID(),
+			Owner:        s.ActorURL(),
+			PublicKeyPem: s.publicKeyPem,
+		},
+	}
+	if len(s.settings.IconURL) > 0 {
+		actor.Icon = &Image{Type: "Image", URL: s.settings.IconURL}
+	}
+	return actor
+}
+
+// WebFinger resolves the blog's account or actor URL.
+func (s *Service) WebFinger(resource string) (*WebFinger, bool) {
+	if !strings.EqualFold(resource, s.account()) &&
+		resource != s.ActorURL() &&
+		resource != s.settings.BaseURL {
+		return nil, false
+	}
+	return &WebFinger{
+		Subject: s.account(),
+		Aliases: []string{s.ActorURL(), s.settings.BaseURL},
+		Links: []WebFingerLink{
+			{Rel: "self", Type: ContentType, Href: s.ActorURL()},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: s.settings.BaseURL},
+		},
+	}, true
+}
+
+func (s *Service) article(post *blog.Post) *Article {
+	permalink := s.settings.BaseURL + "/" + post.ID
+	tags := []Tag{}
+	for _, t := range post.Tags {
+		tags = append(tags, Tag{
+			Type: "Hashtag",
+			Href: s.settings.BaseURL + "/tagged/" + t,
+			Name: "#" + t,
+		})
+	}
+	article := &Article{
+		ID:           permalink,
+		Type:         "Article",
+		Name:         post.Title,
+		Content:      string(blog.StripHeadingAnchors(blog.InlineSyntaxStyles(post.HTML))),
+		URL:          permalink,
+		AttributedTo: s.ActorURL(),
+		Published:    post.PublishDate.UTC(),
+		To:           []string{Public},
+		CC:           []string{s.FollowersURL()},
+		Tag:          tags,
+	}
+	if post.OpenGraphImage.Complete() {
+		article.Image = &Image{
+			Type:      "Image",
+			MediaType: post.OpenGraphImage.MimeType,
+			URL:       post.OpenGraphImage.URL,
+		}
+	}
+	return article
+}
+
+func (s *Service) create(post *blog.Post) *Activity {
+	article := s.article(post)
+	return &Activity{
+		ID:        article.ID + "#create",
+		Type:      "Create",
+		Actor:     s.ActorURL(),
+		Published: article.Published,
+		To:        article.To,
+		CC:        article.CC,
+		Object:    article,
+	}
+}
+
+// Outbox lists all blog posts as Create activities.
+func (s *Service) Outbox(posts []*blog.Post) *OrderedCollection {
+	items := make([]any, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, s.create(post))
+	}
+	return &OrderedCollection{
+		Context:      activityStreamsContext,
+		ID:           s.OutboxURL(),
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	}
+}
+
+// FollowersCollection only exposes the number of followers.
+func (s *Service) FollowersCollection() (*OrderedCollection, error) {
+	followers, err := s.store.Followers()
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedCollection{
+		Context:    activityStreamsContext,
+		ID:         s.FollowersURL(),
+		Type:       "OrderedCollection",
+		TotalItems: len(followers),
+	}, nil
+}
+
+// checkRemoteURL only allows https URLs of other servers,
+// because they come from third parties.
+func checkRemoteURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL '%s': %w", rawURL, err)
+	}
+	if u.Scheme != "https" || len(u.Hostname()) == 0 || u.User != nil {
+		return fmt.Errorf("URL '%s' is not an https URL", rawURL)
+	}
+	return nil
+}
+
+func (s *Service) get(ctx context.Context, rawURL string, v any) error {
+	err := checkRemoteURL(rawURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", ContentType+", "+ldContentType)
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching '%s': %w", rawURL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching '%s' responded with status code %d", rawURL, resp.StatusCode)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+	if err != nil {
+		return fmt.Errorf("error decoding '%s': %w", rawURL, err)
+	}
+	return nil
+}
+
+func (s *Service) fetchActor(ctx context.Context, actorID string) (*Actor, error) {
+	actor := &Actor{}
+	err := s.get(ctx, actorID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if actor.ID != actorID || len(actor.Inbox) == 0 {
+		return nil, fmt.Errorf("invalid actor document at '%s'", actorID)
+	}
+	return actor, nil
+}
+
+func keyOwner(keyID string) string {
+	owner, _, _ := strings.Cut(keyID, "#")
+	return owner
+}
+
+// fetchPublicKey resolves a signature's key ID to the public key of an actor.
+// Keys are cached for the lifetime of the process.
+func (s *Service) fetchPublicKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	s.keysMu.Lock()
+	key, ok := s.keys[keyID]
+	s.keysMu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	actor, err := s.fetchActor(ctx, keyOwner(keyID))
+	if err != nil {
+		return nil, err
+	}
+	if actor.PublicKey == nil || actor.PublicKey.ID != keyID {
+		return nil, fmt.Errorf("actor '%s' does not publish the key '%s'", actor.ID, keyID)
+	}
+	key, err = decodePublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keysMu.Lock()
+	s.keys[keyID] = key
+	s.keysMu.Unlock()
+	return key, nil
+}
+
+// forgetPublicKey removes a key from the cache and
+// reports whether it had been cached before.
+func (s *Service) forgetPublicKey(keyID string) bool {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	_, ok := s.keys[keyID]
+	delete(s.keys, keyID)
+	return ok
+}
+
+func (s *Service) post(ctx context.Context, inbox string, activity *Activity) error {
+	activity.Context = activityStreamsContext
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("error serialising activity: %w", err)
+	}
+	err = checkRemoteURL(inbox)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", s.userAgent)
+
+	err = Sign(req, body, s.keyID(), s.key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error delivering activity to '%s': %w", inbox, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("inbox '%s' responded with status code %d", inbox, resp.StatusCode)
+	}
+	return nil
+}
+
+// objectID returns the ID of an activity's object,
+// which is either a plain string or an embedded object.
+func objectID(object any) string {
+	switch o := object.(type) {
+	case string:
+		return o
+	case map[string]any:
+		id, _ := o["id"].(string)
+		return id
+	default:
+		return ""
+	}
+}
+
+func objectType(object any) string {
+	if o, ok := object.(map[string]any); ok {
+		t, _ := o["type"].(string)
+		return t
+	}
+	return ""
+}
+
+// HandleInbox verifies the signature of an incoming activity and processes
+// Follow and Undo Follow requests. Accept activities are delivered in the
+// background, so that the sender doesn't have to wait for them.
+func (s *Service) HandleInbox(
+	ctx context.Context,
+	logger *slog.Logger,
+	r *http.Request,
+	body []byte,
+) error {
+	activity := &IncomingActivity{}
+	err := json.Unmarshal(body, activity)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupportedActivity, err)
+	}
+
+	// Keys of other actors and keys which can't be fetched
+	// over https get rejected before fetching anything:
+	fetchKey := func(keyID string) (*rsa.PublicKey, error) {
+		if keyOwner(keyID) != activity.Actor {
+			return nil, fmt.Errorf("key '%s' does not belong to actor '%s'", keyID, activity.Actor)
+		}
+		return s.fetchPublicKey(ctx, keyID)
+	}
+	_, err = Verify(r, body, fetchKey)
+	if errors.Is(err, errSignatureMismatch) &&
+		s.forgetPublicKey(parseSignatureHeader(r.Header.Get("Signature"))["keyid"]) {
+		// The actor might have rotated its key since it has been cached:
+		_, err = Verify(r, body, fetchKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch activity.Type {
+	case "Follow":
+		if objectID(activity.Object) != s.ActorURL() {
+			return fmt.Errorf("%w: follow request for another actor", ErrUnsupportedActivity)
+		}
+		actor, err := s.fetchActor(ctx, activity.Actor)
+		if err != nil {
+			return err
+		}
+		follower := &Follower{
+			ID:         actor.ID,
+			Inbox:      actor.Inbox,
+			FollowedAt: time.Now().UTC(),
+		}
+		if actor.Endpoints != nil {
+			follower.SharedInbox = actor.Endpoints.SharedInbox
+		}
+		err = s.store.AddFollower(follower)
+		if err != nil {
+			return err
+		}
+
+		accept := &Activity{
+			ID:     fmt.Sprintf("%s#accepts/%d", s.ActorURL(), time.Now().UnixNano()),
+			Type:   "Accept",
+			Actor:  s.ActorURL(),
+			Object: activity,
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+			defer cancel()
+			if err := s.post(ctx, follower.Inbox, accept); err != nil {
+				logger.Error("Failed to accept follow request.",
+					"error", err,
+					"follower", follower.ID)
+			}
+		}()
+
+		logger.Info("New ActivityPub follower.", "follower", follower.ID)
+		return nil
+
+	case "Undo":
+		if objectType(activity.Object) != "Follow" {
+			return fmt.Errorf("%w: undo of %s", ErrUnsupportedActivity, objectType(activity.Object))
+		}
+		err = s.store.RemoveFollower(activity.Actor)
+		if err != nil {
+			return err
+		}
+		logger.Info("Lost ActivityPub follower.", "follower", activity.Actor)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedActivity, activity.Type)
+	}
+}
+
+func (f *Follower) deliveryInbox() string {
+	if len(f.SharedInbox) > 0 {
+		return f.SharedInbox
+	}
+	return f.Inbox
+}
+
+// DeliverNewPosts sends a Create activity for every blog post which hasn't
+// been delivered before to all followers. Posts which exist while nobody
+// follows the blog are only marked as delivered, so that new followers
+// don't receive the entire back catalogue. Deliveries which fail are
+// retried the next time, until they failed too often.
+func (s *Service) DeliverNewPosts(ctx context.Context, logger *slog.Logger, posts []*blog.Post) error {
+	followers, err := s.store.Followers()
+	if err != nil {
+		return err
+	}
+
+	inboxes := []string{}
+	seen := map[string]bool{}
+	for _, f := range followers {
+		inbox := f.deliveryInbox()
+		if _, err := url.Parse(inbox); err != nil || seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		inboxes = append(inboxes, inbox)
+	}
+
+	err = s.retryDeliveries(ctx, logger, posts, seen)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if s.store.Delivered(post.ID) {
+			continue
+		}
+		failed := 0
+		for _, inbox := range inboxes {
+			if err := s.post(ctx, inbox, s.create(post)); err != nil {
+				logger.Warn("Failed to deliver blog post to follower.",
+					"error", err,
+					"blogPostID", post.ID,
+					"inbox", inbox)
+				failed++
+				err = s.store.SaveRetry(&Retry{PostID: post.ID, Inbox: inbox, Attempts: 1})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err = s.store.MarkDelivered(post.ID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if len(inboxes) > 0 {
+			logger.Info("Delivered blog post to ActivityPub followers.",
+				"blogPostID", post.ID,
+				"inboxes", len(inboxes)-failed,
+				"failed", failed)
+		}
+	}
+	return nil
+}
+
+// retryDeliveries delivers blog posts to the inboxes which failed before.
+// Retries of blog posts which have been retired since and of inboxes
+// which don't belong to a follower anymore are dropped.
+func (s *Service) retryDeliveries(
+	ctx context.Context,
+	logger *slog.Logger,
+	posts []*blog.Post,
+	inboxes map[string]bool,
+) error {
+	retries, err := s.store.Retries()
+	if err != nil {
+		return err
+	}
+
+	postsByID := map[string]*blog.Post{}
+	for _, post := range posts {
+		postsByID[post.ID] = post
+	}
+
+	for _, retry := range retries {
+		post, ok := postsByID[retry.PostID]
+		if !ok || !inboxes[retry.Inbox] {
+			if err := s.store.RemoveRetry(retry.PostID, retry.Inbox); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := s.post(ctx, retry.Inbox, s.create(post))
+		if err == nil {
+			logger.Info("Delivered blog post to ActivityPub follower after a failed attempt.",
+				"blogPostID", post.ID,
+				"inbox", retry.Inbox)
+			if err := s.store.RemoveRetry(retry.PostID, retry.Inbox); err != nil {
+				return err
+			}
+			continue
+		}
+
+		retry.Attempts++
+		if retry.Attempts >= maxDeliveryAttempts {
+			logger.Warn("Giving up delivering blog post to follower.",
+				"error", err,
+				"blogPostID", post.ID,
+				"inbox", retry.Inbox,
+				"attempts", retry.Attempts)
+			if err := s.store.RemoveRetry(retry.PostID, retry.Inbox); err != nil {
+				return err
+			}
+			continue
+		}
+		logger.Warn("Failed to deliver blog post to follower again.",
+			"error", err,
+			"blogPostID", post.ID,
+			"inbox", retry.Inbox,
+			"attempts", retry.Attempts)
+		if err := s.store.SaveRetry(retry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
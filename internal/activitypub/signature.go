@@ -0,0 +1,219 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	keySize        = 2048
+	maxClockSkew   = 12 * time.Hour
+	signedHeaders  = "(request-target) host date digest"
+	signatureAlgo  = "rsa-sha256"
+	pemPrivateType = "PRIVATE KEY"
+	pemPublicType  = "PUBLIC KEY"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid HTTP signature")
+
+	// errSignatureMismatch means that the signature doesn't match the
+	// key, which is the only failure that a newer key could resolve.
+	errSignatureMismatch = errors.New("signature does not match the key")
+)
+
+// LoadOrCreateKey reads the actor's RSA private key from a PEM file
+// and generates a new one if the file doesn't exist yet.
+func LoadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	buffer, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading private key '%s': %w", path, err)
+	}
+
+	block, _ := pem.Decode(buffer)
+	if block == nil || block.Type != pemPrivateType {
+		return nil, fmt.Errorf("file '%s' does not contain a PEM encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func createKey(path string) (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("error generating private key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding private key: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for private key: %w", err)
+	}
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemPrivateType, Bytes: der}), 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error writing private key '%s': %w", path, err)
+	}
+	return key, nil
+}
+
+func encodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("error encoding public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: pemPublicType, Bytes: der})), nil
+}
+
+func decodePublicKey(value string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s",
+				strings.ToLower(r.Method), r.URL.RequestURI()))
+		case "host":
+			lines = append(lines, "host: "+r.Host)
+		default:
+			lines = append(lines, h+": "+r.Header.Get(h))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Sign adds a Digest, a Date and a Signature header to an outgoing request,
+// following the draft-cavage HTTP signatures which Mastodon expects.
+func Sign(r *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	if len(r.Host) == 0 {
+		r.Host = r.URL.Host
+	}
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("Digest", digest(body))
+
+	hash := sha256.Sum256([]byte(signingString(r, strings.Fields(signedHeaders))))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return fmt.Errorf("error signing request: %w", err)
+	}
+
+	r.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="%s",headers="%s",signature="%s"`,
+		keyID,
+		signatureAlgo,
+		signedHeaders,
+		base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+func parseSignatureHeader(value string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(key)] = strings.Trim(val, `"`)
+	}
+	return params
+}
+
+// Verify checks the HTTP signature of an incoming request and returns
+// the signing key's ID. The signature must cover the request target,
+// host, date and digest, so that neither the body nor the destination
+// can be replayed elsewhere. The digest and date get checked before the
+// key gets fetched, so that invalid requests don't cause any requests.
+func Verify(
+	r *http.Request,
+	body []byte,
+	fetchKey func(keyID string) (*rsa.PublicKey, error),
+) (string, error) {
+	params := parseSignatureHeader(r.Header.Get("Signature"))
+	keyID := params["keyid"]
+	if len(keyID) == 0 || len(params["signature"]) == 0 {
+		return "", fmt.Errorf("%w: missing keyId or signature", ErrInvalidSignature)
+	}
+	if algorithm := params["algorithm"]; len(algorithm) > 0 && algorithm != signatureAlgo && algorithm != "hs2019" {
+		return "", fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidSignature, algorithm)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	for _, required := range strings.Fields(signedHeaders) {
+		if !slices.Contains(headers, required) {
+			return "", fmt.Errorf("%w: header '%s' is not signed", ErrInvalidSignature, required)
+		}
+	}
+
+	if r.Header.Get("Digest") != digest(body) {
+		return "", fmt.Errorf("%w: digest does not match the body", ErrInvalidSignature)
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid date header", ErrInvalidSignature)
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", fmt.Errorf("%w: date is outside of the accepted time window", ErrInvalidSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return "", fmt.Errorf("%w: signature is not base64 encoded", ErrInvalidSignature)
+	}
+
+	key, err := fetchKey(keyID)
+	if err != nil {
+		return "", fmt.Errorf("%w: error fetching public key '%s': %w", ErrInvalidSignature, keyID, err)
+	}
+
+	hash := sha256.Sum256([]byte(signingString(r, headers)))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w: %w", ErrInvalidSignature, errSignatureMismatch, err)
+	}
+	return keyID, nil
+}
@@ -0,0 +1,223 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testKeys   = map[string]*rsa.PrivateKey{}
+	testKeysMu sync.Mutex
+)
+
+// testKey returns an RSA key per name, which are
+// generated once, because generating them is slow.
+func testKey(t *testing.T, name string) *rsa.PrivateKey {
+	t.Helper()
+	testKeysMu.Lock()
+	defer testKeysMu.Unlock()
+	if key, ok := testKeys[name]; ok {
+		return key
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKeys[name] = key
+	return key
+}
+
+const testKeyID = "https://example.org/users/alice#main-key"
+
+func newSignedRequest(t *testing.T, target string, body string, key *rsa.PrivateKey) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", ContentType)
+	err := Sign(r, []byte(body), testKeyID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	key := testKey(t, "alice")
+	otherKey := testKey(t, "mallory")
+	body := `{"type":"Follow"}`
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *http.Request
+		body    string
+		key     *rsa.PrivateKey
+		err     string
+	}{
+		{
+			name: "valid signature",
+			request: func(t *testing.T) *http.Request {
+				return newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+			},
+			body: body,
+			key:  key,
+		},
+		{
+			name: "modified body",
+			request: func(t *testing.T) *http.Request {
+				return newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+			},
+			body: `{"type":"Undo"}`,
+			key:  key,
+			err:  "digest does not match the body",
+		},
+		{
+			name: "signed with another key",
+			request: func(t *testing.T) *http.Request {
+				return newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, otherKey)
+			},
+			body: body,
+			key:  key,
+			err:  "verification error",
+		},
+		{
+			name: "replayed to another path",
+			request: func(t *testing.T) *http.Request {
+				signed := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r := httptest.NewRequest(http.MethodPost, "https://dusted.codes/other/inbox", strings.NewReader(body))
+				r.Header = signed.Header
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "verification error",
+		},
+		{
+			name: "replayed to another host",
+			request: func(t *testing.T) *http.Request {
+				signed := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r := httptest.NewRequest(http.MethodPost, "https://example.com/activitypub/inbox", strings.NewReader(body))
+				r.Header = signed.Header
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "verification error",
+		},
+		{
+			name: "missing signature",
+			request: func(t *testing.T) *http.Request {
+				r := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r.Header.Del("Signature")
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "missing keyId or signature",
+		},
+		{
+			name: "digest not signed",
+			request: func(t *testing.T) *http.Request {
+				r := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), " digest", "", 1))
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "header 'digest' is not signed",
+		},
+		{
+			name: "unsupported algorithm",
+			request: func(t *testing.T) *http.Request {
+				r := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), signatureAlgo, "hmac-sha256", 1))
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "unsupported algorithm hmac-sha256",
+		},
+		{
+			name: "outdated date",
+			request: func(t *testing.T) *http.Request {
+				r := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, key)
+				r.Header.Set("Date", time.Now().Add(-maxClockSkew-time.Hour).UTC().Format(http.TimeFormat))
+				return r
+			},
+			body: body,
+			key:  key,
+			err:  "date is outside of the accepted time window",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyID, err := Verify(test.request(t), []byte(test.body), func(keyID string) (*rsa.PublicKey, error) {
+				if keyID != testKeyID {
+					t.Errorf("expected key ID '%s', got '%s'", testKeyID, keyID)
+				}
+				return &test.key.PublicKey, nil
+			})
+
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatalf("expected a valid signature, got %v", err)
+				}
+				if keyID != testKeyID {
+					t.Errorf("expected key ID '%s', got '%s'", testKeyID, keyID)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyFailsWhenTheKeyCannotBeFetched(t *testing.T) {
+	body := `{"type":"Follow"}`
+	r := newSignedRequest(t, "https://dusted.codes/activitypub/inbox", body, testKey(t, "alice"))
+	_, err := Verify(r, []byte(body), func(string) (*rsa.PublicKey, error) {
+		return nil, errors.New("actor not found")
+	})
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "actor.pem")
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.Equal(loaded) {
+		t.Error("expected the loaded key to be the created key")
+	}
+
+	pem, err := encodePublicKey(&loaded.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodePublicKey(pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(&created.PublicKey) {
+		t.Error("expected the decoded public key to be the created public key")
+	}
+}
@@ -0,0 +1,186 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultStorePath = "data/activitypub.db"
+
+	openTimeout = 3 * time.Second
+)
+
+var (
+	followersBucket = []byte("followers")
+	deliveredBucket = []byte("delivered")
+	retriesBucket   = []byte("retries")
+)
+
+type Follower struct {
+	ID          string
+	Inbox       string
+	SharedInbox string
+	FollowedAt  time.Time
+}
+
+// Retry is a delivery of a blog post to an inbox which failed
+// and gets attempted again the next time posts are delivered.
+type Retry struct {
+	PostID   string
+	Inbox    string
+	Attempts int
+}
+
+func retryKey(postID string, inbox string) []byte {
+	return []byte(postID + "\n" + inbox)
+}
+
+// Store persists followers, the blog posts which have already
+// been delivered to them and the deliveries which failed.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for ActivityPub store: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening ActivityPub store '%s': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{followersBucket, deliveredBucket, retriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error initialising ActivityPub store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) AddFollower(f *Follower) error {
+	value, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("error serialising follower: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(followersBucket).Put([]byte(f.ID), value)
+	})
+	if err != nil {
+		return fmt.Errorf("error storing follower: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveFollower(actorID string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(followersBucket).Delete([]byte(actorID))
+	})
+	if err != nil {
+		return fmt.Errorf("error removing follower: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Followers() ([]*Follower, error) {
+	followers := []*Follower{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(followersBucket).ForEach(func(_, value []byte) error {
+			f := &Follower{}
+			if err := json.Unmarshal(value, f); err != nil {
+				return fmt.Errorf("error deserialising follower: %w", err)
+			}
+			followers = append(followers, f)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing followers: %w", err)
+	}
+	sort.Slice(followers, func(i, j int) bool {
+		return followers[i].FollowedAt.Before(followers[j].FollowedAt)
+	})
+	return followers, nil
+}
+
+func (s *Store) Delivered(postID string) bool {
+	found := false
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(deliveredBucket).Get([]byte(postID)) != nil
+		return nil
+	})
+	return found
+}
+
+func (s *Store) MarkDelivered(postID string, deliveredAt time.Time) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveredBucket).Put([]byte(postID), []byte(deliveredAt.Format(time.RFC3339)))
+	})
+	if err != nil {
+		return fmt.Errorf("error marking blog post as delivered: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) SaveRetry(r *Retry) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error serialising delivery retry: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(retriesBucket).Put(retryKey(r.PostID, r.Inbox), value)
+	})
+	if err != nil {
+		return fmt.Errorf("error storing delivery retry: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveRetry(postID string, inbox string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(retriesBucket).Delete(retryKey(postID, inbox))
+	})
+	if err != nil {
+		return fmt.Errorf("error removing delivery retry: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Retries() ([]*Retry, error) {
+	retries := []*Retry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(retriesBucket).ForEach(func(_, value []byte) error {
+			r := &Retry{}
+			if err := json.Unmarshal(value, r); err != nil {
+				return fmt.Errorf("error deserialising delivery retry: %w", err)
+			}
+			retries = append(retries, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing delivery retries: %w", err)
+	}
+	return retries, nil
+}
@@ -0,0 +1,108 @@
+package activitypub
+
+import "time"
+
+const (
+	ContentType   = "application/activity+json"
+	JRDType       = "application/jrd+json"
+	ldContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	Public        = "https://www.w3.org/ns/activitystreams#Public"
+
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	securityContext        = "https://w3id.org/security/v1"
+)
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Image struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType,omitempty"`
+	URL       string `json:"url"`
+}
+
+type Actor struct {
+	Context           []string   `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Icon              *Image     `json:"icon,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	PublicKey         *PublicKey `json:"publicKey,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Tag struct {
+	Type string `json:"type"`
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+type Article struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	Name         string    `json:"name"`
+	Content      string    `json:"content"`
+	URL          string    `json:"url"`
+	AttributedTo string    `json:"attributedTo"`
+	Published    time.Time `json:"published"`
+	To           []string  `json:"to"`
+	CC           []string  `json:"cc"`
+	Tag          []Tag     `json:"tag,omitempty"`
+	Image        *Image    `json:"image,omitempty"`
+}
+
+// Activity is used for outgoing activities. The object is
+// either a full object or the ID of a referenced object.
+type Activity struct {
+	Context   any       `json:"@context,omitempty"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	Published time.Time `json:"published,omitzero"`
+	To        []string  `json:"to,omitempty"`
+	CC        []string  `json:"cc,omitempty"`
+	Object    any       `json:"object"`
+}
+
+// IncomingActivity is the subset of fields which the inbox understands.
+// The object of a Follow is the followed actor's ID, whereas the object
+// of an Undo is the Follow activity itself.
+type IncomingActivity struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Actor  string `json:"actor"`
+	Object any    `json:"object"`
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
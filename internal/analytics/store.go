@@ -0,0 +1,161 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultStorePath = "data/analytics.db"
+
+	openTimeout = 3 * time.Second
+)
+
+var (
+	daysBucket = []byte("days")
+
+	// Salts and visitor hashes must never be persisted,
+	// but earlier versions of the store kept them here:
+	legacyBuckets = [][]byte{[]byte("visitors"), []byte("meta")}
+)
+
+// Day aggregates the page views of a single day (UTC).
+// Requests counts the server-side requests per blog post,
+// which unlike Posts includes visitors without JavaScript.
+type Day struct {
+	Date      string
+	PageViews int
+	Visitors  int
+	Posts     map[string]int
+	Referrers map[string]int
+	Requests  map[string]int
+}
+
+func newDay(date string) *Day {
+	return &Day{
+		Date:      date,
+		Posts:     map[string]int{},
+		Referrers: map[string]int{},
+		Requests:  map[string]int{},
+	}
+}
+
+// compactReferrers keeps the most frequent referrers of a day and
+// adds up the counts of all others as OtherReferrers.
+func (d *Day) compactReferrers() {
+	hosts := []string{}
+	for host := range d.Referrers {
+		if host != OtherReferrers {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) <= maxReferrersPerDay {
+		return
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if d.Referrers[hosts[i]] == d.Referrers[hosts[j]] {
+			return hosts[i] < hosts[j]
+		}
+		return d.Referrers[hosts[i]] > d.Referrers[hosts[j]]
+	})
+	for _, host := range hosts[maxReferrersPerDay:] {
+		d.Referrers[OtherReferrers] += d.Referrers[host]
+		delete(d.Referrers, host)
+	}
+}
+
+func (d *Day) clone() *Day {
+	c := *d
+	c.Posts = maps.Clone(d.Posts)
+	c.Referrers = maps.Clone(d.Referrers)
+	c.Requests = maps.Clone(d.Requests)
+	return &c
+}
+
+// Store persists aggregated statistics in an embedded bbolt database.
+// No individual requests, IP addresses, user agents or visitor hashes
+// are stored.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for analytics store: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening analytics store '%s': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range legacyBuckets {
+			if tx.Bucket(bucket) == nil {
+				continue
+			}
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucketIfNotExists(daysBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error initialising analytics store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Days returns all stored days in chronological order.
+func (s *Store) Days() ([]*Day, error) {
+	days := []*Day{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(daysBucket).ForEach(func(_, value []byte) error {
+			day := newDay("")
+			if err := json.Unmarshal(value, day); err != nil {
+				return fmt.Errorf("error deserialising day: %w", err)
+			}
+			days = append(days, day)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing days: %w", err)
+	}
+	return days, nil
+}
+
+// save stores the given days.
+func (s *Store) save(days []*Day) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, day := range days {
+			value, err := json.Marshal(day)
+			if err != nil {
+				return fmt.Errorf("error serialising day: %w", err)
+			}
+			if err := tx.Bucket(daysBucket).Put([]byte(day.Date), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error storing statistics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dateFormat = "2006-01-02"
+
+	// maxReferrersPerDay limits how many referrers are kept per day.
+	// Referrers are compacted once a day has twice as many.
+	maxReferrersPerDay = 100
+
+	// OtherReferrers counts the visits from all referrers which
+	// didn't make it into the most frequent referrers of a day.
+	// Parentheses can't be part of a host name.
+	OtherReferrers = "(other)"
+)
+
+var botKeywords = []string{"bot", "crawl", "spider", "slurp", "fetch", "preview", "headless"}
+
+// Visit is a single page view as reported by the beacon.
+type Visit struct {
+	Time      time.Time
+	IP        string
+	UserAgent string
+	PostID    string
+	Referrer  string
+}
+
+// IsBot reports whether a user agent identifies a crawler.
+func IsBot(userAgent string) bool {
+	if len(userAgent) == 0 {
+		return true
+	}
+	ua := strings.ToLower(userAgent)
+	return slices.ContainsFunc(botKeywords, func(keyword string) bool {
+		return strings.Contains(ua, keyword)
+	})
+}
+
+// ReferrerHost returns the host of an external referrer
+// or an empty string for direct and internal traffic.
+func ReferrerHost(referrer string, ownHost string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if len(host) == 0 || host == strings.TrimPrefix(strings.ToLower(ownHost), "www.") {
+		return ""
+	}
+	return host
+}
+
+// salt is the secret which visitor hashes of a day are derived from.
+// It gets replaced every day and only exists in memory, which makes it
+// impossible to link visitors across days or to reverse the hashes.
+type salt struct {
+	Date  string
+	Value []byte
+}
+
+// Tracker counts page views, posts, referrers and daily visitors in memory
+// and periodically persists the aggregated counts. Visitors are identified
+// by a hash of their IP address and user agent, which is salted with a
+// random value that changes every day. Neither the salt nor the hashes
+// are persisted, therefore visitors get counted again after a restart.
+type Tracker struct {
+	store *Store
+
+	mu       sync.Mutex
+	days     map[string]*Day
+	salt     *salt
+	visitors map[string]bool
+	dirty    map[string]bool
+}
+
+func NewTracker(store *Store) (*Tracker, error) {
+	days, err := store.Days()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tracker{
+		store:    store,
+		days:     map[string]*Day{},
+		visitors: map[string]bool{},
+		dirty:    map[string]bool{},
+	}
+	for _, day := range days {
+		t.days[day.Date] = day
+	}
+	return t, nil
+}
+
+// rotateSalt replaces the salt and forgets all visitors
+// when the first visit of a new day arrives.
+func (t *Tracker) rotateSalt(date string) error {
+	if t.salt != nil && t.salt.Date == date {
+		return nil
+	}
+	value := make([]byte, 32)
+	_, err := rand.Read(value)
+	if err != nil {
+		return fmt.Errorf("error generating salt: %w", err)
+	}
+	t.salt = &salt{Date: date, Value: value}
+	t.visitors = map[string]bool{}
+	return nil
+}
+
+func (t *Tracker) visitorHash(ip string, userAgent string) string {
+	h := sha256.New()
+	h.Write(t.salt.Value)
+	h.Write([]byte(ip))
+	h.Write([]byte{0})
+	h.Write([]byte(userAgent))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// day returns the statistics of a date and must be called with the lock held.
+func (t *Tracker) day(date string) *Day {
+	day, ok := t.days[date]
+	if !ok {
+		day = newDay(date)
+		t.days[date] = day
+	}
+	return day
+}
+
+func (t *Tracker) Track(v Visit) error {
+	date := v.Time.UTC().Format(dateFormat)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.rotateSalt(date)
+	if err != nil {
+		return err
+	}
+
+	day := t.day(date)
+	day.PageViews++
+	if len(v.PostID) > 0 {
+		day.Posts[v.PostID]++
+	}
+	if len(v.Referrer) > 0 {
+		day.Referrers[v.Referrer]++
+		if len(day.Referrers) > 2*maxReferrersPerDay {
+			day.compactReferrers()
+		}
+	}
+	hash := t.visitorHash(v.IP, v.UserAgent)
+	if !t.visitors[hash] {
+		t.visitors[hash] = true
+		day.Visitors++
+	}
+	t.dirty[date] = true
+	return nil
+}
+
+// CountRequest counts a server-side request of a blog post.
+func (t *Tracker) CountRequest(postID string, at time.Time) {
+	date := at.UTC().Format(dateFormat)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.day(date).Requests[postID]++
+	t.dirty[date] = true
+}
+
+// Flush persists all days which changed since the last flush.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	if len(t.dirty) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	days := make([]*Day, 0, len(t.dirty))
+	for date := range t.dirty {
+		days = append(days, t.days[date].clone())
+	}
+	t.dirty = map[string]bool{}
+	t.mu.Unlock()
+
+	err := t.store.save(days)
+	if err != nil {
+		// Try again with the next flush:
+		t.mu.Lock()
+		for _, day := range days {
+			t.dirty[day.Date] = true
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes the statistics periodically until the context gets cancelled.
+func (t *Tracker) Run(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				logger.Error("Failed to persist statistics.", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				logger.Error("Failed to persist statistics.", "error", err)
+			}
+		}
+	}
+}
+
+// Days returns the statistics of all days since the given time
+// in reverse chronological order. A zero time returns all days.
+func (t *Tracker) Days(since time.Time) []*Day {
+	from := ""
+	if !since.IsZero() {
+		from = since.UTC().Format(dateFormat)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	days := []*Day{}
+	for date, day := range t.days {
+		if date >= from {
+			days = append(days, day.clone())
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date > days[j].Date
+	})
+	return days
+}
+
+// sum adds up the counts of all days since the given time.
+// A zero time sums up all days.
+func (t *Tracker) sum(since time.Time, counts func(day *Day) map[string]int) map[string]int {
+	from := ""
+	if !since.IsZero() {
+		from = since.UTC().Format(dateFormat)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := map[string]int{}
+	for date, day := range t.days {
+		if date < from {
+			continue
+		}
+		for key, count := range counts(day) {
+			total[key] += count
+		}
+	}
+	return total
+}
+
+// PostViews sums up the views of each blog post since the given time.
+// A zero time returns the views of all time.
+func (t *Tracker) PostViews(since time.Time) map[string]int {
+	return t.sum(since, func(day *Day) map[string]int { return day.Posts })
+}
+
+// PostRequests sums up the requests of each blog post since the given time.
+// A zero time returns the requests of all time.
+func (t *Tracker) PostRequests(since time.Time) map[string]int {
+	return t.sum(since, func(day *Day) map[string]int { return day.Requests })
+}
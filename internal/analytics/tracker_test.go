@@ -0,0 +1,173 @@
+package analytics
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestTracker(t *testing.T, path string) (*Tracker, *Store) {
+	t.Helper()
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := NewTracker(store)
+	if err != nil {
+		_ = store.Close()
+		t.Fatal(err)
+	}
+	return tracker, store
+}
+
+func TestTrackCompactsReferrers(t *testing.T) {
+	tracker, store := newTestTracker(t, filepath.Join(t.TempDir(), "analytics.db"))
+	defer func() {
+		_ = store.Close()
+	}()
+
+	now := time.Now()
+	for range 3 {
+		err := tracker.Track(Visit{Time: now, IP: "192.0.2.1", UserAgent: "Firefox", Referrer: "news.ycombinator.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 4 * maxReferrersPerDay {
+		err := tracker.Track(Visit{Time: now, IP: "192.0.2.1", UserAgent: "Firefox", Referrer: fmt.Sprintf("spam-%d.example", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	days := tracker.Days(time.Time{})
+	if len(days) != 1 {
+		t.Fatalf("expected 1 day, got %d", len(days))
+	}
+	referrers := days[0].Referrers
+	if len(referrers) > 2*maxReferrersPerDay+1 {
+		t.Errorf("expected at most %d referrers, got %d", 2*maxReferrersPerDay+1, len(referrers))
+	}
+	if referrers["news.ycombinator.com"] != 3 {
+		t.Errorf("expected the most frequent referrer to be kept, got %v", referrers["news.ycombinator.com"])
+	}
+	total := 0
+	for _, count := range referrers {
+		total += count
+	}
+	if expected := 3 + 4*maxReferrersPerDay; total != expected {
+		t.Errorf("expected %d visits from referrers, got %d", expected, total)
+	}
+	if referrers[OtherReferrers] == 0 {
+		t.Errorf("expected visits from other referrers, got %v", referrers)
+	}
+}
+
+func TestTrackerDoesNotPersistVisitors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.db")
+	visit := Visit{Time: time.Now(), IP: "192.0.2.1", UserAgent: "Firefox", PostID: "hello-world"}
+
+	tracker, store := newTestTracker(t, path)
+	for range 2 {
+		if err := tracker.Track(visit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Close()
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) != string(daysBucket) {
+				t.Errorf("expected only the days bucket, got '%s'", name)
+			}
+			return nil
+		})
+	})
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, store = newTestTracker(t, path)
+	defer func() {
+		_ = store.Close()
+	}()
+	if err := tracker.Track(visit); err != nil {
+		t.Fatal(err)
+	}
+	day := tracker.Days(time.Time{})[0]
+	if day.PageViews != 3 || day.Posts["hello-world"] != 3 {
+		t.Errorf("expected 3 page views to be restored, got %d and %d", day.PageViews, day.Posts["hello-world"])
+	}
+	// The salt is gone after a restart, so the visitor is counted again:
+	if day.Visitors != 2 {
+		t.Errorf("expected 2 visitors, got %d", day.Visitors)
+	}
+}
+
+func TestPostRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.db")
+	tracker, store := newTestTracker(t, path)
+
+	today := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	requests := []struct {
+		postID string
+		at     time.Time
+	}{
+		{"hello-world", today},
+		{"hello-world", today.Add(-time.Hour)},
+		{"hello-world", today.AddDate(0, 0, -40)},
+		{"rsa-keys", today.AddDate(0, 0, -6)},
+		{"rsa-keys", today.AddDate(0, 0, -7)},
+		{"rsa-keys", today.AddDate(0, 0, -7)},
+		// Dates are in UTC:
+		{"rsa-keys", time.Date(2024, 3, 4, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))},
+	}
+	for _, r := range requests {
+		tracker.CountRequest(r.postID, r.at)
+	}
+
+	tests := []struct {
+		name     string
+		since    time.Time
+		expected string
+	}{
+		{"all time", time.Time{}, "map[hello-world:3 rsa-keys:4]"},
+		{"7 days", today.AddDate(0, 0, -6), "map[hello-world:2 rsa-keys:1]"},
+		{"since the start of a day", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), "map[hello-world:2 rsa-keys:4]"},
+		{"since the end of a day", time.Date(2024, 3, 3, 23, 59, 0, 0, time.UTC), "map[hello-world:2 rsa-keys:4]"},
+		{"today", today, "map[hello-world:2]"},
+		{"future", today.AddDate(0, 0, 1), "map[]"},
+	}
+
+	check := func(tracker *Tracker) {
+		t.Helper()
+		for _, test := range tests {
+			if actual := fmt.Sprint(tracker.PostRequests(test.since)); actual != test.expected {
+				t.Errorf("%s: expected %s, got %s", test.name, test.expected, actual)
+			}
+		}
+	}
+	check(tracker)
+
+	// Request counts survive a restart:
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Close()
+	tracker, store = newTestTracker(t, path)
+	defer func() {
+		_ = store.Close()
+	}()
+	check(tracker)
+}
@@ -0,0 +1,242 @@
+package blog
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Admonitions are callout boxes such as notes and warnings, which can be
+// written as GitHub style alerts:
+//
+//	> [!NOTE]
+//	> Content with **Markdown**.
+//
+// or as fenced containers with an optional title:
+//
+//	:::warning Breaking change
+//	Content with **Markdown**.
+//	:::
+//
+// Containers can be nested when the outer fence is longer than the inner one.
+var admonitions = &admonitionExtension{}
+
+// admonitionTitles are the supported variants and their default titles.
+var admonitionTitles = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+}
+
+var alertMarker = regexp.MustCompile(`^\[!([A-Za-z]+)\]$`)
+
+var KindAdmonition = ast.NewNodeKind("Admonition")
+
+// Admonition is a callout box which contains other blocks.
+type Admonition struct {
+	ast.BaseBlock
+	Variant string
+	Title   string
+	fence   int
+}
+
+func (n *Admonition) Kind() ast.NodeKind {
+	return KindAdmonition
+}
+
+func (n *Admonition) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Variant": n.Variant,
+		"Title":   n.Title,
+	}, nil)
+}
+
+func newAdmonition(variant string, title string) *Admonition {
+	if len(title) == 0 {
+		title = admonitionTitles[variant]
+	}
+	return &Admonition{Variant: variant, Title: title}
+}
+
+// advanceLine consumes the rest of the current line except the line break.
+func advanceLine(reader text.Reader) {
+	line, segment := reader.PeekLine()
+	newline := 0
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		newline = 1
+	}
+	reader.Advance(segment.Stop - segment.Start - newline + segment.Padding)
+}
+
+// containerParser parses fenced admonition containers.
+type containerParser struct{}
+
+func (p *containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *containerParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	fence := 0
+	for pos+fence < len(line) && line[pos+fence] == ':' {
+		fence++
+	}
+	if fence < 3 {
+		return nil, parser.NoChildren
+	}
+
+	variant, title, _ := strings.Cut(strings.TrimSpace(string(line[pos+fence:])), " ")
+	variant = strings.ToLower(variant)
+	if _, ok := admonitionTitles[variant]; !ok {
+		return nil, parser.NoChildren
+	}
+
+	node := newAdmonition(variant, strings.TrimSpace(title))
+	node.fence = fence
+	advanceLine(reader)
+	return node, parser.HasChildren
+}
+
+func (p *containerParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w < 4 && !inFencedCode(pc) {
+		fence := 0
+		for pos+fence < len(line) && line[pos+fence] == ':' {
+			fence++
+		}
+		// Only a fence which is at least as long as the opening
+		// fence closes the container, shorter ones close nested ones:
+		if fence >= node.(*Admonition).fence && util.IsBlank(line[pos+fence:]) {
+			advanceLine(reader)
+			return parser.Close
+		}
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+// inFencedCode reports whether a fenced code block is open, whose lines
+// are code even when they look like the closing fence of a container.
+func inFencedCode(pc parser.Context) bool {
+	last := pc.LastOpenedBlock()
+	_, ok := last.Node.(*ast.FencedCodeBlock)
+	return ok
+}
+
+func (p *containerParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// alertTransformer turns blockquotes which start with
+// a line like [!NOTE] into admonitions.
+type alertTransformer struct{}
+
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	blockquotes := []*ast.Blockquote{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if bq, ok := n.(*ast.Blockquote); ok && entering {
+			blockquotes = append(blockquotes, bq)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, bq := range blockquotes {
+		para, ok := bq.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		marker := para.Lines().At(0)
+		match := alertMarker.FindSubmatch(bytes.TrimSpace(marker.Value(source)))
+		if match == nil {
+			continue
+		}
+		variant := strings.ToLower(string(match[1]))
+		if _, ok := admonitionTitles[variant]; !ok {
+			continue
+		}
+
+		// Remove the marker from the paragraph:
+		for c := para.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Stop > marker.Stop {
+				break
+			}
+			para.RemoveChild(para, c)
+			c = next
+		}
+		para.Lines().SetSliced(1, para.Lines().Len())
+		if !para.HasChildren() {
+			bq.RemoveChild(bq, para)
+		}
+
+		node := newAdmonition(variant, "")
+		for c := bq.FirstChild(); c != nil; c = bq.FirstChild() {
+			node.AppendChild(node, c)
+		}
+		bq.Parent().ReplaceChild(bq.Parent(), bq, node)
+	}
+}
+
+// admonitionRenderer renders admonitions as asides with Tailwind friendly classes.
+type admonitionRenderer struct{}
+
+func (r *admonitionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAdmonition, r.renderAdmonition)
+}
+
+func (r *admonitionRenderer) renderAdmonition(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	node := n.(*Admonition)
+	if entering {
+		_, _ = w.WriteString(`<aside class="admonition admonition-` + node.Variant + `">` + "\n")
+		_, _ = w.WriteString(`<p class="admonition-title">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(node.Title)))
+		_, _ = w.WriteString("</p>\n")
+	} else {
+		_, _ = w.WriteString("</aside>\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+type admonitionExtension struct{}
+
+func (e *admonitionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(&containerParser{}, 90),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(&alertTransformer{}, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&admonitionRenderer{}, 100),
+		),
+	)
+}
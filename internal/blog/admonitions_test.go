@@ -0,0 +1,66 @@
+package blog
+
+import "testing"
+
+func TestAdmonitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		expected string
+	}{
+		{
+			name:     "alert",
+			markdown: "> [!WARNING]\n> Be careful.\n",
+			expected: "<aside class=\"admonition admonition-warning\">\n<p class=\"admonition-title\">Warning</p>\n<p>Be careful.</p>\n</aside>\n",
+		},
+		{
+			name:     "container with title",
+			markdown: ":::tip Pro tip\nUse **Markdown**.\n:::\n",
+			expected: "<aside class=\"admonition admonition-tip\">\n<p class=\"admonition-title\">Pro tip</p>\n<p>Use <strong>Markdown</strong>.</p>\n</aside>\n",
+		},
+		{
+			name:     "unknown container",
+			markdown: ":::unknown\ntext\n:::\n",
+			expected: "<p>:::unknown\ntext\n:::</p>\n",
+		},
+		{
+			name:     "container without closing fence",
+			markdown: ":::note\nnever closed\n",
+			expected: "<aside class=\"admonition admonition-note\">\n<p class=\"admonition-title\">Note</p>\n<p>never closed</p>\n</aside>\n",
+		},
+		{
+			name:     "nested containers",
+			markdown: "::::tip\n:::note\ninner\n:::\nouter\n::::\n",
+			expected: "<aside class=\"admonition admonition-tip\">\n<p class=\"admonition-title\">Tip</p>\n" +
+				"<aside class=\"admonition admonition-note\">\n<p class=\"admonition-title\">Note</p>\n<p>inner</p>\n</aside>\n" +
+				"<p>outer</p>\n</aside>\n",
+		},
+		{
+			name:     "fence in code block",
+			markdown: ":::note\n```\n:::\n```\nafter\n:::\n",
+			expected: "<aside class=\"admonition admonition-note\">\n<p class=\"admonition-title\">Note</p>\n" +
+				"<figure class=\"code-block\" data-copy>\n<pre class=\"chroma\"><code>:::\n</code></pre>\n</figure>\n" +
+				"<p>after</p>\n</aside>\n",
+		},
+		{
+			name:     "fences in code block of nested container",
+			markdown: "::::tip\n:::note\n```\n:::\n::::\n```\n:::\n::::\nout\n",
+			expected: "<aside class=\"admonition admonition-tip\">\n<p class=\"admonition-title\">Tip</p>\n" +
+				"<aside class=\"admonition admonition-note\">\n<p class=\"admonition-title\">Note</p>\n" +
+				"<figure class=\"code-block\" data-copy>\n<pre class=\"chroma\"><code>:::\n::::\n</code></pre>\n</figure>\n" +
+				"</aside>\n</aside>\n<p>out</p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html, _, err := computeTemplate(test.markdown, nil, "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(html) != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, html)
+			}
+		})
+	}
+}
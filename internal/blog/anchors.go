@@ -0,0 +1,168 @@
+package blog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const DefaultAnchorsPath = "dist/anchors.txt"
+
+// AnchorWarning reports a heading ID which has been published before,
+// but which doesn't exist in the blog post anymore.
+type AnchorWarning struct {
+	BlogPostID string
+	Anchor     string
+}
+
+func (w AnchorWarning) String() string {
+	return fmt.Sprintf("blog post '%s': anchor '#%s' has been published, but no heading has this ID anymore",
+		w.BlogPostID, w.Anchor)
+}
+
+// htmlHeadingIDs returns the IDs of the headings of a blog post written in HTML.
+func htmlHeadingIDs(content string) []string {
+	ids := []string{}
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ids
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				if id, ok := attr(token.Attr, "id"); ok && len(id) > 0 {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+}
+
+// loadAnchors reads the published anchors, which are lines of
+// <blog post ID>#<anchor>. A missing file has no anchors.
+func loadAnchors(anchorsPath string) ([]string, error) {
+	anchors := []string{}
+	f, err := os.Open(anchorsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return anchors, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening anchors: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blogPostID, anchor, ok := strings.Cut(line, "#")
+		if !ok || len(blogPostID) == 0 || len(anchor) == 0 {
+			return nil, fmt.Errorf("invalid anchor in line %d: %s", lineNumber, line)
+		}
+		anchors = append(anchors, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading anchors: %w", err)
+	}
+	return anchors, nil
+}
+
+func saveAnchors(anchorsPath string, anchors []string) error {
+	var sb strings.Builder
+	sb.WriteString("# Published heading anchors, recorded by 'blog validate'.\n")
+	sb.WriteString("#\n# 'blog validate' warns when a heading of an anchor disappears. Keep the\n")
+	sb.WriteString("# anchor with a custom ID on the renamed heading, e.g. ## New title {#old-id},\n")
+	sb.WriteString("# or remove the line if breaking the links is intended.\n")
+	sb.WriteString("#\n# Format:\n#   <blog post ID>#<anchor>\n\n")
+	for _, anchor := range anchors {
+		sb.WriteString(anchor + "\n")
+	}
+	if err := os.WriteFile(anchorsPath, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("error writing anchors: %w", err)
+	}
+	return nil
+}
+
+// CheckAnchors compares the heading IDs of all blog posts with the
+// anchors which have been published before and logs a warning for each
+// anchor which disappeared. The anchors of renamed blog posts are found
+// through their aliases. New anchors get added to the record, missing
+// ones stay in it until they are restored or removed by hand.
+func CheckAnchors(
+	ctx context.Context,
+	blogPosts []*Post,
+	anchorsPath string,
+) ([]AnchorWarning, error) {
+	logger := slogctx.GetLogger(ctx)
+	published, err := loadAnchors(anchorsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	postsByID := map[string]*Post{}
+	for _, blogPost := range blogPosts {
+		postsByID[blogPost.ID] = blogPost
+		for _, alias := range blogPost.Aliases {
+			if _, ok := postsByID[alias]; !ok {
+				postsByID[alias] = blogPost
+			}
+		}
+	}
+
+	anchors := map[string]bool{}
+	warnings := []AnchorWarning{}
+	for _, line := range published {
+		blogPostID, anchor, _ := strings.Cut(line, "#")
+		blogPost, ok := postsByID[blogPostID]
+		// Retired blog posts and ones which don't exist anymore
+		// respond with an error or a redirect, nothing to check:
+		if !ok || blogPost.Retired {
+			anchors[line] = true
+			continue
+		}
+		key := blogPost.ID + "#" + anchor
+		if anchors[key] {
+			continue
+		}
+		anchors[key] = true
+		if slices.Contains(blogPost.HeadingIDs, anchor) {
+			continue
+		}
+		warning := AnchorWarning{BlogPostID: blogPost.ID, Anchor: anchor}
+		logger.Warn("Published anchor doesn't exist anymore.",
+			"warning", warning.String())
+		warnings = append(warnings, warning)
+	}
+
+	for _, blogPost := range blogPosts {
+		if blogPost.Retired {
+			continue
+		}
+		for _, id := range blogPost.HeadingIDs {
+			anchors[blogPost.ID+"#"+id] = true
+		}
+	}
+	lines := []string{}
+	for line := range anchors {
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return warnings, saveAnchors(anchorsPath, lines)
+}
@@ -11,18 +11,20 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/chroma/v2"
-	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/dusted-go/logging/v2/slogctx"
 	"github.com/yuin/goldmark"
 	syntax "github.com/yuin/goldmark-highlighting/v2"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
 )
 
 const (
@@ -64,6 +66,9 @@ var (
 			chroma.GenericTraceback:     "#dedede",
 			chroma.GenericUnderline:     "#dedede",
 			chroma.Error:                "#dedede",
+			chroma.LineHighlight:        "bg:#363636",
+			chroma.LineNumbers:          "#7f7f7f",
+			chroma.LineNumbersTable:     "#7f7f7f",
 		})
 )
 
@@ -87,40 +92,86 @@ type Post struct {
 	ID             string
 	Title          string
 	PublishDate    time.Time
+	Updated        time.Time
 	Tags           []string
+	Aliases        []string
+	Retired        bool
+	Replacement    string
 	HashCode       string
 	OpenGraphImage OpenGraphImage
+	Description    string
+	Canonical      string
+	Robots         string
+	Extensions     []string
+	HeadingIDs     []string
 	content        string
 	isHTML         bool
 	HTML           template.HTML
 }
 
+// LastModified returns the date of the last significant update
+// of a blog post, which defaults to its publish date.
+func (p *Post) LastModified() time.Time {
+	if p.Updated.After(p.PublishDate) {
+		return p.Updated
+	}
+	return p.PublishDate
+}
+
 func (p *Post) Year() int {
 	return p.PublishDate.Year()
 }
 
-func computeTemplate(markdown string) (template.HTML, error) {
-	parser := goldmark.New(
+func newMarkdown(trusted bool, optional []string) goldmark.Markdown {
+	rendererOptions := []renderer.Option{}
+	parserOptions := []parser.Option{}
+	highlightingOptions := []syntax.Option{
+		syntax.WithCustomStyle(syntaxStyle),
+		syntax.WithFormatOptions(syntaxFormatOptions...),
+	}
+
+	// Only blog posts may contain raw HTML, generate heading IDs
+	// for deep links and set attributes on code blocks:
+	if trusted {
+		rendererOptions = append(rendererOptions, html.WithUnsafe())
+		parserOptions = append(parserOptions,
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(&codeBlockTransformer{}, 90)),
+		)
+		highlightingOptions = append(highlightingOptions,
+			syntax.WithWrapperRenderer(renderCodeBlockWrapper),
+			syntax.WithCodeBlockOptions(codeBlockOptions),
+		)
+	}
+
+	rendererOptions = append(rendererOptions, renderer.WithNodeRenderers(
+		util.Prioritized(newCodeBlockRenderer(highlightingOptions...), 200),
+	))
+
+	extensions := []goldmark.Extender{
+		extension.Table,
+		extension.Strikethrough,
+	}
+	for _, e := range optionalExtensions {
+		if slices.Contains(optional, e.name) {
+			extensions = append(extensions, e.extender)
+		}
+	}
+
+	return goldmark.New(
 		goldmark.WithExtensions(
-			extension.Table,
-			extension.Strikethrough,
-			syntax.NewHighlighting(
-				syntax.WithCustomStyle(syntaxStyle),
-				syntax.WithFormatOptions(
-					chromahtml.TabWidth(4),
-					chromahtml.WithLineNumbers(false),
-					chromahtml.PreventSurroundingPre(false),
-				),
-			),
+			extensions...,
 		),
 		goldmark.WithRendererOptions(
-			html.WithUnsafe(),
+			rendererOptions...,
 		), goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
+			parserOptions...,
 		))
+}
 
+func convertMarkdown(md goldmark.Markdown, markdown string, opts ...parser.ParseOption) (template.HTML, error) {
 	var buf bytes.Buffer
-	err := parser.Convert([]byte(markdown), &buf)
+	err := md.Convert([]byte(markdown), &buf, opts...)
 	if err != nil {
 		return template.HTML(""),
 			fmt.Errorf("error converting Markdown into HTML: %w", err)
@@ -130,10 +181,33 @@ func computeTemplate(markdown string) (template.HTML, error) {
 	return template.HTML(buf.Bytes()), nil
 }
 
+func computeTemplate(markdown string, extensions []string, hashCode string) (template.HTML, []string, error) {
+	md := newMarkdown(true, extensions)
+	admonitions.Extend(md)
+	diagrams.Extend(md)
+	headings.Extend(md)
+	pc := parser.NewContext()
+	pc.Set(hashCodeKey, hashCode)
+	html, err := convertMarkdown(md, markdown, parser.WithContext(pc))
+	if err != nil {
+		return html, nil, err
+	}
+	headingIDs, _ := pc.Get(headingIDsKey).([]string)
+	return html, headingIDs, firstError(pc, markdown)
+}
+
+// RenderUntrustedMarkdown converts user submitted Markdown into HTML
+// with the same pipeline as blog posts, except that raw HTML is omitted.
+func RenderUntrustedMarkdown(markdown string) (template.HTML, error) {
+	return convertMarkdown(newMarkdown(false, nil), markdown)
+}
+
 func parsePost(
 	blogPostID string,
 	publishDate time.Time,
 	buffer []byte,
+	files *includeDirs,
+	options Options,
 ) (
 	*Post,
 	error,
@@ -148,10 +222,16 @@ func parsePost(
 	readMeta := false
 	readBody := false
 
+	// The line in the file where the body starts,
+	// which turns errors in the body into file lines:
+	lineNumber := 0
+	bodyLine := 0
+
 	scanner := bufio.NewScanner(bytes.NewReader(bufferWithoutBOM))
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
 		line := scanner.Text()
+		lineNumber++
 
 		if strings.HasPrefix(line, "<!--") {
 			readMeta = true
@@ -169,6 +249,9 @@ func parsePost(
 		} else if strings.HasPrefix(line, "# ") && body.Len() == 0 {
 			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
 		} else if readBody {
+			if body.Len() == 0 {
+				bodyLine = lineNumber
+			}
 			body.WriteString(line)
 			body.WriteString("\n")
 		}
@@ -182,7 +265,15 @@ func parsePost(
 	isHTML := false
 
 	var tags []string
+	var updated time.Time
+	var aliases []string
+	var retired bool
+	var replacement string
 	var ogImage OpenGraphImage
+	var description string
+	var canonical string
+	var robots string
+	var markdown []string
 
 	for _, meta := range metadata {
 		metaParts := strings.SplitN(meta, ":", 2)
@@ -191,6 +282,27 @@ func parsePost(
 		switch key {
 		case "tags":
 			tags = strings.Split(strings.TrimSpace(metaParts[1]), " ")
+		case "updated":
+			value := strings.TrimSpace(metaParts[1])
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blog post updated date: %s", value)
+			}
+			updated = date
+		case "aliases":
+			aliases = strings.Fields(metaParts[1])
+		case "status":
+			status := strings.ToLower(strings.TrimSpace(metaParts[1]))
+			switch status {
+			case "published":
+				retired = false
+			case "retired":
+				retired = true
+			default:
+				return nil, fmt.Errorf("unknown blog post status: %s", status)
+			}
+		case "replacement":
+			replacement = strings.TrimSpace(metaParts[1])
 		case "type":
 			isHTML = strings.ToLower(strings.TrimSpace(metaParts[1])) == "html"
 		case "image.url":
@@ -212,11 +324,38 @@ func parsePost(
 			}
 		case "image.mimetype":
 			ogImage.MimeType = strings.TrimSpace(metaParts[1])
+		case "description":
+			description = strings.TrimSpace(metaParts[1])
+		case "canonical":
+			canonical = strings.TrimSpace(metaParts[1])
+		case "robots":
+			robots = strings.TrimSpace(metaParts[1])
+		case "markdown":
+			markdown = strings.Fields(metaParts[1])
 		default:
 			return nil, fmt.Errorf("unknown blog post metadata key: %s", key)
 		}
 	}
 
+	extensions, err := resolveExtensions(options.Extensions, markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shortcodes get expanded before hashing,
+	// so that changes to included files count:
+	lines := lineMap{}
+	if !isHTML {
+		content, lines, err = expandShortcodes(content, &shortcodeContext{files: files})
+		if err != nil {
+			var lineErr *LineError
+			if errors.As(err, &lineErr) {
+				lineErr.Line += bodyLine - 1
+			}
+			return nil, fmt.Errorf("error expanding shortcodes: %w", err)
+		}
+	}
+
 	valueToHash := strings.Builder{}
 	valueToHash.WriteString(title + content + publishDate.String())
 
@@ -224,6 +363,25 @@ func parsePost(
 		valueToHash.WriteString(tag)
 	}
 
+	if !updated.IsZero() {
+		valueToHash.WriteString("updated" + updated.String())
+	}
+
+	if retired {
+		valueToHash.WriteString("retired" + replacement)
+	}
+
+	// Only hash SEO overrides when they are set,
+	// which keeps the hash codes of other posts stable:
+	if len(description+canonical+robots) > 0 {
+		valueToHash.WriteString("seo" + description + canonical + robots)
+	}
+
+	// The same goes for optional Markdown extensions:
+	if len(extensions) > 0 {
+		valueToHash.WriteString("markdown" + strings.Join(extensions, " "))
+	}
+
 	//nolint: gosec // hash used for caching, not security
 	hash := sha1.New()
 	hash.Write([]byte(valueToHash.String()))
@@ -233,9 +391,17 @@ func parsePost(
 		ID:             blogPostID,
 		Title:          title,
 		PublishDate:    publishDate,
+		Updated:        updated,
 		Tags:           tags,
+		Aliases:        aliases,
+		Retired:        retired,
+		Replacement:    replacement,
 		HashCode:       hashCode,
 		OpenGraphImage: ogImage,
+		Description:    description,
+		Canonical:      canonical,
+		Robots:         robots,
+		Extensions:     extensions,
 		content:        content,
 		isHTML:         isHTML,
 	}
@@ -243,19 +409,29 @@ func parsePost(
 	if isHTML {
 		//nolint: gosec // This is safe content
 		blogPost.HTML = template.HTML(content)
+		blogPost.HeadingIDs = htmlHeadingIDs(content)
 	} else {
-		html, err := computeTemplate(content)
+		html, headingIDs, err := computeTemplate(content, extensions, hashCode)
 		if err != nil {
+			var lineErr *LineError
+			if errors.As(err, &lineErr) {
+				lineErr.Line = lines.source(lineErr.Line) + bodyLine - 1
+			}
 			return nil, fmt.Errorf("error computing template: %w", err)
 		}
 
 		blogPost.HTML = html
+		blogPost.HeadingIDs = headingIDs
 	}
 
 	return blogPost, nil
 }
 
-func ReadPost(ctx context.Context, basePath string, blogPostID string) (*Post, error) {
+func ReadPost(ctx context.Context, basePath string, blogPostID string, options Options) (*Post, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
 	blogPostsBasePath := filepath.Clean(basePath)
 
 	files, err := os.ReadDir(blogPostsBasePath)
@@ -296,7 +472,13 @@ func ReadPost(ctx context.Context, basePath string, blogPostID string) (*Post, e
 		return nil, fmt.Errorf("error reading blog post file: %w", err)
 	}
 
-	blogPost, err := parsePost(blogPostID, publishDate, fileBuffer)
+	includes, err := openIncludes(blogPostsBasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer includes.Close()
+
+	blogPost, err := parsePost(blogPostID, publishDate, fileBuffer, includes, options)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing blog post '%s': %w", fileName, err)
 	}
@@ -304,12 +486,22 @@ func ReadPost(ctx context.Context, basePath string, blogPostID string) (*Post, e
 	return blogPost, nil
 }
 
-func ReadPosts(ctx context.Context, basePath string) ([]*Post, error) {
+func ReadPosts(ctx context.Context, basePath string, options Options) ([]*Post, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
 	files, err := os.ReadDir(basePath)
 	if err != nil {
 		return nil, fmt.Errorf("error reading files from directory '%s': %w", basePath, err)
 	}
 
+	includes, err := openIncludes(basePath)
+	if err != nil {
+		return nil, err
+	}
+	defer includes.Close()
+
 	blogPosts := []*Post{}
 	logger := slogctx.GetLogger(ctx)
 
@@ -348,7 +540,7 @@ func ReadPosts(ctx context.Context, basePath string) ([]*Post, error) {
 			continue
 		}
 
-		blogPost, err := parsePost(blogPostID, publishDate, fileBuffer)
+		blogPost, err := parsePost(blogPostID, publishDate, fileBuffer, includes, options)
 		if err != nil {
 			logger.Error("Skipping blog post because of parsing error.",
 				"filename", fileName,
@@ -367,3 +559,28 @@ func ReadPosts(ctx context.Context, basePath string) ([]*Post, error) {
 
 	return blogPosts, nil
 }
+
+// CheckAliases makes sure that the aliases of blog posts can't shadow
+// another blog post, because redirects take precedence over routes.
+func CheckAliases(blogPosts []*Post) error {
+	owners := map[string]string{}
+	for _, blogPost := range blogPosts {
+		owners[blogPost.ID] = blogPost.ID
+	}
+	for _, blogPost := range blogPosts {
+		for _, alias := range blogPost.Aliases {
+			owner, ok := owners[alias]
+			if !ok {
+				owners[alias] = blogPost.ID
+				continue
+			}
+			if owner == alias {
+				return fmt.Errorf("alias '%s' of blog post '%s' is the ID of an existing blog post",
+					alias, blogPost.ID)
+			}
+			return fmt.Errorf("alias '%s' of blog post '%s' is already an alias of blog post '%s'",
+				alias, blogPost.ID, owner)
+		}
+	}
+	return nil
+}
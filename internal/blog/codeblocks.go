@@ -0,0 +1,232 @@
+package blog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	syntax "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Fenced code blocks in blog posts accept attributes after the language:
+//
+//	```go {title="main.go" hl_lines=[3,5-7] linenos=true}
+//
+// title renders a file name above the code, hl_lines highlights single
+// lines and ranges, linenos shows line numbers and linenostart sets the
+// number of the first line.
+var codeBlockAttributes = map[string]func(value any) (any, error){
+	"title":       parseCodeBlockTitle,
+	"hl_lines":    parseHighlightedLines,
+	"linenos":     parseLineNumbers,
+	"linenostart": parseLineNumberStart,
+}
+
+func parseCodeBlockTitle(value any) (any, error) {
+	title, ok := value.([]byte)
+	if !ok || len(title) == 0 {
+		return nil, errors.New("title must be a non-empty string")
+	}
+	return title, nil
+}
+
+// parseHighlightedLines accepts line numbers and ranges. Unquoted ranges
+// such as 5-7 get parsed as the numbers 5 and -7, so a negative number
+// ends the range which starts with the number before it. The result
+// uses the "5-7" strings which the syntax highlighter understands.
+func parseHighlightedLines(value any) (any, error) {
+	errInvalid := errors.New("hl_lines must be a list of lines and ranges, e.g. [3,5-7]")
+	items, ok := value.([]any)
+	if !ok {
+		return nil, errInvalid
+	}
+	lines := []any{}
+	for i := 0; i < len(items); i++ {
+		switch item := items[i].(type) {
+		case float64:
+			from, to := int(item), int(item)
+			if i+1 < len(items) {
+				if next, ok := items[i+1].(float64); ok && next < 0 {
+					to = -int(next)
+					i++
+				}
+			}
+			if from < 1 || to < from {
+				return nil, errInvalid
+			}
+			lines = append(lines, []byte(strconv.Itoa(from)+"-"+strconv.Itoa(to)))
+		case []byte:
+			from, to, found := bytes.Cut(item, []byte("-"))
+			if !found {
+				to = from
+			}
+			f, err1 := strconv.Atoi(string(from))
+			t, err2 := strconv.Atoi(string(to))
+			if err1 != nil || err2 != nil || f < 1 || t < f {
+				return nil, errInvalid
+			}
+			lines = append(lines, item)
+		default:
+			return nil, errInvalid
+		}
+	}
+	return lines, nil
+}
+
+// lastHighlightedLine returns the end of the last range of parsed hl_lines.
+func lastHighlightedLine(lines []any) int {
+	last := 0
+	for _, line := range lines {
+		from, to, found := bytes.Cut(line.([]byte), []byte("-"))
+		if !found {
+			to = from
+		}
+		n, _ := strconv.Atoi(string(to))
+		last = max(last, n)
+	}
+	return last
+}
+
+func parseLineNumbers(value any) (any, error) {
+	if _, ok := value.(bool); !ok {
+		return nil, errors.New("linenos must be true or false")
+	}
+	return value, nil
+}
+
+func parseLineNumberStart(value any) (any, error) {
+	if n, ok := value.(float64); !ok || n < 1 || n != float64(int(n)) {
+		return nil, errors.New("linenostart must be a positive number")
+	}
+	return value, nil
+}
+
+// codeBlockTransformer validates the attributes of fenced code blocks
+// and stores them on the nodes, where the syntax highlighter finds them.
+type codeBlockTransformer struct{}
+
+func (t *codeBlockTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		cb, ok := n.(*ast.FencedCodeBlock)
+		if !ok || !entering || cb.Info == nil {
+			return ast.WalkContinue, nil
+		}
+		info := cb.Info.Segment.Value(source)
+		start := bytes.IndexByte(info, '{')
+		if start < 0 {
+			return ast.WalkContinue, nil
+		}
+		offset := cb.Info.Segment.Start + start
+		attrs, ok := parser.ParseAttributes(text.NewReader(info[start:]))
+		if !ok {
+			reportError(pc, offset, fmt.Errorf("invalid code block attributes: %s", info[start:]))
+			return ast.WalkContinue, nil
+		}
+		for _, attr := range attrs {
+			parse, ok := codeBlockAttributes[string(attr.Name)]
+			if !ok {
+				reportError(pc, offset, fmt.Errorf("unknown code block attribute: %s", attr.Name))
+				continue
+			}
+			value, err := parse(attr.Value)
+			if err != nil {
+				reportError(pc, offset, err)
+				continue
+			}
+			cb.SetAttribute(attr.Name, value)
+		}
+		// Highlighted lines count from the first line of the code block:
+		if lines, ok := cb.AttributeString("hl_lines"); ok {
+			if count := cb.Lines().Len(); lastHighlightedLine(lines.([]any)) > count {
+				reportError(pc, offset, fmt.Errorf("hl_lines must be within the %d lines of the code block", count))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// renderCodeBlockWrapper wraps code blocks in a figure with the title as
+// caption. The data-copy attribute is the hook for the copy button.
+func renderCodeBlockWrapper(w util.BufWriter, ctx syntax.CodeBlockContext, entering bool) {
+	if !entering {
+		if !ctx.Highlighted() {
+			_, _ = w.WriteString("</code></pre>\n")
+		}
+		_, _ = w.WriteString("</figure>\n")
+		return
+	}
+	_, _ = w.WriteString(`<figure class="code-block" data-copy>` + "\n")
+	// The highlighter parses the attributes of the info string itself
+	// when none were valid, so the title can still be of any type here:
+	if attrs := ctx.Attributes(); attrs != nil {
+		if value, ok := attrs.GetString("title"); ok {
+			title, _ := value.([]byte)
+			_, _ = w.WriteString(`<figcaption class="code-block-title">`)
+			_, _ = w.Write(util.EscapeHTML(title))
+			_, _ = w.WriteString("</figcaption>\n")
+		}
+	}
+	if !ctx.Highlighted() {
+		_, _ = w.WriteString(`<pre class="chroma"><code`)
+		if language, ok := ctx.Language(); ok {
+			_, _ = w.WriteString(` class="language-`)
+			_, _ = w.Write(util.EscapeHTML(language))
+			_, _ = w.WriteString(`"`)
+		}
+		_, _ = w.WriteString(">")
+	}
+}
+
+// codeBlockOptions renders line numbers in a table column of their own,
+// which keeps them out of text selections and copied code.
+func codeBlockOptions(ctx syntax.CodeBlockContext) []chromahtml.Option {
+	return []chromahtml.Option{chromahtml.LineNumbersInTable(true)}
+}
+
+// codeBlockRenderer renders fenced code blocks with the syntax
+// highlighter and removes the spans which no theme colours.
+type codeBlockRenderer struct {
+	highlight renderer.NodeRendererFunc
+}
+
+func newCodeBlockRenderer(opts ...syntax.Option) *codeBlockRenderer {
+	r := &codeBlockRenderer{}
+	syntax.NewHTMLRenderer(opts...).RegisterFuncs(highlightRegisterer{r})
+	return r
+}
+
+// highlightRegisterer takes the render function of the syntax highlighter.
+type highlightRegisterer struct {
+	r *codeBlockRenderer
+}
+
+func (reg highlightRegisterer) Register(kind ast.NodeKind, f renderer.NodeRendererFunc) {
+	reg.r.highlight = f
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderCodeBlock(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	status, err := r.highlight(bw, source, n, entering)
+	_ = bw.Flush()
+	_, _ = w.Write(removeUnstyledSpans(buf.Bytes()))
+	return status, err
+}
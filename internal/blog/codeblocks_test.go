@@ -0,0 +1,191 @@
+package blog
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	codeLinePattern   = regexp.MustCompile(`<span class="line( hl)?"><span class="cl">(\w+)`)
+	lineNumberPattern = regexp.MustCompile(`<span class="lnt">(\d+)`)
+)
+
+func TestCodeBlockAttributes(t *testing.T) {
+	code := "a := 1\nb := 2\nc := 3\nd := 4\ne := 5\n```\n"
+	tests := []struct {
+		name        string
+		info        string
+		title       string
+		highlighted []string
+		lineNumbers []string
+		err         string
+	}{
+		{
+			name: "no attributes",
+			info: "go",
+		},
+		{
+			name:  "title",
+			info:  `go {title="cmd/main.go"}`,
+			title: `<figcaption class="code-block-title">cmd/main.go</figcaption>`,
+		},
+		{
+			name:  "escaped title",
+			info:  `go {title="<main>.go"}`,
+			title: `<figcaption class="code-block-title">&lt;main&gt;.go</figcaption>`,
+		},
+		{
+			name:        "highlighted lines and ranges",
+			info:        "go {hl_lines=[1,3-4]}",
+			highlighted: []string{"a", "c", "d"},
+		},
+		{
+			name:        "quoted ranges",
+			info:        `go {hl_lines=["2-3","5"]}`,
+			highlighted: []string{"b", "c", "e"},
+		},
+		{
+			name:        "last line",
+			info:        "go {hl_lines=[5]}",
+			highlighted: []string{"e"},
+		},
+		{
+			name:        "line numbers",
+			info:        "go {linenos=true}",
+			lineNumbers: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name: "no line numbers",
+			info: "go {linenos=false}",
+		},
+		{
+			name:        "line numbers with start",
+			info:        "go {linenos=true linenostart=41 hl_lines=[2]}",
+			highlighted: []string{"b"},
+			lineNumbers: []string{"41", "42", "43", "44", "45"},
+		},
+		{
+			name: "empty title",
+			info: `go {title=""}`,
+			err:  "line 3: title must be a non-empty string",
+		},
+		{
+			name: "numeric title",
+			info: "go {title=1}",
+			err:  "line 3: title must be a non-empty string",
+		},
+		{
+			name: "highlighted line zero",
+			info: "go {hl_lines=[0]}",
+			err:  "line 3: hl_lines must be a list of lines and ranges",
+		},
+		{
+			name: "reversed range",
+			info: "go {hl_lines=[4-2]}",
+			err:  "line 3: hl_lines must be a list of lines and ranges",
+		},
+		{
+			name: "reversed quoted range",
+			info: `go {hl_lines=["4-2"]}`,
+			err:  "line 3: hl_lines must be a list of lines and ranges",
+		},
+		{
+			name: "non-numeric range",
+			info: `go {hl_lines=["a-b"]}`,
+			err:  "line 3: hl_lines must be a list of lines and ranges",
+		},
+		{
+			name: "highlighted lines without a list",
+			info: "go {hl_lines=3}",
+			err:  "line 3: hl_lines must be a list of lines and ranges",
+		},
+		{
+			name: "highlighted line after the code",
+			info: "go {hl_lines=[6]}",
+			err:  "line 3: hl_lines must be within the 5 lines of the code block",
+		},
+		{
+			name: "range after the code",
+			info: "go {hl_lines=[4-6]}",
+			err:  "line 3: hl_lines must be within the 5 lines of the code block",
+		},
+		{
+			name: "non-boolean line numbers",
+			info: `go {linenos="table"}`,
+			err:  "line 3: linenos must be true or false",
+		},
+		{
+			name: "line numbers starting at zero",
+			info: "go {linenos=true linenostart=0}",
+			err:  "line 3: linenostart must be a positive number",
+		},
+		{
+			name: "fractional line number start",
+			info: "go {linenos=true linenostart=1.5}",
+			err:  "line 3: linenostart must be a positive number",
+		},
+		{
+			name: "non-numeric line number start",
+			info: `go {linenos=true linenostart="10"}`,
+			err:  "line 3: linenostart must be a positive number",
+		},
+		{
+			name: "unknown attribute",
+			info: "go {hl_style=monokai}",
+			err:  "line 3: unknown code block attribute: hl_style",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			markdown := "# Code\n\n```" + test.info + "\n" + code
+			html, _, err := computeTemplate(markdown, nil, "hash")
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := string(html)
+			if !strings.Contains(actual, `<figure class="code-block" data-copy>`) {
+				t.Error("expected the code block to be wrapped in a figure")
+			}
+			if len(test.title) > 0 && !strings.Contains(actual, test.title) {
+				t.Errorf("expected the title %s", test.title)
+			}
+			if len(test.title) == 0 && strings.Contains(actual, "<figcaption") {
+				t.Error("expected no title")
+			}
+
+			highlighted := []string{}
+			lines := codeLinePattern.FindAllStringSubmatch(actual, -1)
+			for _, line := range lines {
+				if len(line[1]) > 0 {
+					highlighted = append(highlighted, line[2])
+				}
+			}
+			if len(lines) != 5 {
+				t.Errorf("expected 5 lines of code, got %d", len(lines))
+			}
+			if len(test.highlighted) > 0 && !slices.Equal(highlighted, test.highlighted) ||
+				len(test.highlighted) == 0 && len(highlighted) > 0 {
+				t.Errorf("expected highlighted lines %v, got %v", test.highlighted, highlighted)
+			}
+
+			lineNumbers := []string{}
+			for _, match := range lineNumberPattern.FindAllStringSubmatch(actual, -1) {
+				lineNumbers = append(lineNumbers, match[1])
+			}
+			if len(test.lineNumbers) > 0 && !slices.Equal(lineNumbers, test.lineNumbers) ||
+				len(test.lineNumbers) == 0 && len(lineNumbers) > 0 {
+				t.Errorf("expected line numbers %v, got %v", test.lineNumbers, lineNumbers)
+			}
+		})
+	}
+}
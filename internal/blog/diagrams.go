@@ -0,0 +1,187 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/dustedcodes/blog/internal/diagram"
+)
+
+// Diagrams are fenced code blocks in the Graphviz DOT language or
+// Mermaid flowcharts, which get rendered as inline SVG:
+//
+//	```dot
+//	digraph { request -> cache -> response }
+//	```
+var diagrams = &diagramExtension{}
+
+// diagramLanguages maps the languages of code blocks to diagram languages.
+var diagramLanguages = map[string]string{
+	"dot":      diagram.DOT,
+	"graphviz": diagram.DOT,
+	"mermaid":  diagram.Mermaid,
+}
+
+// The layout of diagrams is comparatively expensive, so rendered SVG
+// is cached by the hash code of the post and the index of the diagram.
+// Blog posts get parsed on every request during development, where the
+// cache would otherwise grow with every edit of a post:
+var (
+	diagramCache = &svgCache{max: 64, svgs: map[string]string{}}
+	hashCodeKey  = parser.NewContextKey()
+)
+
+// svgCache keeps the most recently added diagrams.
+type svgCache struct {
+	mu   sync.Mutex
+	max  int
+	svgs map[string]string
+	keys []string // from oldest to newest
+}
+
+func (c *svgCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	svg, ok := c.svgs[key]
+	return svg, ok
+}
+
+func (c *svgCache) add(key string, svg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.svgs[key]; ok {
+		return
+	}
+	if len(c.keys) == c.max {
+		delete(c.svgs, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.svgs[key] = svg
+	c.keys = append(c.keys, key)
+}
+
+var KindDiagram = ast.NewNodeKind("Diagram")
+
+// Diagram is a fenced code block which was rendered as SVG.
+type Diagram struct {
+	ast.BaseBlock
+	SVG string
+}
+
+func (n *Diagram) Kind() ast.NodeKind {
+	return KindDiagram
+}
+
+func (n *Diagram) IsRaw() bool {
+	return true
+}
+
+func (n *Diagram) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// diagramTransformer replaces code blocks in diagram languages
+// with diagrams before the syntax highlighter sees them.
+type diagramTransformer struct{}
+
+func (t *diagramTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	blocks := []*ast.FencedCodeBlock{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if cb, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if _, ok := diagramLanguages[strings.ToLower(string(cb.Language(source)))]; ok {
+				blocks = append(blocks, cb)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	hashCode, _ := pc.Get(hashCodeKey).(string)
+	for i, cb := range blocks {
+		language := diagramLanguages[strings.ToLower(string(cb.Language(source)))]
+		lines := cb.Lines()
+		var src strings.Builder
+		for j := 0; j < lines.Len(); j++ {
+			line := lines.At(j)
+			src.Write(line.Value(source))
+		}
+
+		id := "diagram-" + strconv.Itoa(i+1)
+		key := hashCode + "/" + id
+		svg, ok := diagramCache.get(key)
+		if !ok {
+			rendered, err := diagram.Render(language, src.String(), id)
+			if err != nil {
+				reportError(pc, diagramErrorOffset(cb, err), fmt.Errorf("invalid %s diagram: %w", language, err))
+				continue
+			}
+			svg = rendered
+			if len(hashCode) > 0 {
+				diagramCache.add(key, svg)
+			}
+		}
+
+		node := &Diagram{SVG: svg}
+		node.SetLines(lines)
+		cb.Parent().ReplaceChild(cb.Parent(), cb, node)
+	}
+}
+
+// diagramErrorOffset returns the offset of the line of a
+// syntax error, or of the code block for other errors.
+func diagramErrorOffset(cb *ast.FencedCodeBlock, err error) int {
+	offset := 0
+	if cb.Info != nil {
+		offset = cb.Info.Segment.Start
+	}
+	var syntaxErr *diagram.SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Line-1 < cb.Lines().Len() {
+		offset = cb.Lines().At(max(syntaxErr.Line-1, 0)).Start
+	}
+	return offset
+}
+
+type diagramRenderer struct{}
+
+func (r *diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDiagram, r.renderDiagram)
+}
+
+func (r *diagramRenderer) renderDiagram(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(`<figure class="diagram">`)
+		_, _ = w.WriteString(n.(*Diagram).SVG)
+		_, _ = w.WriteString("</figure>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type diagramExtension struct{}
+
+func (e *diagramExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&diagramTransformer{}, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&diagramRenderer{}, 100),
+		),
+	)
+}
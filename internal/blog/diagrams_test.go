@@ -0,0 +1,45 @@
+package blog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSVGCacheEvictsTheOldestDiagrams(t *testing.T) {
+	c := &svgCache{max: 2, svgs: map[string]string{}}
+	c.add("a", "<svg>a</svg>")
+	c.add("b", "<svg>b</svg>")
+	c.add("a", "<svg>a again</svg>")
+	c.add("c", "<svg>c</svg>")
+
+	expected := map[string]string{"a": "", "b": "<svg>b</svg>", "c": "<svg>c</svg>"}
+	for key, svg := range expected {
+		actual, ok := c.get(key)
+		if ok != (len(svg) > 0) || actual != svg {
+			t.Errorf("expected '%s' for key '%s', got '%s' (%t)", svg, key, actual, ok)
+		}
+	}
+}
+
+func TestDiagramErrorLines(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		line int
+	}{
+		{"dot", "Intro\n\n```dot\ndigraph {\n  a -> b\n  c [label=\"x]\n}\n```\n", 11},
+		{"mermaid", "Intro\n\n```mermaid\nflowchart LR\n  A[unclosed --> B\n```\n", 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := "<!--\n-->\n\n# Title\n\n" + test.body
+			_, err := parsePost("example", time.Now(), []byte(source), noIncludes, Options{})
+			if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("line %d: invalid %s diagram", test.line, test.name)) {
+				t.Errorf("expected an invalid diagram on line %d, got %v", test.line, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package blog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// LineError is an error in the Markdown of a blog post.
+// Line is the line number in the blog post file.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Markdown extensions can't fail a conversion, so they
+// report errors via the parser context instead:
+var sourceErrorsKey = parser.NewContextKey()
+
+type sourceError struct {
+	offset int
+	err    error
+}
+
+// reportError records an error at a byte offset of the Markdown source.
+func reportError(pc parser.Context, offset int, err error) {
+	errs, _ := pc.Get(sourceErrorsKey).([]sourceError)
+	pc.Set(sourceErrorsKey, append(errs, sourceError{offset: offset, err: err}))
+}
+
+// firstError returns the first reported error with its line number.
+func firstError(pc parser.Context, source string) error {
+	errs, _ := pc.Get(sourceErrorsKey).([]sourceError)
+	if len(errs) == 0 {
+		return nil
+	}
+	offset := min(errs[0].offset, len(source))
+	return &LineError{
+		Line: strings.Count(source[:offset], "\n") + 1,
+		Err:  errs[0].err,
+	}
+}
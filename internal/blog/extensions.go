@@ -0,0 +1,87 @@
+package blog
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Optional Markdown extensions. They change the HTML of blog posts
+// which happen to contain their syntax by accident, which is why
+// they must be enabled explicitly, either globally or per blog post.
+const (
+	Footnotes       = "footnotes"
+	DefinitionLists = "definition-lists"
+	TaskLists       = "task-lists"
+	Linkify         = "linkify"
+	Typographer     = "typographer"
+	Math            = "math"
+)
+
+type optionalExtension struct {
+	name     string
+	extender goldmark.Extender
+}
+
+// optionalExtensions lists the optional Markdown extensions
+// in the order in which they get added to the pipeline.
+var optionalExtensions = []optionalExtension{
+	{Footnotes, extension.Footnote},
+	{DefinitionLists, extension.DefinitionList},
+	{TaskLists, extension.TaskList},
+	{Linkify, extension.Linkify},
+	{Typographer, extension.Typographer},
+	{Math, &mathExtension{}},
+}
+
+// Options control how blog posts get rendered.
+type Options struct {
+	// Extensions are the optional Markdown extensions which are enabled
+	// for all blog posts. A blog post can enable additional extensions
+	// and disable default ones with the Markdown metadata key, e.g.
+	// "Markdown: footnotes -linkify".
+	Extensions []string
+}
+
+func isOptionalExtension(name string) bool {
+	return slices.ContainsFunc(optionalExtensions, func(e optionalExtension) bool {
+		return e.name == name
+	})
+}
+
+func (o Options) validate() error {
+	for _, name := range o.Extensions {
+		if !isOptionalExtension(name) {
+			return fmt.Errorf("unknown Markdown extension: %s", name)
+		}
+	}
+	return nil
+}
+
+// resolveExtensions applies the toggles of a blog post to the
+// default extensions and returns the enabled extensions in order.
+func resolveExtensions(defaults []string, toggles []string) ([]string, error) {
+	enabled := map[string]bool{}
+	for _, name := range defaults {
+		enabled[name] = true
+	}
+	for _, toggle := range toggles {
+		name, disable := strings.CutPrefix(strings.ToLower(toggle), "-")
+		name = strings.TrimPrefix(name, "+")
+		if !isOptionalExtension(name) {
+			return nil, fmt.Errorf("unknown Markdown extension: %s", name)
+		}
+		enabled[name] = !disable
+	}
+
+	extensions := []string{}
+	for _, e := range optionalExtensions {
+		if enabled[e.name] {
+			extensions = append(extensions, e.name)
+		}
+	}
+	return extensions, nil
+}
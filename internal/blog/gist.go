@@ -0,0 +1,88 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var gistID = regexp.MustCompile(`^([A-Za-z0-9-]+)/([0-9a-f]+)$`)
+
+// embedGist renders a GitHub gist from a vendored copy, so that
+// blog posts neither depend on GitHub's scripts nor on the gist
+// staying online:
+//
+//	{{< gist "dustinmoris/5fa8fb0bd34f5bd0b8ab8d8ad28b1d43" >}}
+//	{{< gist "dustinmoris/5fa8fb0bd34f5bd0b8ab8d8ad28b1d43" file="Program.fs" >}}
+//
+// The files of a gist are in gists/<id>/ next to the articles directory.
+// Each file becomes a code block with the file name as title, followed
+// by a link to the original gist.
+func embedGist(sc *shortcode, ctx *shortcodeContext) (string, error) {
+	if err := sc.checkParams("file"); err != nil {
+		return "", err
+	}
+	if len(sc.args) != 1 {
+		return "", errors.New("gist needs exactly one user/id, e.g. {{< gist \"dustinmoris/5fa8fb0bd\" >}}")
+	}
+	match := gistID.FindStringSubmatch(sc.args[0])
+	if match == nil {
+		return "", fmt.Errorf("invalid gist '%s', expected user/id", sc.args[0])
+	}
+	id := match[2]
+	dir := path.Join(gistsDir, id)
+
+	files := []string{}
+	if file, ok := sc.params["file"]; ok {
+		if !fs.ValidPath(file) || strings.Contains(file, "/") {
+			return "", fmt.Errorf("invalid gist file: %s", file)
+		}
+		files = append(files, file)
+	} else {
+		entries, err := fs.ReadDir(ctx.files.gists, id)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("gist '%s' isn't vendored in %s", sc.args[0], dir)
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading gist '%s': %w", sc.args[0], err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				files = append(files, entry.Name())
+			}
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("gist '%s' has no files in %s", sc.args[0], dir)
+		}
+	}
+
+	var sb strings.Builder
+	for _, file := range files {
+		data, err := fs.ReadFile(ctx.files.gists, path.Join(id, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("gist '%s' has no file '%s' in %s", sc.args[0], file, dir)
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading gist '%s': %w", sc.args[0], err)
+		}
+		text := strings.ReplaceAll(string(data), "\r\n", "\n")
+		lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+		language := strings.TrimPrefix(path.Ext(file), ".")
+		if l, ok := includeLanguages[language]; ok {
+			language = l
+		}
+		if len(language) == 0 {
+			language = "text"
+		}
+		title := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(file)
+		sb.WriteString(fencedCode(language+` {title="`+title+`"}`, lines))
+		sb.WriteString("\n")
+	}
+	url := "https://gist.github.com/" + match[1] + "/" + match[2]
+	fmt.Fprintf(&sb, `<p class="gist-source"><a href="%s">View %s on GitHub</a></p>`+"\n", url, html.EscapeString(sc.args[0]))
+	return sb.String(), nil
+}
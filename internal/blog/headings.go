@@ -0,0 +1,164 @@
+package blog
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+)
+
+// Headings of blog posts get an anchor link to copy deep links from.
+// The IDs are generated from the text of the heading, unless a custom
+// one is set as attribute, which keeps links stable when the text changes:
+//
+//	## Generating keys {#keygen}
+var headings = &headingExtension{}
+
+var headingIDsKey = parser.NewContextKey()
+
+var KindHeadingAnchor = ast.NewNodeKind("HeadingAnchor")
+
+// HeadingAnchor is a link to the heading which contains it.
+type HeadingAnchor struct {
+	ast.BaseInline
+	ID []byte
+}
+
+func (n *HeadingAnchor) Kind() ast.NodeKind {
+	return KindHeadingAnchor
+}
+
+func (n *HeadingAnchor) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ID": string(n.ID)}, nil)
+}
+
+// headingTransformer adds anchors to headings and records their IDs.
+// IDs which are used more than once get a number appended like the
+// generated IDs of goldmark, which happens when a custom ID is the same
+// as the ID of another heading.
+type headingTransformer struct{}
+
+func (t *headingTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	headings := []*ast.Heading{}
+	taken := map[string]bool{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if id := headingID(heading); len(id) > 0 {
+			headings = append(headings, heading)
+			taken[id] = true
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, heading := range headings {
+		id := headingID(heading)
+		if seen[id] {
+			unique := id
+			for i := 1; taken[unique]; i++ {
+				unique = fmt.Sprintf("%s-%d", id, i)
+			}
+			id = unique
+			taken[id] = true
+			heading.SetAttributeString("id", []byte(id))
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		heading.AppendChild(heading, &HeadingAnchor{ID: []byte(id)})
+	}
+	pc.Set(headingIDsKey, ids)
+}
+
+func headingID(heading *ast.Heading) string {
+	value, ok := heading.AttributeString("id")
+	id, isBytes := value.([]byte)
+	if !ok || !isBytes {
+		return ""
+	}
+	return string(id)
+}
+
+type headingAnchorRenderer struct{}
+
+func (r *headingAnchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindHeadingAnchor, r.renderHeadingAnchor)
+}
+
+// The anchor has no text of its own,
+// the stylesheet shows it when hovering the heading.
+func (r *headingAnchorRenderer) renderHeadingAnchor(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(`<a class="heading-anchor" href="#`)
+		_, _ = w.Write(util.EscapeHTML(n.(*HeadingAnchor).ID))
+		_, _ = w.WriteString(`" aria-label="Link to this section"></a>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type headingExtension struct{}
+
+func (e *headingExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithHeadingAttribute(),
+		parser.WithASTTransformers(
+			util.Prioritized(&headingTransformer{}, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&headingAnchorRenderer{}, 100),
+		),
+	)
+}
+
+// StripHeadingAnchors removes the anchor links of headings, because
+// emails, feed readers and ActivityPub servers don't load the stylesheet
+// which hides them, and deep links work on the blog itself only.
+func StripHeadingAnchors(content template.HTML) template.HTML {
+	var sb strings.Builder
+	// depth counts the open links of an anchor which get skipped:
+	depth := 0
+	z := html.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			//nolint: gosec // only removes elements of the blog post
+			return template.HTML(sb.String())
+		}
+		raw := string(z.Raw())
+		token := z.Token()
+		switch {
+		case tt == html.StartTagToken && token.Data == "a" && depth > 0:
+			depth++
+			continue
+		case tt == html.StartTagToken && token.Data == "a":
+			if class, _ := attr(token.Attr, "class"); slices.Contains(strings.Fields(class), "heading-anchor") {
+				depth = 1
+				continue
+			}
+		case tt == html.EndTagToken && token.Data == "a" && depth > 0:
+			depth--
+			continue
+		}
+		if depth == 0 {
+			sb.WriteString(raw)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package blog
+
+import (
+	"html/template"
+	"slices"
+	"testing"
+)
+
+func TestHeadings(t *testing.T) {
+	anchor := func(id string) string {
+		return `<a class="heading-anchor" href="#` + id + `" aria-label="Link to this section"></a>`
+	}
+
+	tests := []struct {
+		name     string
+		markdown string
+		expected string
+		ids      []string
+	}{
+		{
+			name:     "generated id",
+			markdown: "## Generating RSA keys\n",
+			expected: `<h2 id="generating-rsa-keys">Generating RSA keys` + anchor("generating-rsa-keys") + "</h2>\n",
+			ids:      []string{"generating-rsa-keys"},
+		},
+		{
+			name:     "custom id",
+			markdown: "## Generating RSA keys {#keygen}\n",
+			expected: `<h2 id="keygen">Generating RSA keys` + anchor("keygen") + "</h2>\n",
+			ids:      []string{"keygen"},
+		},
+		{
+			name:     "same text",
+			markdown: "## Setup\n\n## Setup\n",
+			expected: `<h2 id="setup">Setup` + anchor("setup") + "</h2>\n" +
+				`<h2 id="setup-1">Setup` + anchor("setup-1") + "</h2>\n",
+			ids: []string{"setup", "setup-1"},
+		},
+		{
+			name:     "custom id of an earlier heading",
+			markdown: "## Setup {#keygen}\n\n## Keygen\n",
+			expected: `<h2 id="keygen">Setup` + anchor("keygen") + "</h2>\n" +
+				`<h2 id="keygen-1">Keygen` + anchor("keygen-1") + "</h2>\n",
+			ids: []string{"keygen", "keygen-1"},
+		},
+		{
+			name:     "custom id of a later heading",
+			markdown: "## Keygen\n\n## Setup {#keygen}\n",
+			expected: `<h2 id="keygen">Keygen` + anchor("keygen") + "</h2>\n" +
+				`<h2 id="keygen-1">Setup` + anchor("keygen-1") + "</h2>\n",
+			ids: []string{"keygen", "keygen-1"},
+		},
+		{
+			name:     "same custom ids",
+			markdown: "## Setup {#keygen}\n\n## Keygen {#keygen}\n\n## Keygen 1\n",
+			expected: `<h2 id="keygen">Setup` + anchor("keygen") + "</h2>\n" +
+				`<h2 id="keygen-2">Keygen` + anchor("keygen-2") + "</h2>\n" +
+				`<h2 id="keygen-1">Keygen 1` + anchor("keygen-1") + "</h2>\n",
+			ids: []string{"keygen", "keygen-2", "keygen-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html, ids, err := computeTemplate(test.markdown, nil, "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(html) != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, html)
+			}
+			if !slices.Equal(ids, test.ids) {
+				t.Errorf("expected ids %v, got %v", test.ids, ids)
+			}
+		})
+	}
+}
+
+func TestStripHeadingAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     template.HTML
+		expected template.HTML
+	}{
+		{
+			name:     "anchor",
+			html:     `<h2 id="setup">Setup<a class="heading-anchor" href="#setup" aria-label="Link to this section"></a></h2>`,
+			expected: `<h2 id="setup">Setup</h2>`,
+		},
+		{
+			name:     "other links",
+			html:     `<p><a href="#setup">Setup</a> and <a class="external" href="https://dusted.codes">more</a></p>`,
+			expected: `<p><a href="#setup">Setup</a> and <a class="external" href="https://dusted.codes">more</a></p>`,
+		},
+		{
+			name:     "anchor with content",
+			html:     `<h3>Keys<a class="heading-anchor" href="#keys"><span>#</span></a> after</h3>`,
+			expected: `<h3>Keys after</h3>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := StripHeadingAnchors(test.html); actual != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+// ImageWarning reports Open Graph image metadata of a blog post
+// which doesn't match the image in the local mirror of the CDN.
+type ImageWarning struct {
+	BlogPostID string
+	Field      string
+	Declared   string
+	Detected   string
+}
+
+func (w ImageWarning) String() string {
+	if len(w.Declared) == 0 {
+		return fmt.Sprintf("blog post '%s': %s", w.BlogPostID, w.Detected)
+	}
+	return fmt.Sprintf("blog post '%s': Image.%s is '%s', but the image in the CDN mirror has '%s'",
+		w.BlogPostID, w.Field, w.Declared, w.Detected)
+}
+
+// cdnPath returns the path of a CDN URL relative to the CDN.
+// It returns false for URLs which aren't served by the CDN.
+func cdnPath(imageURL string, cdnBaseURL string) (string, bool) {
+	rel, ok := strings.CutPrefix(imageURL, strings.TrimSuffix(cdnBaseURL, "/")+"/")
+	if !ok {
+		return "", false
+	}
+	rel, _, _ = strings.Cut(rel, "?")
+	rel, _, _ = strings.Cut(rel, "#")
+	rel, err := url.PathUnescape(rel)
+	if err != nil {
+		return "", false
+	}
+	// Cleaning a rooted path removes ".." elements,
+	// which keeps the file inside the mirror:
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if len(rel) == 0 {
+		return "", false
+	}
+	return rel, true
+}
+
+// mirrorFile maps a CDN URL onto a file inside the mirror directory.
+// It returns false for URLs which aren't served by the CDN.
+func mirrorFile(imageURL string, cdnBaseURL string, mirrorPath string) (string, bool) {
+	rel, ok := cdnPath(imageURL, cdnBaseURL)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(mirrorPath, filepath.FromSlash(rel)), true
+}
+
+// DetectImage fills in missing Open Graph image metadata of a blog post
+// from the local mirror of the CDN, if the image is served by the CDN.
+// Metadata which has been set explicitly is kept, but mismatches with
+// the actual image are returned as warnings.
+func DetectImage(blogPost *Post, cdnBaseURL string, mirrorPath string) ([]ImageWarning, error) {
+	img := &blogPost.OpenGraphImage
+	fileName, ok := mirrorFile(img.URL, cdnBaseURL, mirrorPath)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := os.Open(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []ImageWarning{{
+			BlogPostID: blogPost.ID,
+			Field:      "URL",
+			Detected:   fmt.Sprintf("image '%s' doesn't exist in the CDN mirror", img.URL),
+		}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening image '%s': %w", fileName, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file info of image '%s': %w", fileName, err)
+	}
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return []ImageWarning{{
+			BlogPostID: blogPost.ID,
+			Field:      "URL",
+			Detected:   fmt.Sprintf("image '%s' can't be decoded: %s", img.URL, err),
+		}}, nil
+	}
+
+	warnings := []ImageWarning{}
+	detectInt := func(field string, declared *int, detected int) {
+		if *declared == 0 {
+			*declared = detected
+		} else if *declared != detected {
+			warnings = append(warnings, ImageWarning{
+				BlogPostID: blogPost.ID,
+				Field:      field,
+				Declared:   strconv.Itoa(*declared),
+				Detected:   strconv.Itoa(detected),
+			})
+		}
+	}
+	detectInt("Width", &img.Width, config.Width)
+	detectInt("Height", &img.Height, config.Height)
+	detectInt("Size", &img.Size, int(info.Size()))
+
+	mimeType := "image/" + format
+	if len(img.MimeType) == 0 {
+		img.MimeType = mimeType
+	} else if !strings.EqualFold(img.MimeType, mimeType) {
+		warnings = append(warnings, ImageWarning{
+			BlogPostID: blogPost.ID,
+			Field:      "MimeType",
+			Declared:   img.MimeType,
+			Detected:   mimeType,
+		})
+	}
+
+	return warnings, nil
+}
+
+// DetectImages detects the Open Graph image metadata of all blog posts
+// and logs a warning for each mismatch. Nothing gets detected when
+// the CDN mirror doesn't exist, which is the case in production.
+func DetectImages(
+	ctx context.Context,
+	blogPosts []*Post,
+	cdnBaseURL string,
+	mirrorPath string,
+) ([]ImageWarning, error) {
+	logger := slogctx.GetLogger(ctx)
+	if _, err := os.Stat(mirrorPath); err != nil {
+		logger.Debug("Skipping detection of Open Graph images, because the CDN mirror is not available.",
+			"path", mirrorPath)
+		return nil, nil
+	}
+
+	warnings := []ImageWarning{}
+	for _, blogPost := range blogPosts {
+		postWarnings, err := DetectImage(blogPost, cdnBaseURL, mirrorPath)
+		if err != nil {
+			return nil, fmt.Errorf("error detecting Open Graph image of blog post '%s': %w", blogPost.ID, err)
+		}
+		for _, warning := range postWarnings {
+			logger.Warn("Open Graph image metadata doesn't match the CDN mirror.",
+				"warning", warning.String())
+		}
+		warnings = append(warnings, postWarnings...)
+	}
+	return warnings, nil
+}
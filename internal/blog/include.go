@@ -0,0 +1,239 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The directories next to the articles directory which blog posts can
+// include files from.
+const (
+	examplesDir = "examples"
+	gistsDir    = "gists"
+)
+
+// includeDirs are the directories which blog posts can include files
+// from. Each of them is a root of its own, so that paths can't leave it.
+type includeDirs struct {
+	examples fs.FS
+	gists    fs.FS
+	roots    []*os.Root
+}
+
+// openIncludes opens the examples and gists directories next to the
+// directory with the blog posts.
+func openIncludes(basePath string) (*includeDirs, error) {
+	parent := filepath.Dir(filepath.Clean(basePath))
+	dirs := &includeDirs{}
+	examples, err := dirs.open(filepath.Join(parent, examplesDir))
+	if err != nil {
+		return nil, err
+	}
+	gists, err := dirs.open(filepath.Join(parent, gistsDir))
+	if err != nil {
+		_ = dirs.Close()
+		return nil, err
+	}
+	dirs.examples, dirs.gists = examples, gists
+	return dirs, nil
+}
+
+// open returns the files of a directory, a directory
+// which doesn't exist has no files.
+func (d *includeDirs) open(dir string) (fs.FS, error) {
+	root, err := os.OpenRoot(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return missingDir{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening the directory for included files: %w", err)
+	}
+	d.roots = append(d.roots, root)
+	return root.FS(), nil
+}
+
+func (d *includeDirs) Close() error {
+	errs := []error{}
+	for _, root := range d.roots {
+		errs = append(errs, root.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// missingDir is an include directory which doesn't exist.
+type missingDir struct{}
+
+func (missingDir) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// includeLanguages maps file extensions to languages
+// where they differ from the extension:
+var includeLanguages = map[string]string{
+	"cs":  "csharp",
+	"fs":  "fsharp",
+	"js":  "javascript",
+	"md":  "markdown",
+	"py":  "python",
+	"rs":  "rust",
+	"sh":  "bash",
+	"ts":  "typescript",
+	"yml": "yaml",
+}
+
+// includeFile embeds a file as a fenced code block:
+//
+//	{{< include "rsa/main.go" >}}
+//	{{< include "rsa/main.go" lines="10-40" >}}
+//	{{< include "rsa/main.go" region="keygen" lang="go" >}}
+//
+// Paths are relative to the examples directory next to the articles
+// directory and can't leave it, a leading "examples/" is optional.
+// A region is the code between lines which contain the markers
+// [START name] and [END name], usually in comments. The language
+// defaults to the file extension.
+func includeFile(sc *shortcode, ctx *shortcodeContext) (string, error) {
+	if err := sc.checkParams("lines", "region", "lang"); err != nil {
+		return "", err
+	}
+	if len(sc.args) != 1 {
+		return "", errors.New("include needs exactly one path, e.g. {{< include \"rsa/main.go\" >}}")
+	}
+	name := sc.args[0]
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid path to include: %s", name)
+	}
+	data, err := fs.ReadFile(ctx.files.examples, strings.TrimPrefix(name, "examples/"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("can't include '%s', the file doesn't exist", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error including '%s': %w", name, err)
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	lineRange, hasLines := sc.params["lines"]
+	region, hasRegion := sc.params["region"]
+	switch {
+	case hasLines && hasRegion:
+		return "", errors.New("include can have either lines or a region, not both")
+	case hasLines:
+		lines, err = selectLines(lines, lineRange)
+	case hasRegion:
+		lines, err = selectRegion(lines, region)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error including '%s': %w", name, err)
+	}
+
+	language, ok := sc.params["lang"]
+	if !ok {
+		language = strings.TrimPrefix(path.Ext(name), ".")
+		if l, ok := includeLanguages[language]; ok {
+			language = l
+		}
+	}
+	return fencedCode(language, dedent(lines)), nil
+}
+
+// selectLines returns the lines of a range like 10-40 or 10.
+func selectLines(lines []string, lineRange string) ([]string, error) {
+	from, to, isRange := strings.Cut(lineRange, "-")
+	if !isRange {
+		to = from
+	}
+	first, err1 := strconv.Atoi(strings.TrimSpace(from))
+	last, err2 := strconv.Atoi(strings.TrimSpace(to))
+	if err1 != nil || err2 != nil || first < 1 || last < first {
+		return nil, fmt.Errorf("invalid lines '%s', expected a range like 10-40", lineRange)
+	}
+	if last > len(lines) {
+		return nil, fmt.Errorf("lines %s are out of range, the file has %d lines", lineRange, len(lines))
+	}
+	return lines[first-1 : last], nil
+}
+
+// selectRegion returns the lines between the markers of a region,
+// without the markers of the region or of regions nested in it.
+func selectRegion(lines []string, region string) ([]string, error) {
+	start, end := "[START "+region+"]", "[END "+region+"]"
+	selected := []string{}
+	inRegion, found := false, false
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, start):
+			if found {
+				return nil, fmt.Errorf("region '%s' is defined more than once", region)
+			}
+			inRegion, found = true, true
+		case strings.Contains(line, end):
+			if !inRegion {
+				return nil, fmt.Errorf("region '%s' ends before it starts", region)
+			}
+			inRegion = false
+		case inRegion && !isRegionMarker(line):
+			selected = append(selected, line)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("region '%s' not found", region)
+	}
+	if inRegion {
+		return nil, fmt.Errorf("region '%s' has no end marker", region)
+	}
+	return selected, nil
+}
+
+func isRegionMarker(line string) bool {
+	return strings.Contains(line, "[START ") || strings.Contains(line, "[END ")
+}
+
+// dedent removes the indentation which all non-empty lines have in common.
+func dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		dedented[i] = strings.TrimPrefix(line, prefix)
+	}
+	return dedented
+}
+
+// fencedCode returns a fenced code block with a fence
+// which is longer than any backtick fence in the code.
+func fencedCode(language string, lines []string) string {
+	fence := 3
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if n := len(trimmed) - len(strings.TrimLeft(trimmed, "`")); n >= fence {
+			fence = n + 1
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("`", fence) + language + "\n")
+	for _, line := range lines {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString(strings.Repeat("`", fence) + "\n")
+	return sb.String()
+}
@@ -0,0 +1,116 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var noIncludes = &includeDirs{examples: missingDir{}, gists: missingDir{}}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	dist := t.TempDir()
+	writeTestFile(t, filepath.Join(dist, "articles", "2024_01_01_example.md"), "<!--\n-->\n\n# Example\n")
+	writeTestFile(t, filepath.Join(dist, "examples", "rsa", "main.go"), "package main\n\n// [START keygen]\nfunc keygen() {}\n// [END keygen]\n")
+	writeTestFile(t, filepath.Join(dist, "gists", "5fa8fb0bd", "Program.fs"), "printfn \"Hello\"\n")
+	writeTestFile(t, filepath.Join(dist, "secret.txt"), "secret")
+
+	dirs, err := openIncludes(filepath.Join(dist, "articles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dirs.Close()
+	}()
+
+	tests := []struct {
+		name     string
+		markdown string
+		expected string
+		err      string
+	}{
+		{
+			name:     "example",
+			markdown: `{{< include "rsa/main.go" region="keygen" >}}`,
+			expected: "```go\nfunc keygen() {}\n```\n",
+		},
+		{
+			name:     "example with the directory",
+			markdown: `{{< include "examples/rsa/main.go" lines="1" >}}`,
+			expected: "```go\npackage main\n```\n",
+		},
+		{
+			name:     "gist",
+			markdown: `{{< gist "dustinmoris/5fa8fb0bd" >}}`,
+			expected: "```fsharp {title=\"Program.fs\"}\nprintfn \"Hello\"\n```\n",
+		},
+		{
+			name:     "file next to the examples",
+			markdown: `{{< include "../secret.txt" >}}`,
+			err:      "invalid path to include: ../secret.txt",
+		},
+		{
+			name:     "file next to the examples with the directory",
+			markdown: `{{< include "examples/../secret.txt" >}}`,
+			err:      "invalid path to include: examples/../secret.txt",
+		},
+		{
+			name:     "articles",
+			markdown: `{{< include "articles/2024_01_01_example.md" >}}`,
+			err:      "can't include 'articles/2024_01_01_example.md', the file doesn't exist",
+		},
+		{
+			name:     "gists",
+			markdown: `{{< include "gists/5fa8fb0bd/Program.fs" >}}`,
+			err:      "can't include 'gists/5fa8fb0bd/Program.fs', the file doesn't exist",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, _, err := expandShortcodes(test.markdown+"\n", &shortcodeContext{files: dirs})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(actual, test.expected) {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIncludesWithoutDirectories(t *testing.T) {
+	dirs, err := openIncludes(filepath.Join(t.TempDir(), "articles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dirs.Close()
+	}()
+
+	_, _, err = expandShortcodes("{{< include \"rsa/main.go\" >}}\n", &shortcodeContext{files: dirs})
+	if err == nil || !strings.Contains(err.Error(), "the file doesn't exist") {
+		t.Errorf("expected a missing file, got %v", err)
+	}
+	_, _, err = expandShortcodes("{{< gist \"dustinmoris/5fa8fb0bd\" >}}\n", &shortcodeContext{files: dirs})
+	if err == nil || !strings.Contains(err.Error(), "isn't vendored in gists/5fa8fb0bd") {
+		t.Errorf("expected a missing gist, got %v", err)
+	}
+}
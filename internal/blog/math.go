@@ -0,0 +1,264 @@
+package blog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/dustedcodes/blog/internal/mathml"
+)
+
+// mathExtension renders TeX between $...$ (inline) and $$...$$ (display)
+// as MathML. Display math can span multiple lines:
+//
+//	$$
+//	c = m^e \bmod n
+//	$$
+//
+// An opening $ must not be followed by a space and a closing $ must not
+// be preceded by a space or followed by a digit, so that amounts like
+// $5 and $10 stay text.
+type mathExtension struct{}
+
+var (
+	KindMathInline = ast.NewNodeKind("MathInline")
+	KindMathBlock  = ast.NewNodeKind("MathBlock")
+)
+
+// MathInline is inline or display math within a paragraph.
+type MathInline struct {
+	ast.BaseInline
+	MathML string
+}
+
+func (n *MathInline) Kind() ast.NodeKind {
+	return KindMathInline
+}
+
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// MathBlock is display math on lines of its own.
+type MathBlock struct {
+	ast.BaseBlock
+	MathML string
+	offset int
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// renderMath converts TeX into MathML and reports syntax errors
+// at their offset in the Markdown source.
+func renderMath(pc parser.Context, tex string, display bool, offset func(pos int) int) string {
+	html, err := mathml.Render(tex, display)
+	if err != nil {
+		pos := 0
+		var syntaxErr *mathml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			pos = syntaxErr.Pos
+		}
+		reportError(pc, offset(pos), fmt.Errorf("invalid math '%s': %w", strings.Join(strings.Fields(tex), " "), err))
+	}
+	return html
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	display := len(line) > 1 && line[1] == '$'
+	delimiter := 1
+	if display {
+		delimiter = 2
+	}
+	if len(line) <= delimiter || (!display && util.IsSpace(line[1])) {
+		return nil
+	}
+
+	end := -1
+	for i := delimiter; i < len(line) && end < 0; i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] != '$':
+		case display:
+			if i+1 < len(line) && line[i+1] == '$' {
+				end = i
+			}
+		case !util.IsSpace(line[i-1]) && (i+1 == len(line) || !isDigit(line[i+1])):
+			end = i
+		}
+	}
+	if end <= delimiter {
+		return nil
+	}
+
+	block.Advance(end + delimiter)
+	start := segment.Start + delimiter
+	return &MathInline{
+		MathML: renderMath(pc, string(line[delimiter:end]), display, func(pos int) int {
+			return start + pos
+		}),
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &MathBlock{offset: segment.Start + pos}
+	rest := util.TrimRightSpace(line[pos+2:])
+	start := segment.Start + pos + 2
+	if closing := bytes.Index(rest, []byte("$$")); closing >= 0 {
+		// Text after the closing $$ makes it inline math:
+		if closing != len(rest)-2 {
+			return nil, parser.NoChildren
+		}
+		node.Lines().Append(text.NewSegment(start, start+closing))
+		node.closed = true
+	} else if len(util.TrimLeftSpace(rest)) > 0 {
+		node.Lines().Append(text.NewSegment(start, segment.Stop))
+	}
+	advanceLine(reader)
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	mathBlock := node.(*MathBlock)
+	if mathBlock.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	trimmed := util.TrimRightSpace(line)
+	if bytes.HasSuffix(trimmed, []byte("$$")) {
+		node.Lines().Append(text.NewSegment(segment.Start, segment.Start+len(trimmed)-2))
+		mathBlock.closed = true
+		advanceLine(reader)
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	advanceLine(reader)
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	mathBlock := node.(*MathBlock)
+	source := reader.Source()
+	lines := node.Lines()
+	if !mathBlock.closed {
+		reportError(pc, mathBlock.offset, errors.New("display math is missing the closing $$"))
+		return
+	}
+
+	var tex bytes.Buffer
+	for i := range lines.Len() {
+		segment := lines.At(i)
+		tex.Write(segment.Value(source))
+	}
+	mathBlock.MathML = renderMath(pc, tex.String(), true, func(pos int) int {
+		for i := range lines.Len() {
+			segment := lines.At(i)
+			if pos < segment.Len() {
+				return segment.Start + pos
+			}
+			pos -= segment.Len()
+		}
+		return mathBlock.offset
+	})
+}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderMathInline)
+	reg.Register(KindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMathInline(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(n.(*MathInline).MathML)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(
+	w util.BufWriter,
+	source []byte,
+	n ast.Node,
+	entering bool,
+) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(n.(*MathBlock).MathML)
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(&mathBlockParser{}, 95),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(&mathInlineParser{}, 150),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&mathRenderer{}, 100),
+		),
+	)
+}
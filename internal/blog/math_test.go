@@ -0,0 +1,127 @@
+package blog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mathNamespace = `<math xmlns="http://www.w3.org/1998/Math/MathML"`
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		expected string
+	}{
+		{
+			name:     "inline",
+			markdown: "Euler: $e^{i\\pi} = -1$.\n",
+			expected: "<p>Euler: " + mathNamespace + "><semantics><mrow><msup><mi>e</mi><mrow><mi>i</mi><mi>π</mi></mrow></msup>" +
+				"<mo>=</mo><mo>−</mo><mn>1</mn></mrow><annotation encoding=\"application/x-tex\">e^{i\\pi} = -1</annotation>" +
+				"</semantics></math>.</p>\n",
+		},
+		{
+			name:     "display on one line",
+			markdown: "$$x^2$$\n",
+			expected: mathNamespace + " display=\"block\"><semantics><msup><mi>x</mi><mn>2</mn></msup>" +
+				"<annotation encoding=\"application/x-tex\">x^2</annotation></semantics></math>\n",
+		},
+		{
+			name:     "display block",
+			markdown: "$$\n\\frac{1}{2}\n$$\n",
+			expected: mathNamespace + " display=\"block\"><semantics><mfrac><mn>1</mn><mn>2</mn></mfrac>" +
+				"<annotation encoding=\"application/x-tex\">\\frac{1}{2}</annotation></semantics></math>\n",
+		},
+		{
+			name:     "amounts of money",
+			markdown: "It costs $5 and $10.\n",
+			expected: "<p>It costs $5 and $10.</p>\n",
+		},
+		{
+			name:     "escaped dollar",
+			markdown: "Escaped \\$x$ here.\n",
+			expected: "<p>Escaped $x$ here.</p>\n",
+		},
+		{
+			name:     "code span",
+			markdown: "`$x$`\n",
+			expected: "<p><code>$x$</code></p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html, _, err := computeTemplate(test.markdown, []string{"math"}, "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(html) != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, html)
+			}
+		})
+	}
+}
+
+func TestMathIsDisabledByDefault(t *testing.T) {
+	html, _, err := computeTemplate("$x^2$\n", nil, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<p>$x^2$</p>\n"; string(html) != expected {
+		t.Errorf("expected %q, got %q", expected, html)
+	}
+}
+
+func TestMathErrorLines(t *testing.T) {
+	const header = "<!--\n    Markdown: math\n-->\n\n# Title\n\n"
+
+	tests := []struct {
+		name string
+		body string
+		line int
+		err  string
+	}{
+		{
+			name: "inline",
+			body: "Intro\n\nSee $\\frac{1}{$ here.\n",
+			line: 9,
+			err:  `invalid math '\frac{1}{': missing closing brace`,
+		},
+		{
+			name: "inline on a continued line",
+			body: "Intro\nsecond $x^2^3$ line\n",
+			line: 8,
+			err:  "invalid math 'x^2^3': double superscript",
+		},
+		{
+			name: "display block",
+			body: "Intro\n\n$$\nx\n\\foo\n$$\n",
+			line: 11,
+			err:  `invalid math 'x \foo': unknown command \foo`,
+		},
+		{
+			name: "unclosed display block",
+			body: "Intro\n\n$$\nx^2\n",
+			line: 9,
+			err:  "display math is missing the closing $$",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parsePost("example", time.Now(), []byte(header+test.body), noIncludes, Options{})
+			var lineErr *LineError
+			if !errors.As(err, &lineErr) {
+				t.Fatalf("expected a LineError, got %v", err)
+			}
+			if lineErr.Line != test.line {
+				t.Errorf("expected line %d, got %d", test.line, lineErr.Line)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing '%s', got '%s'", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package blog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint: gosec // used for cache invalidation
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dusted-go/logging/v2/slogctx"
+	"golang.org/x/image/draw"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/dustedcodes/blog/internal/webp"
+)
+
+const (
+	DefaultImageManifestPath = "dist/images.txt"
+
+	jpegQuality = 85
+
+	// Images are at most as wide as the content of an article,
+	// images with the half-width class take half of it:
+	imageSizes     = "(min-width: 48rem) 48rem, 100vw"
+	halfImageSizes = "(min-width: 48rem) 24rem, 50vw"
+)
+
+// ResponsiveImage is an image in the CDN and its resized variants,
+// which are next to the image: a.png has the variants a-480w.jpg,
+// a-480w.webp and so on.
+type ResponsiveImage struct {
+	// The path relative to the CDN:
+	Path   string
+	Width  int
+	Height int
+	// The widths of the variants, an image without variants
+	// only gets its size and lazy loading:
+	Widths []int
+	// Variants are always JPEGs and also lossless WebPs when
+	// they are smaller or the image is transparent:
+	WebP bool
+}
+
+func (img *ResponsiveImage) variant(width int, ext string) string {
+	return strings.TrimSuffix(img.Path, path.Ext(img.Path)) + "-" + strconv.Itoa(width) + "w." + ext
+}
+
+func (img *ResponsiveImage) srcset(cdnBaseURL string, ext string) string {
+	candidates := []string{}
+	for _, width := range img.Widths {
+		candidates = append(candidates, cdnBaseURL+"/"+img.variant(width, ext)+" "+strconv.Itoa(width)+"w")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// ImageManifest lists the responsive images by their path in the CDN.
+// It gets committed with the blog posts, because the CDN mirror
+// isn't available in production.
+type ImageManifest map[string]*ResponsiveImage
+
+// LoadImageManifest reads the manifest of responsive images.
+// A missing manifest is empty.
+//
+// Format:
+//
+//	<path> <width>x<height> <webp,jpeg|jpeg|-> [<variant widths>...]
+func LoadImageManifest(manifestPath string) (ImageManifest, error) {
+	manifest := ImageManifest{}
+	f, err := os.Open(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening image manifest: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		img, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image manifest in line %d: %w", lineNumber, err)
+		}
+		manifest[img.Path] = img
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading image manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func parseManifestLine(line string) (*ResponsiveImage, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected a path, a size and formats: %s", line)
+	}
+	img := &ResponsiveImage{Path: fields[0]}
+	w, h, ok := strings.Cut(fields[1], "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width < 1 || height < 1 {
+		return nil, fmt.Errorf("invalid size: %s", fields[1])
+	}
+	img.Width, img.Height = width, height
+	switch fields[2] {
+	case "webp,jpeg":
+		img.WebP = true
+	case "jpeg", "-":
+	default:
+		return nil, fmt.Errorf("invalid formats: %s", fields[2])
+	}
+	for _, field := range fields[3:] {
+		width, err := strconv.Atoi(field)
+		if err != nil || width < 1 {
+			return nil, fmt.Errorf("invalid width: %s", field)
+		}
+		img.Widths = append(img.Widths, width)
+	}
+	if fields[2] == "-" && len(img.Widths) > 0 {
+		return nil, errors.New("variants need a format")
+	}
+	return img, nil
+}
+
+func (img *ResponsiveImage) manifestLine() string {
+	formats := "-"
+	if len(img.Widths) > 0 {
+		formats = "jpeg"
+		if img.WebP {
+			formats = "webp,jpeg"
+		}
+	}
+	line := fmt.Sprintf("%s %dx%d %s", img.Path, img.Width, img.Height, formats)
+	for _, width := range img.Widths {
+		line += " " + strconv.Itoa(width)
+	}
+	return line
+}
+
+// Save writes the manifest sorted by path.
+func (m ImageManifest) Save(manifestPath string) error {
+	var sb strings.Builder
+	sb.WriteString("# Responsive images in the CDN, generated by 'blog images', do not edit.\n")
+	sb.WriteString("#\n# Format:\n#   <path> <width>x<height> <formats> [<variant widths>...]\n\n")
+	paths := []string{}
+	for p := range m {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	for _, p := range paths {
+		sb.WriteString(m[p].manifestLine() + "\n")
+	}
+	if err := os.WriteFile(manifestPath, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("error writing image manifest: %w", err)
+	}
+	return nil
+}
+
+// imageSources returns the sources of all images in HTML.
+func imageSources(content string) []string {
+	sources := []string{}
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return sources
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom != atom.Img {
+				continue
+			}
+			if src, ok := attr(token.Attr, "src"); ok {
+				sources = append(sources, src)
+			}
+		}
+	}
+}
+
+func attr(attrs []html.Attribute, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// GenerateImageVariants resizes all images of blog posts which are in the
+// local mirror of the CDN to the given widths and writes the variants next
+// to the images, ready for the upload to the CDN. Images which didn't change
+// since their variants were generated are skipped. The manifest gets updated.
+func GenerateImageVariants(
+	ctx context.Context,
+	blogPosts []*Post,
+	cdnBaseURL string,
+	mirrorPath string,
+	widths []int,
+	manifest ImageManifest,
+) error {
+	logger := slogctx.GetLogger(ctx)
+	if _, err := os.Stat(mirrorPath); err != nil {
+		return fmt.Errorf("the CDN mirror is not available: %w", err)
+	}
+
+	done := map[string]bool{}
+	for _, blogPost := range blogPosts {
+		for _, src := range imageSources(string(blogPost.HTML)) {
+			rel, ok := cdnPath(src, cdnBaseURL)
+			if !ok || done[rel] {
+				continue
+			}
+			done[rel] = true
+			fileName := filepath.Join(mirrorPath, filepath.FromSlash(rel))
+			if _, err := os.Stat(fileName); err != nil {
+				logger.Warn("Image doesn't exist in the CDN mirror.",
+					"blogPostID", blogPost.ID,
+					"image", src)
+				continue
+			}
+			img, err := generateVariants(fileName, rel, widths, manifest[rel])
+			if err != nil {
+				return fmt.Errorf("error generating variants of image '%s': %w", src, err)
+			}
+			if img == nil {
+				continue
+			}
+			if img != manifest[rel] {
+				logger.Info("Generated image variants.",
+					"image", rel,
+					"widths", img.Widths,
+					"webp", img.WebP)
+			}
+			manifest[rel] = img
+		}
+	}
+	return nil
+}
+
+// variantWidths are the widths which are smaller than the image,
+// plus the width of the image when it is smaller than the largest.
+func variantWidths(imageWidth int, widths []int) []int {
+	variants := []int{}
+	for _, width := range widths {
+		if width < imageWidth {
+			variants = append(variants, width)
+		}
+	}
+	if len(widths) > 0 && imageWidth < slices.Max(widths) {
+		variants = append(variants, imageWidth)
+	}
+	slices.Sort(variants)
+	return slices.Compact(variants)
+}
+
+// generateVariants returns nil for images which aren't PNGs,
+// JPEGs or GIFs. Only the size of GIFs is recorded, because
+// they are often animated.
+func generateVariants(fileName string, rel string, widths []int, existing *ResponsiveImage) (*ResponsiveImage, error) {
+	ext := strings.ToLower(path.Ext(rel))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" && ext != ".gif" {
+		return nil, nil
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && variantsUpToDate(fileName, info, existing, widths) {
+		return existing, nil
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if ext == ".gif" {
+		config, _, err := image.DecodeConfig(f)
+		if err != nil {
+			return nil, err
+		}
+		return &ResponsiveImage{Path: rel, Width: config.Width, Height: config.Height}, nil
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	img := &ResponsiveImage{
+		Path:   rel,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Widths: variantWidths(bounds.Dx(), widths),
+	}
+	jpegs, webps := map[int][]byte{}, map[int][]byte{}
+	jpegSize, webpSize := 0, 0
+	transparent := false
+	for _, width := range img.Widths {
+		height := max(1, (img.Height*width+img.Width/2)/img.Width)
+		resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), src, bounds, draw.Src, nil)
+		transparent = transparent || !resized.Opaque()
+
+		var buf bytes.Buffer
+		if err := webp.Encode(&buf, resized); err != nil {
+			return nil, err
+		}
+		webps[width] = buf.Bytes()
+		webpSize += buf.Len()
+
+		// JPEGs have no transparency, so they get a white background:
+		flattened := image.NewRGBA(resized.Bounds())
+		draw.Draw(flattened, flattened.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flattened, flattened.Bounds(), resized, image.Point{}, draw.Over)
+		buf = bytes.Buffer{}
+		if err := jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		jpegs[width] = buf.Bytes()
+		jpegSize += buf.Len()
+	}
+	img.WebP = transparent || webpSize < jpegSize
+
+	dir := filepath.Dir(fileName)
+	for _, width := range img.Widths {
+		err := os.WriteFile(filepath.Join(dir, path.Base(img.variant(width, "jpg"))), jpegs[width], 0o644)
+		if err != nil {
+			return nil, err
+		}
+		webpFile := filepath.Join(dir, path.Base(img.variant(width, "webp")))
+		if img.WebP {
+			err = os.WriteFile(webpFile, webps[width], 0o644)
+		} else {
+			err = os.Remove(webpFile)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// variantsUpToDate reports whether the variants exist for the
+// widths and are newer than the image.
+func variantsUpToDate(fileName string, info fs.FileInfo, img *ResponsiveImage, widths []int) bool {
+	if strings.EqualFold(path.Ext(img.Path), ".gif") {
+		return true
+	}
+	if !slices.Equal(img.Widths, variantWidths(img.Width, widths)) {
+		return false
+	}
+	dir := filepath.Dir(fileName)
+	for _, width := range img.Widths {
+		exts := []string{"jpg"}
+		if img.WebP {
+			exts = append(exts, "webp")
+		}
+		for _, ext := range exts {
+			variant, err := os.Stat(filepath.Join(dir, path.Base(img.variant(width, ext))))
+			if err != nil || variant.ModTime().Before(info.ModTime()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// UseResponsiveImages rewrites the images of a blog post which are in the
+// manifest. Images with variants get a srcset of JPEGs and become a picture
+// with a WebP source if there are WebP variants. All of them get their size
+// and are loaded lazily. The manifest entries of the images go into the
+// hash code of the blog post, so that caches notice new variants.
+func UseResponsiveImages(blogPost *Post, manifest ImageManifest, cdnBaseURL string) {
+	if len(manifest) == 0 {
+		return
+	}
+	cdnBaseURL = strings.TrimSuffix(cdnBaseURL, "/")
+
+	var sb strings.Builder
+	var used strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(string(blogPost.HTML)))
+	inPicture := 0
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		raw := tokenizer.Raw()
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken && tokenType != html.EndTagToken {
+			sb.Write(raw)
+			continue
+		}
+		raw = slices.Clone(raw)
+		token := tokenizer.Token()
+		switch {
+		case token.DataAtom == atom.Picture && tokenType == html.StartTagToken:
+			inPicture++
+		case token.DataAtom == atom.Picture && tokenType == html.EndTagToken:
+			inPicture--
+		case token.DataAtom == atom.Img && tokenType != html.EndTagToken && inPicture == 0:
+			src, _ := attr(token.Attr, "src")
+			rel, ok := cdnPath(src, cdnBaseURL)
+			if img := manifest[rel]; ok && img != nil {
+				sb.WriteString(responsiveImage(token, img, cdnBaseURL))
+				used.WriteString(img.manifestLine() + "\n")
+				continue
+			}
+		}
+		sb.Write(raw)
+	}
+
+	//nolint: gosec // rewritten from HTML which is safe
+	blogPost.HTML = template.HTML(sb.String())
+	if used.Len() > 0 {
+		//nolint: gosec // hash used for caching, not security
+		hash := sha1.New()
+		hash.Write([]byte(blogPost.HashCode + "images" + cdnBaseURL + "\n" + used.String()))
+		blogPost.HashCode = hex.EncodeToString(hash.Sum(nil))
+	}
+}
+
+func responsiveImage(token html.Token, img *ResponsiveImage, cdnBaseURL string) string {
+	sizes := imageSizes
+	if class, _ := attr(token.Attr, "class"); containsClass(class, "half-width") {
+		sizes = halfImageSizes
+	}
+	attrs := slices.Clone(token.Attr)
+	setDefault := func(key, value string) {
+		if _, ok := attr(attrs, key); !ok {
+			attrs = append(attrs, html.Attribute{Key: key, Val: value})
+		}
+	}
+	if len(img.Widths) > 0 {
+		setDefault("srcset", img.srcset(cdnBaseURL, "jpg"))
+		setDefault("sizes", sizes)
+	}
+	_, hasWidth := attr(attrs, "width")
+	_, hasHeight := attr(attrs, "height")
+	if !hasWidth && !hasHeight {
+		setDefault("width", strconv.Itoa(img.Width))
+		setDefault("height", strconv.Itoa(img.Height))
+	}
+	setDefault("loading", "lazy")
+
+	// Browsers pick the WebP source when they support it:
+	picture := len(img.Widths) > 0 && img.WebP
+	var sb strings.Builder
+	if picture {
+		fmt.Fprintf(&sb, `<picture><source type="image/webp" srcset="%s" sizes="%s">`,
+			html.EscapeString(img.srcset(cdnBaseURL, "webp")), html.EscapeString(sizes))
+	}
+	sb.WriteString("<img")
+	for _, a := range attrs {
+		sb.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	sb.WriteString(">")
+	if picture {
+		sb.WriteString("</picture>")
+	}
+	return sb.String()
+}
+
+func containsClass(value string, class string) bool {
+	return slices.Contains(strings.Fields(value), class)
+}
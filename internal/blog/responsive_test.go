@@ -0,0 +1,57 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCDN = "https://cdn.dusted.codes"
+
+func TestUseResponsiveImagesChangesTheHashCode(t *testing.T) {
+	newPost := func() *Post {
+		return &Post{
+			HashCode: "abc",
+			HTML:     `<p><img src="https://cdn.dusted.codes/images/a.png" alt="A"></p>`,
+		}
+	}
+	img := &ResponsiveImage{Path: "images/a.png", Width: 1600, Height: 900, Widths: []int{480, 960}}
+
+	tests := []struct {
+		name     string
+		manifest ImageManifest
+		changed  bool
+	}{
+		{"empty manifest", ImageManifest{}, false},
+		{"other images", ImageManifest{"images/b.png": {Path: "images/b.png", Width: 10, Height: 10}}, false},
+		{"image with variants", ImageManifest{"images/a.png": img}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blogPost := newPost()
+			UseResponsiveImages(blogPost, test.manifest, testCDN)
+			if changed := blogPost.HashCode != "abc"; changed != test.changed {
+				t.Errorf("expected the hash code to change: %t, got '%s'", test.changed, blogPost.HashCode)
+			}
+		})
+	}
+
+	before := newPost()
+	UseResponsiveImages(before, ImageManifest{"images/a.png": img}, testCDN)
+	again := newPost()
+	UseResponsiveImages(again, ImageManifest{"images/a.png": img}, testCDN)
+	if before.HashCode != again.HashCode {
+		t.Errorf("expected the same hash code for the same manifest, got '%s' and '%s'", before.HashCode, again.HashCode)
+	}
+
+	webp := *img
+	webp.WebP = true
+	after := newPost()
+	UseResponsiveImages(after, ImageManifest{"images/a.png": &webp}, testCDN)
+	if before.HashCode == after.HashCode {
+		t.Error("expected the hash code to change with the variants of the image")
+	}
+	if !strings.Contains(string(after.HTML), `type="image/webp"`) {
+		t.Errorf("expected a WebP source, got %s", after.HTML)
+	}
+}
@@ -0,0 +1,443 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Shortcodes are directives on lines of their own, which get expanded
+// into Markdown before a blog post gets converted:
+//
+//	{{< include "rsa/main.go" lines="10-40" >}}
+//	{{< youtube "Up7LcbGZFuo" title="Functional programming" >}}
+//
+// Arguments are either quoted positional values or key="value" pairs.
+// Shortcodes which wrap content need a closing shortcode:
+//
+//	{{< details "Show the full output" >}}
+//	Markdown content
+//	{{< /details >}}
+//
+// Shortcodes within fenced code blocks stay as they are.
+var shortcodeLine = regexp.MustCompile(`^\s*\{\{<\s*(/?)([A-Za-z][A-Za-z0-9_-]*)((?:\s+(?:[^>"]|"(?:[^"\\]|\\.)*")*?)?)\s*>\}\}\s*$`)
+
+var shortcodeArg = regexp.MustCompile(`^\s*(?:([A-Za-z][A-Za-z0-9_]*)=)?"((?:[^"\\]|\\.)*)"`)
+
+type shortcode struct {
+	name    string
+	closing bool
+	args    []string
+	params  map[string]string
+}
+
+func parseShortcode(line string) (*shortcode, bool, error) {
+	match := shortcodeLine.FindStringSubmatch(line)
+	if match == nil {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "{{<") && strings.HasSuffix(trimmed, ">}}") {
+			return nil, true, fmt.Errorf("invalid shortcode: %s", trimmed)
+		}
+		return nil, false, nil
+	}
+	sc := &shortcode{
+		name:    match[2],
+		closing: len(match[1]) > 0,
+		params:  map[string]string{},
+	}
+	rest := match[3]
+	for len(strings.TrimSpace(rest)) > 0 {
+		arg := shortcodeArg.FindStringSubmatch(rest)
+		if arg == nil {
+			return nil, true, fmt.Errorf("invalid arguments of shortcode '%s': %s", sc.name, strings.TrimSpace(rest))
+		}
+		rest = rest[len(arg[0]):]
+		value := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(arg[2])
+		if len(arg[1]) == 0 {
+			sc.args = append(sc.args, value)
+			continue
+		}
+		if _, ok := sc.params[arg[1]]; ok {
+			return nil, true, fmt.Errorf("duplicate argument '%s' of shortcode '%s'", arg[1], sc.name)
+		}
+		sc.params[arg[1]] = value
+	}
+	return sc, true, nil
+}
+
+// checkParams fails for parameters which a shortcode doesn't know.
+func (sc *shortcode) checkParams(known ...string) error {
+	for key := range sc.params {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("unknown argument '%s' of shortcode '%s'", key, sc.name)
+		}
+	}
+	return nil
+}
+
+// lineMap maps the lines of expanded Markdown to the lines of the
+// Markdown before the expansion, lines start at 1.
+type lineMap []int
+
+func (m lineMap) source(line int) int {
+	if line < 1 || len(m) == 0 {
+		return line
+	}
+	return m[min(line, len(m))-1]
+}
+
+// sourceLine is a line of Markdown and the line it came from.
+type sourceLine struct {
+	text   string
+	number int
+}
+
+func lineError(line sourceLine, err error) error {
+	return &LineError{Line: line.number, Err: err}
+}
+
+// appendLines splits text into lines which all come from the same line.
+func appendLines(lines []sourceLine, text string, number int) []sourceLine {
+	for _, l := range strings.SplitAfter(text, "\n") {
+		if len(l) > 0 {
+			lines = append(lines, sourceLine{text: l, number: number})
+		}
+	}
+	return lines
+}
+
+// shortcodeFunc expands a shortcode which is implemented in Go.
+type shortcodeFunc func(sc *shortcode, ctx *shortcodeContext) (string, error)
+
+var shortcodeFuncs = map[string]shortcodeFunc{
+	"include": includeFile,
+	"gist":    embedGist,
+}
+
+// shortcodeContext is what shortcodes have access to.
+type shortcodeContext struct {
+	// Files which blog posts can include:
+	files *includeDirs
+	// Templates which the blog post defines:
+	templates map[string]*shortcodeTemplate
+}
+
+// template returns the template of a shortcode,
+// templates of the blog post come before built-in ones.
+func (ctx *shortcodeContext) template(name string) (*shortcodeTemplate, bool) {
+	if t, ok := ctx.templates[name]; ok {
+		return t, true
+	}
+	t, ok := builtinTemplates[name]
+	return t, ok
+}
+
+// expandShortcodes replaces shortcodes with the Markdown they produce.
+func expandShortcodes(markdown string, ctx *shortcodeContext) (string, lineMap, error) {
+	lines := appendLines(nil, markdown, 0)
+	for i := range lines {
+		lines[i].number = i + 1
+	}
+
+	lines, err := ctx.defineTemplates(lines)
+	if err != nil {
+		return "", nil, err
+	}
+	lines, err = ctx.expand(lines)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	numbers := make(lineMap, len(lines))
+	for i, line := range lines {
+		sb.WriteString(line.text)
+		numbers[i] = line.number
+	}
+	return sb.String(), numbers, nil
+}
+
+// defineTemplates registers the templates between {{< define "name" >}}
+// and {{< /define >}} and removes them from the Markdown.
+func (ctx *shortcodeContext) defineTemplates(lines []sourceLine) ([]sourceLine, error) {
+	remaining := []sourceLine{}
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		fence = trackFence(fence, lines[i].text)
+		sc, ok, err := parseShortcode(lines[i].text)
+		if len(fence) > 0 || !ok || sc == nil || sc.name != "define" {
+			remaining = append(remaining, lines[i])
+			continue
+		}
+		if err != nil {
+			return nil, lineError(lines[i], err)
+		}
+		if sc.closing {
+			return nil, lineError(lines[i], errors.New("unexpected closing shortcode 'define'"))
+		}
+		if err := sc.checkParams(); err != nil {
+			return nil, lineError(lines[i], err)
+		}
+		if len(sc.args) != 1 {
+			return nil, lineError(lines[i], errors.New(`define needs exactly one name, e.g. {{< define "note" >}}`))
+		}
+		end, err := findClosing(lines, i)
+		if err != nil {
+			return nil, lineError(lines[i], err)
+		}
+		var source strings.Builder
+		for _, l := range lines[i+1 : end] {
+			source.WriteString(l.text)
+		}
+		name := sc.args[0]
+		if _, ok := ctx.template(name); ok || shortcodeFuncs[name] != nil || name == "define" {
+			return nil, lineError(lines[i], fmt.Errorf("shortcode '%s' is already defined", name))
+		}
+		t, err := newShortcodeTemplate(name, source.String())
+		if err != nil {
+			return nil, lineError(lines[i], err)
+		}
+		if ctx.templates == nil {
+			ctx.templates = map[string]*shortcodeTemplate{}
+		}
+		ctx.templates[name] = t
+		i = end
+	}
+	return remaining, nil
+}
+
+// findClosing returns the index of the shortcode which closes
+// the one at start, shortcodes with the same name can be nested.
+func findClosing(lines []sourceLine, start int) (int, error) {
+	open, _, _ := parseShortcode(lines[start].text)
+	depth := 0
+	fence := ""
+	for i := start + 1; i < len(lines); i++ {
+		fence = trackFence(fence, lines[i].text)
+		sc, ok, _ := parseShortcode(lines[i].text)
+		if len(fence) > 0 || !ok || sc == nil || sc.name != open.name {
+			continue
+		}
+		if !sc.closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return i, nil
+		}
+		depth--
+	}
+	return 0, fmt.Errorf("shortcode '%s' is missing its closing {{< /%s >}}", open.name, open.name)
+}
+
+func (ctx *shortcodeContext) expand(lines []sourceLine) ([]sourceLine, error) {
+	expanded := []sourceLine{}
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		fence = trackFence(fence, line.text)
+		sc, ok, err := parseShortcode(line.text)
+		if len(fence) > 0 || !ok {
+			expanded = append(expanded, line)
+			continue
+		}
+		if err != nil {
+			return nil, lineError(line, err)
+		}
+		if sc.closing {
+			return nil, lineError(line, fmt.Errorf("unexpected closing shortcode '%s'", sc.name))
+		}
+
+		if fn, ok := shortcodeFuncs[sc.name]; ok {
+			text, err := fn(sc, ctx)
+			if err != nil {
+				return nil, lineError(line, err)
+			}
+			expanded = appendLines(expanded, text, line.number)
+			continue
+		}
+
+		t, ok := ctx.template(sc.name)
+		if !ok {
+			return nil, lineError(line, fmt.Errorf("unknown shortcode: %s", sc.name))
+		}
+		if !t.paired {
+			text, _, err := t.execute(sc)
+			if err != nil {
+				return nil, lineError(line, err)
+			}
+			expanded = appendLines(expanded, text, line.number)
+			continue
+		}
+
+		// Content between paired shortcodes can contain shortcodes:
+		end, err := findClosing(lines, i)
+		if err != nil {
+			return nil, lineError(line, err)
+		}
+		inner, err := ctx.expand(lines[i+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		before, after, err := t.execute(sc)
+		if err != nil {
+			return nil, lineError(line, err)
+		}
+		expanded = appendLines(expanded, before, line.number)
+		expanded = append(expanded, inner...)
+		expanded = appendLines(expanded, after, lines[end].number)
+		i = end
+	}
+	return expanded, nil
+}
+
+// trackFence returns the fence of the fenced code block which a line
+// is in or opens, or an empty string when the line ends the block.
+func trackFence(fence string, line string) string {
+	trimmed := strings.TrimSpace(line)
+	if len(fence) > 0 {
+		if strings.HasPrefix(trimmed, fence) && len(strings.Trim(trimmed, fence[:1])) == 0 {
+			return ""
+		}
+		return fence
+	}
+	for _, c := range []string{"`", "~"} {
+		if n := len(trimmed) - len(strings.TrimLeft(trimmed, c)); n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// shortcodeTemplate is a shortcode which is implemented as an HTML
+// template. Templates which use .Inner wrap content and are paired.
+type shortcodeTemplate struct {
+	template *template.Template
+	paired   bool
+}
+
+func newShortcodeTemplate(name string, source string) (*shortcodeTemplate, error) {
+	t, err := template.New(name).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template of shortcode '%s': %w", name, err)
+	}
+	return &shortcodeTemplate{template: t, paired: strings.Contains(source, ".Inner")}, nil
+}
+
+// innerPlaceholder marks where the content of paired shortcodes goes,
+// the content itself gets expanded line by line.
+const innerPlaceholder = "\x00inner\x00"
+
+// execute renders the template and returns the HTML
+// before and after the content of paired shortcodes.
+func (t *shortcodeTemplate) execute(sc *shortcode) (string, string, error) {
+	data := &shortcodeData{sc: sc, used: map[string]bool{}}
+	var sb strings.Builder
+	if err := t.template.Execute(&sb, data); err != nil {
+		var argErr *shortcodeArgError
+		if errors.As(err, &argErr) {
+			return "", "", argErr
+		}
+		return "", "", fmt.Errorf("error executing shortcode '%s': %w", sc.name, err)
+	}
+	for key := range sc.params {
+		if !data.used[key] {
+			return "", "", fmt.Errorf("unknown argument '%s' of shortcode '%s'", key, sc.name)
+		}
+	}
+	if len(sc.args) > data.args {
+		return "", "", fmt.Errorf("too many arguments for shortcode '%s'", sc.name)
+	}
+
+	html := strings.TrimSpace(sb.String())
+	// The placeholder gets escaped in attributes, scripts and styles,
+	// which would drop the content silently:
+	if t.paired && strings.Count(html, innerPlaceholder) != 1 {
+		return "", "", fmt.Errorf("shortcode '%s' must render .Inner exactly once and not in an attribute, script or style", sc.name)
+	}
+	before, after, found := strings.Cut(html, innerPlaceholder)
+	if !found {
+		return html + "\n", "", nil
+	}
+	return before, strings.TrimPrefix(after, "\n") + "\n", nil
+}
+
+// shortcodeArgError is a missing argument of a shortcode.
+type shortcodeArgError struct {
+	msg string
+}
+
+func (e *shortcodeArgError) Error() string {
+	return e.msg
+}
+
+// shortcodeData is what shortcode templates get to see:
+//
+//	{{ .Arg 0 }}             the first positional argument, which is required
+//	{{ .Param "src" }}       a required key="value" argument
+//	{{ .Optional "title" }}  an optional key="value" argument or ""
+//	{{ .Inner }}             the content of paired shortcodes
+//
+// Arguments which the template doesn't use are errors.
+type shortcodeData struct {
+	sc   *shortcode
+	used map[string]bool
+	args int
+}
+
+func (d *shortcodeData) Arg(i int) (string, error) {
+	d.args = max(d.args, i+1)
+	if i < 0 || i >= len(d.sc.args) {
+		return "", &shortcodeArgError{fmt.Sprintf("shortcode '%s' is missing argument %d", d.sc.name, i+1)}
+	}
+	return d.sc.args[i], nil
+}
+
+func (d *shortcodeData) Param(name string) (string, error) {
+	d.used[name] = true
+	value, ok := d.sc.params[name]
+	if !ok {
+		return "", &shortcodeArgError{fmt.Sprintf("shortcode '%s' is missing the argument %s=\"...\"", d.sc.name, name)}
+	}
+	return value, nil
+}
+
+func (d *shortcodeData) Optional(name string) string {
+	d.used[name] = true
+	return d.sc.params[name]
+}
+
+func (d *shortcodeData) Inner() template.HTML {
+	//nolint: gosec // placeholder for Markdown, which gets converted later
+	return template.HTML(innerPlaceholder)
+}
+
+// builtinTemplates are the shortcodes which all blog posts can use.
+var builtinTemplates = map[string]*shortcodeTemplate{
+	"youtube": mustShortcodeTemplate("youtube",
+		`<iframe class="youTubeVideo" src="https://www.youtube.com/embed/{{ .Arg 0 }}"`+
+			`{{ with .Optional "title" }} title="{{ . }}"{{ end }} frameborder="0" `+
+			`allow="accelerometer; encrypted-media; gyroscope; picture-in-picture" loading="lazy" allowfullscreen></iframe>`),
+	"figure": mustShortcodeTemplate("figure", `<figure{{ with .Optional "class" }} class="{{ . }}"{{ end }}>
+<img src="{{ .Param "src" }}" alt="{{ .Param "alt" }}"`+
+		`{{ with .Optional "width" }} width="{{ . }}"{{ end }}{{ with .Optional "height" }} height="{{ . }}"{{ end }} loading="lazy">
+{{ with .Optional "caption" }}<figcaption>{{ . }}</figcaption>
+{{ end }}</figure>`),
+	"details": mustShortcodeTemplate("details", `<details{{ if eq (.Optional "open") "true" }} open{{ end }}>
+<summary>{{ .Arg 0 }}</summary>
+
+{{ .Inner }}
+
+</details>`),
+}
+
+func mustShortcodeTemplate(name string, source string) *shortcodeTemplate {
+	t, err := newShortcodeTemplate(name, source)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
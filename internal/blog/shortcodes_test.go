@@ -0,0 +1,60 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShortcodeTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		err      string
+	}{
+		{
+			name:     "inner content",
+			template: "<aside>\n\n{{ .Inner }}\n\n</aside>",
+			expected: "<aside>\n\nHello\n\n</aside>\n",
+		},
+		{
+			name:     "inner in an attribute",
+			template: `<aside title="{{ .Inner }}">Note</aside>`,
+			err:      "shortcode 'note' must render .Inner exactly once",
+		},
+		{
+			name:     "inner in a script",
+			template: `<script>var inner = "{{ .Inner }}";</script>`,
+			err:      "shortcode 'note' must render .Inner exactly once",
+		},
+		{
+			name:     "inner in a branch which isn't taken",
+			template: `<aside>{{ if .Optional "open" }}{{ .Inner }}{{ end }}</aside>`,
+			err:      "shortcode 'note' must render .Inner exactly once",
+		},
+		{
+			name:     "inner twice",
+			template: "<aside>\n{{ .Inner }}\n{{ .Inner }}\n</aside>",
+			err:      "shortcode 'note' must render .Inner exactly once",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			markdown := "{{< define \"note\" >}}\n" + test.template + "\n{{< /define >}}\n\n{{< note >}}\nHello\n{{< /note >}}\n"
+			actual, _, err := expandShortcodes(markdown, &shortcodeContext{files: noIncludes})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(actual, test.expected) {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
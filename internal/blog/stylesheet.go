@@ -0,0 +1,195 @@
+package blog
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/net/html"
+)
+
+// SyntaxStylesheetPath is where the syntax-css command
+// writes the stylesheet for the assets middleware.
+const SyntaxStylesheetPath = "dist/assets/syntax.css"
+
+// Highlighted code only has CSS classes, the colours are in a stylesheet
+// which switches between a light and the custom dark style with the
+// colour scheme of the reader.
+var syntaxFormatOptions = []chromahtml.Option{
+	chromahtml.WithClasses(true),
+	chromahtml.TabWidth(4),
+	chromahtml.WithLineNumbers(false),
+	chromahtml.PreventSurroundingPre(false),
+}
+
+// lightSyntaxStyle is GitHub's light style on the paper colours of the blog.
+var lightSyntaxStyle = func() *chroma.Style {
+	style, err := styles.Get("github").Builder().
+		Add(chroma.Background, "#1f2328 bg:#ebe9e6").
+		Add(chroma.LineHighlight, "bg:#dcd9d3").
+		Add(chroma.LineNumbers, "#8c8a87").
+		Add(chroma.LineNumbersTable, "#8c8a87").
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	return style
+}()
+
+var syntaxThemes = []struct {
+	colorScheme string
+	style       *chroma.Style
+}{
+	{"light", lightSyntaxStyle},
+	{"dark", syntaxStyle},
+}
+
+// styledClasses are the CSS classes of token types which at least one
+// theme colours, the spans of all other tokens can go. Classes of the
+// lines and line numbers always stay and whitespace needs no colour.
+var styledClasses = func() map[string]bool {
+	classes := map[string]bool{}
+	for t, class := range chroma.StandardTypes {
+		if t == chroma.TextWhitespace {
+			continue
+		}
+		if t < 0 {
+			classes[class] = true
+			continue
+		}
+		for _, theme := range syntaxThemes {
+			bg := theme.style.Get(chroma.Background)
+			if !theme.style.Get(t).Sub(bg).IsZero() {
+				classes[class] = true
+			}
+		}
+	}
+	return classes
+}()
+
+var tokenSpan = regexp.MustCompile(`<span class="([a-z0-9]+)">([^<]*)</span>`)
+
+// removeUnstyledSpans replaces the spans of uncoloured tokens with their
+// text, which keeps the HTML of highlighted code small.
+func removeUnstyledSpans(html []byte) []byte {
+	return tokenSpan.ReplaceAllFunc(html, func(span []byte) []byte {
+		match := tokenSpan.FindSubmatch(span)
+		if styledClasses[string(match[1])] {
+			return span
+		}
+		return match[2]
+	})
+}
+
+// SyntaxCSS generates the stylesheet for highlighted code.
+func SyntaxCSS() (string, error) {
+	formatter := chromahtml.New(syntaxFormatOptions...)
+	var sb strings.Builder
+	sb.WriteString("/* Generated by 'blog syntax-css', do not edit. */\n")
+	for _, theme := range syntaxThemes {
+		fmt.Fprintf(&sb, "@media (prefers-color-scheme: %s) {\n", theme.colorScheme)
+		if err := formatter.WriteCSS(&sb, theme.style); err != nil {
+			return "", fmt.Errorf("error generating CSS for the %s syntax style: %w", theme.colorScheme, err)
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String(), nil
+}
+
+// inlineSyntaxStyles are the styles of the dark theme by CSS class for
+// readers without the stylesheet. The layout of line numbers is the
+// same as in the stylesheet.
+var inlineSyntaxStyles = func() map[string]string {
+	styles := map[string]string{}
+	bg := syntaxStyle.Get(chroma.Background)
+	for t, class := range chroma.StandardTypes {
+		entry := syntaxStyle.Get(t)
+		if t != chroma.Background {
+			entry = entry.Sub(bg)
+		}
+		if css := chromahtml.StyleEntryToCSS(entry); len(css) > 0 {
+			styles[class] = css
+		}
+	}
+	styles[chroma.StandardTypes[chroma.PreWrapper]] = chromahtml.StyleEntryToCSS(bg) + "; tab-size: 4"
+	lineNumbers := "white-space: pre; user-select: none; margin-right: 0.4em; padding: 0 0.4em 0 0.4em; "
+	styles[chroma.StandardTypes[chroma.LineNumbers]] = lineNumbers + styles[chroma.StandardTypes[chroma.LineNumbers]]
+	styles[chroma.StandardTypes[chroma.LineNumbersTable]] = lineNumbers + styles[chroma.StandardTypes[chroma.LineNumbersTable]]
+	styles[chroma.StandardTypes[chroma.LineTable]] = "border-spacing: 0; padding: 0; margin: 0; border: 0"
+	styles[chroma.StandardTypes[chroma.LineTableTD]] = "vertical-align: top; padding: 0; margin: 0; border: 0"
+	styles[chroma.StandardTypes[chroma.Line]] = "display: flex"
+	return styles
+}()
+
+// InlineSyntaxStyles replaces the CSS classes of highlighted code with
+// inline styles of the dark theme, because emails, feed readers and
+// ActivityPub servers don't load the stylesheet.
+func InlineSyntaxStyles(content template.HTML) template.HTML {
+	var sb strings.Builder
+	// Highlighted code is in an element with the chroma class
+	// and depth counts the open elements inside of it:
+	depth := 0
+	z := html.NewTokenizer(strings.NewReader(string(content)))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			//nolint: gosec // only attributes of highlighted code change
+			return template.HTML(sb.String())
+		}
+		// Tokens lower case the names of tags in the raw HTML:
+		raw := string(z.Raw())
+		token := z.Token()
+		switch {
+		case tt == html.StartTagToken && depth > 0:
+			depth++
+		case tt == html.StartTagToken:
+			if class, _ := attr(token.Attr, "class"); slices.Contains(strings.Fields(class), "chroma") {
+				depth = 1
+			}
+		case tt == html.EndTagToken && depth > 0:
+			depth--
+		}
+		if tt != html.StartTagToken || depth == 0 {
+			sb.WriteString(raw)
+			continue
+		}
+
+		styles := []string{}
+		attrs := make([]html.Attribute, 0, len(token.Attr)+1)
+		existing := ""
+		for _, a := range token.Attr {
+			if a.Key == "style" {
+				existing = a.Val
+				continue
+			}
+			if a.Key != "class" {
+				attrs = append(attrs, a)
+				continue
+			}
+			classes := []string{}
+			for _, class := range strings.Fields(a.Val) {
+				if css, ok := inlineSyntaxStyles[class]; ok {
+					styles = append(styles, css)
+				} else {
+					classes = append(classes, class)
+				}
+			}
+			if len(classes) > 0 {
+				attrs = append(attrs, html.Attribute{Key: "class", Val: strings.Join(classes, " ")})
+			}
+		}
+		if len(existing) > 0 {
+			styles = append(styles, existing)
+		}
+		if len(styles) > 0 {
+			attrs = append(attrs, html.Attribute{Key: "style", Val: strings.Join(styles, "; ")})
+		}
+		token.Attr = attrs
+		sb.WriteString(token.String())
+	}
+}
@@ -0,0 +1,64 @@
+package blog
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestInlineSyntaxStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "highlighted code",
+			content:  `<pre class="chroma"><code><span class="line"><span class="kd">func</span> <span class="nf">main</span>()</span></code></pre>`,
+			expected: `<pre style="color: #cccccc; background-color: #1d1d1d; tab-size: 4"><code><span style="display: flex"><span style="color: #d179a3">func</span> <span style="color: #ecc77d">main</span>()</span></code></pre>`,
+		},
+		{
+			name:     "highlighted lines",
+			content:  `<pre class="chroma"><span class="line hl">x</span></pre>`,
+			expected: `<pre style="color: #cccccc; background-color: #1d1d1d; tab-size: 4"><span style="display: flex; background-color: #363636">x</span></pre>`,
+		},
+		{
+			name:     "classes without styles stay",
+			content:  `<pre class="chroma"><code class="language-go"><span class="cl">x</span></code></pre>`,
+			expected: `<pre style="color: #cccccc; background-color: #1d1d1d; tab-size: 4"><code class="language-go"><span class="cl">x</span></code></pre>`,
+		},
+		{
+			name:     "line numbers in a table",
+			content:  `<div class="chroma"><table class="lntable"><tr><td class="lntd"><pre class="chroma"><span class="lnt">1</span></pre></td></tr></table></div>`,
+			expected: `<div style="color: #cccccc; background-color: #1d1d1d; tab-size: 4"><table style="border-spacing: 0; padding: 0; margin: 0; border: 0"><tr><td style="vertical-align: top; padding: 0; margin: 0; border: 0"><pre style="color: #cccccc; background-color: #1d1d1d; tab-size: 4"><span style="white-space: pre; user-select: none; margin-right: 0.4em; padding: 0 0.4em 0 0.4em; color: #7f7f7f">1</span></pre></td></tr></table></div>`,
+		},
+		{
+			name:     "classes outside of code",
+			content:  `<p class="k">Text with <code>code</code> &amp; <SPAN class="s">a span</SPAN></p><pre class="chroma">x</pre><p class="k">y</p>`,
+			expected: `<p class="k">Text with <code>code</code> &amp; <SPAN class="s">a span</SPAN></p><pre style="color: #cccccc; background-color: #1d1d1d; tab-size: 4">x</pre><p class="k">y</p>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := InlineSyntaxStyles(template.HTML(test.content))
+			if string(actual) != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInlineSyntaxStylesOfABlogPost(t *testing.T) {
+	html, _, err := computeTemplate("```go\nfunc main() {}\n```\n", nil, "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := string(InlineSyntaxStyles(html))
+	if strings.Contains(actual, `class="chroma"`) || strings.Contains(actual, `class="kd"`) {
+		t.Errorf("expected no classes with inline styles, got:\n%s", actual)
+	}
+	if !strings.Contains(actual, `<span style="color: #d179a3">func</span>`) {
+		t.Errorf("expected the keyword to have an inline style, got:\n%s", actual)
+	}
+}
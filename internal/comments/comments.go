@@ -0,0 +1,388 @@
+package comments
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultStorePath = "data/comments.db"
+
+	maxAuthorLen   = 100
+	maxWebsiteLen  = 200
+	maxContentLen  = 5000
+	openTimeout    = 3 * time.Second
+	sequenceFormat = "%020d"
+)
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+
+	postsBucket  = []byte("posts")
+	countsBucket = []byte("counts")
+	metaBucket   = []byte("meta")
+	revisionKey  = []byte("revision")
+)
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusSpam     Status = "spam"
+	StatusDeleted  Status = "deleted"
+)
+
+func ParseStatus(value string) (Status, error) {
+	status := Status(value)
+	switch status {
+	case StatusPending, StatusApproved, StatusSpam, StatusDeleted:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown comment status: %s", value)
+	}
+}
+
+type Comment struct {
+	ID        string
+	PostID    string
+	ParentID  string
+	Author    string
+	Website   string
+	Content   string
+	HTML      template.HTML
+	CreatedAt time.Time
+	Status    Status
+}
+
+// Validate checks the user submitted fields of a comment.
+func (c *Comment) Validate() error {
+	if len(c.PostID) == 0 {
+		return errors.New("comment is missing a blog post ID")
+	}
+	if len(c.Author) == 0 {
+		return errors.New("please enter your name")
+	}
+	if len(c.Author) > maxAuthorLen {
+		return fmt.Errorf("name must not be longer than %d characters", maxAuthorLen)
+	}
+	if len(c.Website) > maxWebsiteLen {
+		return fmt.Errorf("website must not be longer than %d characters", maxWebsiteLen)
+	}
+	if len(c.Content) == 0 {
+		return errors.New("please enter a comment")
+	}
+	if len(c.Content) > maxContentLen {
+		return fmt.Errorf("comment must not be longer than %d characters", maxContentLen)
+	}
+	return nil
+}
+
+// Store persists comments in an embedded bbolt database.
+// Comments are grouped in one bucket per blog post and
+// keyed by an increasing sequence number, which keeps them
+// in chronological order. The number of approved comments
+// per blog post is kept in a separate bucket, so that listings
+// don't have to read every comment.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for comment store: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening comment store '%s': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(postsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(metaBucket); err != nil {
+			return err
+		}
+		if tx.Bucket(countsBucket) != nil {
+			return nil
+		}
+		// Stores which were created before the counts existed
+		// get them computed once:
+		if _, err := tx.CreateBucket(countsBucket); err != nil {
+			return err
+		}
+		return countAll(tx)
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error initialising comment store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func incrementRevision(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	revision := uint64(0)
+	if value := meta.Get(revisionKey); value != nil {
+		revision = binary.BigEndian.Uint64(value)
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, revision+1)
+	return meta.Put(revisionKey, value)
+}
+
+func addCount(tx *bolt.Tx, postID string, delta int64) error {
+	counts := tx.Bucket(countsBucket)
+	count := int64(0)
+	if value := counts.Get([]byte(postID)); value != nil {
+		count = int64(binary.BigEndian.Uint64(value))
+	}
+	count = max(count+delta, 0)
+	if count == 0 {
+		return counts.Delete([]byte(postID))
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(count))
+	return counts.Put([]byte(postID), value)
+}
+
+// countChange returns how the number of approved comments changes
+// when a comment moves from one status to another.
+func countChange(from Status, to Status) int64 {
+	switch {
+	case from != StatusApproved && to == StatusApproved:
+		return 1
+	case from == StatusApproved && to != StatusApproved:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func countAll(tx *bolt.Tx) error {
+	posts := tx.Bucket(postsBucket)
+	return posts.ForEachBucket(func(key []byte) error {
+		return posts.Bucket(key).ForEach(func(_, value []byte) error {
+			c := &Comment{}
+			if err := json.Unmarshal(value, c); err != nil {
+				return fmt.Errorf("error deserialising comment: %w", err)
+			}
+			return addCount(tx, string(key), countChange("", c.Status))
+		})
+	})
+}
+
+func getComment(bucket *bolt.Bucket, commentID string) (*Comment, error) {
+	value := bucket.Get([]byte(commentID))
+	if value == nil {
+		return nil, ErrCommentNotFound
+	}
+	c := &Comment{}
+	if err := json.Unmarshal(value, c); err != nil {
+		return nil, fmt.Errorf("error deserialising comment: %w", err)
+	}
+	return c, nil
+}
+
+func putComment(bucket *bolt.Bucket, c *Comment) error {
+	value, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error serialising comment: %w", err)
+	}
+	return bucket.Put([]byte(c.ID), value)
+}
+
+// Add stores a new comment and assigns it an ID.
+func (s *Store) Add(c *Comment) error {
+	return s.AddAll([]*Comment{c})
+}
+
+// AddAll stores multiple comments in a single transaction.
+// Comments which already have an ID keep it, so that
+// replies can reference their parent before being stored.
+func (s *Store) AddAll(comments []*Comment) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, c := range comments {
+			bucket, err := tx.Bucket(postsBucket).CreateBucketIfNotExists([]byte(c.PostID))
+			if err != nil {
+				return err
+			}
+			if len(c.ID) == 0 {
+				seq, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				c.ID = fmt.Sprintf(sequenceFormat, seq)
+			}
+			// Comments which get imported again replace the existing ones:
+			previous := Status("")
+			if existing, err := getComment(bucket, c.ID); err == nil {
+				previous = existing.Status
+			} else if !errors.Is(err, ErrCommentNotFound) {
+				return err
+			}
+			if err := putComment(bucket, c); err != nil {
+				return err
+			}
+			if err := addCount(tx, c.PostID, countChange(previous, c.Status)); err != nil {
+				return err
+			}
+		}
+		return incrementRevision(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("error storing comments: %w", err)
+	}
+	return nil
+}
+
+// SetStatus moves a comment into a different moderation state.
+func (s *Store) SetStatus(postID string, commentID string, status Status) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(postsBucket).Bucket([]byte(postID))
+		if bucket == nil {
+			return ErrCommentNotFound
+		}
+		c, err := getComment(bucket, commentID)
+		if err != nil {
+			return err
+		}
+		previous := c.Status
+		c.Status = status
+		if err := putComment(bucket, c); err != nil {
+			return err
+		}
+		if err := addCount(tx, postID, countChange(previous, status)); err != nil {
+			return err
+		}
+		return incrementRevision(tx)
+	})
+}
+
+func (s *Store) forEach(postID string, fn func(c *Comment)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		posts := tx.Bucket(postsBucket)
+		visit := func(bucket *bolt.Bucket) error {
+			return bucket.ForEach(func(_, value []byte) error {
+				c := &Comment{}
+				if err := json.Unmarshal(value, c); err != nil {
+					return fmt.Errorf("error deserialising comment: %w", err)
+				}
+				fn(c)
+				return nil
+			})
+		}
+
+		if len(postID) > 0 {
+			bucket := posts.Bucket([]byte(postID))
+			if bucket == nil {
+				return nil
+			}
+			return visit(bucket)
+		}
+
+		return posts.ForEachBucket(func(key []byte) error {
+			return visit(posts.Bucket(key))
+		})
+	})
+}
+
+// Get returns a single comment of a blog post.
+func (s *Store) Get(postID string, commentID string) (*Comment, error) {
+	var c *Comment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(postsBucket).Bucket([]byte(postID))
+		if bucket == nil {
+			return ErrCommentNotFound
+		}
+		var err error
+		c, err = getComment(bucket, commentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// List returns all comments of a blog post with the given status
+// in chronological order.
+func (s *Store) List(postID string, status Status) ([]*Comment, error) {
+	comments := []*Comment{}
+	err := s.forEach(postID, func(c *Comment) {
+		if c.Status == status {
+			comments = append(comments, c)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing comments: %w", err)
+	}
+	// Imported comments don't share the same sequence of keys:
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+// ListAll returns the comments with the given status across all blog posts,
+// newest first.
+func (s *Store) ListAll(status Status) ([]*Comment, error) {
+	comments := []*Comment{}
+	err := s.forEach("", func(c *Comment) {
+		if c.Status == status {
+			comments = append(comments, c)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing comments: %w", err)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.After(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+// Counts returns the number of approved comments per blog post ID.
+func (s *Store) Counts() (map[string]int, error) {
+	counts := map[string]int{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(countsBucket).ForEach(func(key, value []byte) error {
+			counts[string(key)] = int(binary.BigEndian.Uint64(value))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error counting comments: %w", err)
+	}
+	return counts, nil
+}
+
+// Revision changes every time a comment gets added or moderated
+// and can be used to invalidate cached pages.
+func (s *Store) Revision() uint64 {
+	revision := uint64(0)
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(metaBucket).Get(revisionKey); value != nil {
+			revision = binary.BigEndian.Uint64(value)
+		}
+		return nil
+	})
+	return revision
+}
@@ -0,0 +1,199 @@
+package comments
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "comments.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	return store, path
+}
+
+func newComment(postID string, author string, status Status, createdAt time.Time) *Comment {
+	return &Comment{
+		PostID:    postID,
+		Author:    author,
+		Content:   "Hello from " + author,
+		CreatedAt: createdAt,
+		Status:    status,
+	}
+}
+
+func authors(comments []*Comment) string {
+	names := []string{}
+	for _, c := range comments {
+		names = append(names, c.Author)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStore(t *testing.T) {
+	store, _ := openTestStore(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	alice := newComment("hello-world", "alice", StatusApproved, start)
+	bob := newComment("hello-world", "bob", StatusPending, start.Add(time.Hour))
+	carol := newComment("hello-world", "carol", StatusApproved, start.Add(2*time.Hour))
+	dave := newComment("other-post", "dave", StatusPending, start.Add(3*time.Hour))
+	for _, c := range []*Comment{alice, bob, carol, dave} {
+		if err := store.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(alice.ID) == 0 || alice.ID == bob.ID {
+		t.Fatalf("expected unique IDs, got '%s' and '%s'", alice.ID, bob.ID)
+	}
+
+	approved, err := store.List("hello-world", StatusApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := authors(approved); names != "alice,carol" {
+		t.Errorf("expected approved comments alice,carol, got %s", names)
+	}
+
+	pending, err := store.ListAll(StatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := authors(pending); names != "dave,bob" {
+		t.Errorf("expected pending comments newest first dave,bob, got %s", names)
+	}
+
+	c, err := store.Get("hello-world", bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Author != "bob" || !c.CreatedAt.Equal(bob.CreatedAt) {
+		t.Errorf("expected bob's comment, got %+v", c)
+	}
+	if _, err := store.Get("hello-world", "missing"); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("expected %v, got %v", ErrCommentNotFound, err)
+	}
+	if _, err := store.Get("missing-post", bob.ID); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("expected %v, got %v", ErrCommentNotFound, err)
+	}
+}
+
+func TestStoreCountsAndRevision(t *testing.T) {
+	store, path := openTestStore(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	revision := store.Revision()
+	alice := newComment("hello-world", "alice", StatusPending, start)
+	if err := store.Add(alice); err != nil {
+		t.Fatal(err)
+	}
+	if store.Revision() == revision {
+		t.Error("expected the revision to change when adding a comment")
+	}
+
+	tests := []struct {
+		status   Status
+		expected int
+	}{
+		{StatusApproved, 1},
+		{StatusApproved, 1},
+		{StatusSpam, 0},
+		{StatusApproved, 1},
+		{StatusDeleted, 0},
+	}
+	for _, test := range tests {
+		revision := store.Revision()
+		if err := store.SetStatus("hello-world", alice.ID, test.status); err != nil {
+			t.Fatal(err)
+		}
+		if store.Revision() == revision {
+			t.Errorf("expected the revision to change when moving to %s", test.status)
+		}
+		counts, err := store.Counts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts["hello-world"] != test.expected {
+			t.Errorf("expected %d approved comments after moving to %s, got %d",
+				test.expected, test.status, counts["hello-world"])
+		}
+	}
+
+	if err := store.SetStatus("hello-world", "missing", StatusApproved); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("expected %v, got %v", ErrCommentNotFound, err)
+	}
+
+	// Counts and comments survive reopening the store:
+	if err := store.SetStatus("hello-world", alice.ID, StatusApproved); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reopened.Close()
+	}()
+	counts, err := reopened.Counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["hello-world"] != 1 {
+		t.Errorf("expected 1 approved comment after reopening, got %d", counts["hello-world"])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment Comment
+		err     string
+	}{
+		{"valid", Comment{PostID: "hello-world", Author: "alice", Content: "Hi"}, ""},
+		{"missing blog post", Comment{Author: "alice", Content: "Hi"}, "missing a blog post ID"},
+		{"missing name", Comment{PostID: "hello-world", Content: "Hi"}, "please enter your name"},
+		{"long name", Comment{PostID: "hello-world", Author: strings.Repeat("a", maxAuthorLen+1), Content: "Hi"}, "name must not be longer"},
+		{"long website", Comment{PostID: "hello-world", Author: "alice", Website: strings.Repeat("a", maxWebsiteLen+1), Content: "Hi"}, "website must not be longer"},
+		{"missing content", Comment{PostID: "hello-world", Author: "alice"}, "please enter a comment"},
+		{"long content", Comment{PostID: "hello-world", Author: "alice", Content: strings.Repeat("a", maxContentLen+1)}, "comment must not be longer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.comment.Validate()
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing '%s', got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for _, status := range []Status{StatusPending, StatusApproved, StatusSpam, StatusDeleted} {
+		parsed, err := ParseStatus(string(status))
+		if err != nil || parsed != status {
+			t.Errorf("expected %s, got %s (%v)", status, parsed, err)
+		}
+	}
+	if _, err := ParseStatus("published"); err == nil {
+		t.Error("expected an error for an unknown status")
+	}
+}
@@ -0,0 +1,99 @@
+package comments
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxLinks = 3
+
+	// pruneEvery is the number of submissions after which
+	// inactive IP addresses get forgotten.
+	pruneEvery = 1000
+)
+
+// RateLimiter allows a fixed number of comment submissions
+// per IP address within a sliding time window.
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	seen   map[string][]time.Time
+	calls  int
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		seen:   map[string][]time.Time{},
+	}
+}
+
+// ClientIP strips the port from a request's RemoteAddr,
+// which the proxy middleware has already set to the real IP.
+func ClientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// Allow records a submission from the given IP address and
+// reports whether it is within the limit.
+func (rl *RateLimiter) Allow(ip string, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	cutoff := now.Add(-rl.window)
+	recent := rl.seen[ip][:0]
+	for _, t := range rl.seen[ip] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	// Forget about IP addresses which haven't been active for a while
+	// every now and then, so that the map doesn't grow forever:
+	rl.calls++
+	if rl.calls%pruneEvery == 0 {
+		rl.prune(cutoff)
+	}
+
+	if len(recent) >= rl.limit {
+		rl.seen[ip] = recent
+		return false
+	}
+	rl.seen[ip] = append(recent, now)
+	return true
+}
+
+func (rl *RateLimiter) prune(cutoff time.Time) {
+	for key, times := range rl.seen {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(rl.seen, key)
+		}
+	}
+}
+
+// Classify decides the initial moderation state of a new comment.
+// The honeypot is a form field which is hidden from humans, so any
+// value means the comment was submitted by a bot.
+func Classify(c *Comment, honeypot string) Status {
+	if len(honeypot) > 0 {
+		return StatusSpam
+	}
+
+	content := strings.ToLower(c.Content)
+	links := strings.Count(content, "http://") +
+		strings.Count(content, "https://")
+	if links > maxLinks {
+		return StatusSpam
+	}
+
+	return StatusPending
+}
@@ -0,0 +1,92 @@
+package comments
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		honeypot string
+		expected Status
+	}{
+		{"plain comment", "Great post, thanks!", "", StatusPending},
+		{"filled honeypot", "Great post, thanks!", "https://spam.example", StatusSpam},
+		{"few links", strings.Repeat("see https://example.com ", maxLinks), "", StatusPending},
+		{"too many links", strings.Repeat("see http://example.com ", maxLinks+1), "", StatusSpam},
+		{"upper case links", strings.Repeat("see HTTPS://EXAMPLE.COM ", maxLinks+1), "", StatusSpam},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := Classify(&Comment{Content: test.content}, test.honeypot)
+			if status != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, status)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(2, time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		ip       string
+		at       time.Duration
+		expected bool
+	}{
+		{"1.1.1.1", 0, true},
+		{"1.1.1.1", time.Minute, true},
+		{"1.1.1.1", 2 * time.Minute, false},
+		{"2.2.2.2", 2 * time.Minute, true},
+		{"1.1.1.1", 59 * time.Minute, false},
+		{"1.1.1.1", 61 * time.Minute, true},
+		{"1.1.1.1", 62 * time.Minute, true},
+		{"1.1.1.1", 63 * time.Minute, false},
+	}
+
+	for _, test := range tests {
+		if allowed := rl.Allow(test.ip, start.Add(test.at)); allowed != test.expected {
+			t.Errorf("expected %t for %s after %s, got %t", test.expected, test.ip, test.at, allowed)
+		}
+	}
+}
+
+func TestRateLimiterForgetsInactiveIPs(t *testing.T) {
+	rl := NewRateLimiter(1, time.Hour)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range pruneEvery - 1 {
+		rl.Allow(fmt.Sprintf("10.0.%d.%d", i/256, i%256), start)
+	}
+	if len(rl.seen) != pruneEvery-1 {
+		t.Fatalf("expected %d IP addresses, got %d", pruneEvery-1, len(rl.seen))
+	}
+
+	rl.Allow("1.1.1.1", start.Add(2*time.Hour))
+	if len(rl.seen) != 1 {
+		t.Errorf("expected only the active IP address, got %d", len(rl.seen))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		expected   string
+	}{
+		{"1.2.3.4:5678", "1.2.3.4"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"1.2.3.4", "1.2.3.4"},
+	}
+
+	for _, test := range tests {
+		if ip := ClientIP(test.remoteAddr); ip != test.expected {
+			t.Errorf("expected %s for %s, got %s", test.expected, test.remoteAddr, ip)
+		}
+	}
+}
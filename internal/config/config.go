@@ -1,26 +1,44 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
 	"strconv"
 	"strings"
 
 	"github.com/dusted-go/config/env"
+
+	"github.com/dustedcodes/blog/internal/blog"
 )
 
 type Config struct {
-	EnvironmentName    string
-	LogLevel           slog.Leveler
-	ApplicationName    string
-	ApplicationVersion string
-	HTTPPort           int
-	ProxyCount         int
-	PublicHost         string
-	BaseURL            string
-	RedirectWWW        bool
-	CDN                string
-	MaxRequestSize     int64
-	DisqusShortname    string
+	EnvironmentName      string
+	LogLevel             slog.Leveler
+	ApplicationName      string
+	ApplicationVersion   string
+	HTTPPort             int
+	ProxyCount           int
+	PublicHost           string
+	BaseURL              string
+	RedirectWWW          bool
+	CDN                  string
+	CDNMirrorPath        string
+	ImageWidths          []int
+	MaxRequestSize       int64
+	CommentsStorePath    string
+	WebmentionStorePath  string
+	SendWebmentions      bool
+	AdminPassword        string
+	ActivityPubUsername  string
+	ActivityPubStorePath string
+	ActivityPubKeyPath   string
+	NewsletterStorePath  string
+	NewsletterFrom       string
+	SMTPAddress          string
+	SMTPUsername         string
+	SMTPPassword         string
+	AnalyticsStorePath   string
+	MarkdownExtensions   []string
 }
 
 func parseLogLevel(value string) slog.Leveler {
@@ -51,6 +69,19 @@ func parseLogLevel(value string) slog.Leveler {
 	return slog.LevelInfo
 }
 
+// parseImageWidths parses a space or comma separated list
+// of widths and ignores values which aren't positive numbers.
+func parseImageWidths(value string) []int {
+	widths := []int{}
+	for _, field := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		width, err := strconv.Atoi(field)
+		if err == nil && width > 0 {
+			widths = append(widths, width)
+		}
+	}
+	return widths
+}
+
 func (c *Config) MinLogLevel() slog.Leveler {
 	return c.LogLevel
 }
@@ -63,6 +94,30 @@ func (c *Config) HotReload() bool {
 	return !c.IsProduction()
 }
 
+// AdminEnabled reports whether the password protected
+// admin pages are available.
+func (c *Config) AdminEnabled() bool {
+	return len(c.AdminPassword) > 0
+}
+
+// NewsletterEnabled reports whether emails can be sent,
+// which is required for the double opt-in of subscribers.
+func (c *Config) NewsletterEnabled() bool {
+	return len(c.SMTPAddress) > 0
+}
+
+// UserAgent identifies the blog in outgoing HTTP requests.
+func (c *Config) UserAgent() string {
+	return fmt.Sprintf("%s/%s (+%s)", c.ApplicationName, c.ApplicationVersion, c.BaseURL)
+}
+
+// BlogOptions control how blog posts get rendered.
+func (c *Config) BlogOptions() blog.Options {
+	return blog.Options{
+		Extensions: c.MarkdownExtensions,
+	}
+}
+
 func (c *Config) ServerAddress() string {
 	return ":" + strconv.Itoa(c.HTTPPort)
 }
@@ -86,17 +141,32 @@ func (c *Config) DomainRedirects() map[string]string {
 
 func Load() *Config {
 	return &Config{
-		EnvironmentName:    env.GetOrDefault("ENV_NAME", "Development"),
-		LogLevel:           parseLogLevel(env.GetOrDefault("LOG_LEVEL", "Debug")),
-		ApplicationName:    env.GetOrDefault("APP_NAME", "dustedcodes"),
-		ApplicationVersion: env.GetOrDefault("APP_VERSION", "0.1.0"),
-		HTTPPort:           env.GetIntOrDefault("HTTP_PORT", 3000),
-		ProxyCount:         env.GetIntOrDefault("PROXY_COUNT", 0),
-		PublicHost:         env.GetOrDefault("PUBLIC_HOST", "dusted.codes"),
-		BaseURL:            env.GetOrDefault("BASE_URL", "https://dusted.codes"),
-		RedirectWWW:        env.GetBoolOrDefault("REDIRECT_WWW", false),
-		CDN:                env.GetOrDefault("CDN", "https://cdn.dusted.codes"),
-		MaxRequestSize:     int64(env.GetIntOrDefault("MAX_REQUEST_SIZE", 500000)),
-		DisqusShortname:    env.GetOrDefault("DISQUS_SHORTNAME", ""),
+		EnvironmentName:      env.GetOrDefault("ENV_NAME", "Development"),
+		LogLevel:             parseLogLevel(env.GetOrDefault("LOG_LEVEL", "Debug")),
+		ApplicationName:      env.GetOrDefault("APP_NAME", "dustedcodes"),
+		ApplicationVersion:   env.GetOrDefault("APP_VERSION", "0.1.0"),
+		HTTPPort:             env.GetIntOrDefault("HTTP_PORT", 3000),
+		ProxyCount:           env.GetIntOrDefault("PROXY_COUNT", 0),
+		PublicHost:           env.GetOrDefault("PUBLIC_HOST", "dusted.codes"),
+		BaseURL:              env.GetOrDefault("BASE_URL", "https://dusted.codes"),
+		RedirectWWW:          env.GetBoolOrDefault("REDIRECT_WWW", false),
+		CDN:                  env.GetOrDefault("CDN", "https://cdn.dusted.codes"),
+		CDNMirrorPath:        env.GetOrDefault("CDN_MIRROR_PATH", "../../cdn"),
+		ImageWidths:          parseImageWidths(env.GetOrDefault("IMAGE_WIDTHS", "480,960,1536")),
+		MaxRequestSize:       int64(env.GetIntOrDefault("MAX_REQUEST_SIZE", 500000)),
+		CommentsStorePath:    env.GetOrDefault("COMMENTS_STORE_PATH", "data/comments.db"),
+		WebmentionStorePath:  env.GetOrDefault("WEBMENTION_STORE_PATH", "data/webmentions.db"),
+		SendWebmentions:      env.GetBoolOrDefault("SEND_WEBMENTIONS", false),
+		AdminPassword:        env.GetOrDefault("ADMIN_PASSWORD", ""),
+		ActivityPubUsername:  env.GetOrDefault("ACTIVITYPUB_USERNAME", "blog"),
+		ActivityPubStorePath: env.GetOrDefault("ACTIVITYPUB_STORE_PATH", "data/activitypub.db"),
+		ActivityPubKeyPath:   env.GetOrDefault("ACTIVITYPUB_KEY_PATH", "data/activitypub.pem"),
+		NewsletterStorePath:  env.GetOrDefault("NEWSLETTER_STORE_PATH", "data/newsletter.db"),
+		NewsletterFrom:       env.GetOrDefault("NEWSLETTER_FROM", "Dusted Codes <newsletter@dusted.codes>"),
+		SMTPAddress:          env.GetOrDefault("SMTP_ADDRESS", ""),
+		SMTPUsername:         env.GetOrDefault("SMTP_USERNAME", ""),
+		SMTPPassword:         env.GetOrDefault("SMTP_PASSWORD", ""),
+		AnalyticsStorePath:   env.GetOrDefault("ANALYTICS_STORE_PATH", "data/analytics.db"),
+		MarkdownExtensions:   strings.Fields(strings.ReplaceAll(env.GetOrDefault("MARKDOWN_EXTENSIONS", ""), ",", " ")),
 	}
 }
@@ -0,0 +1,429 @@
+package diagram
+
+import (
+	"maps"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type dotToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// lexDOT splits DOT source into IDs, quoted strings, edge operators
+// and punctuation, skipping comments.
+func lexDOT(src string) ([]dotToken, error) {
+	tokens := []dotToken{}
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, syntaxError(line, "unterminated comment")
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"':
+			start := line
+			var sb strings.Builder
+			i++
+			for ; i < len(src) && src[i] != '"'; i++ {
+				switch {
+				case src[i] == '\\' && i+1 < len(src):
+					i++
+					switch src[i] {
+					case 'n', 'l', 'r':
+						sb.WriteByte('\n')
+					case '\n':
+						line++
+					default:
+						sb.WriteByte(src[i])
+					}
+				case src[i] == '\n':
+					line++
+					sb.WriteByte('\n')
+				default:
+					sb.WriteByte(src[i])
+				}
+			}
+			if i >= len(src) {
+				return nil, syntaxError(start, "unterminated string")
+			}
+			i++
+			tokens = append(tokens, dotToken{text: sb.String(), quoted: true, line: start})
+		case strings.HasPrefix(src[i:], "->") || strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, dotToken{text: src[i : i+2], line: line})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", rune(c)):
+			tokens = append(tokens, dotToken{text: string(c), line: line})
+			i++
+		default:
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' &&
+					(r != '-' || strings.HasPrefix(src[i:], "->") || strings.HasPrefix(src[i:], "--")) {
+					break
+				}
+				i += size
+			}
+			if i == start {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, syntaxError(line, "unexpected '%c'", r)
+			}
+			tokens = append(tokens, dotToken{text: src[start:i], line: line})
+		}
+	}
+	return tokens, nil
+}
+
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	graph    *Graph
+	directed bool
+	// Default attributes of nodes and edges, see attr_stmt:
+	nodeAttrs map[string]string
+	edgeAttrs map[string]string
+}
+
+// ParseDOT parses a graph or digraph in the DOT language. Subgraphs are
+// flattened and only the attributes which affect the layout of this
+// package are supported: rankdir, label, shape and style (rounded,
+// dashed and dotted) as well as dir and arrowhead to remove arrows.
+// Other attributes are ignored.
+func ParseDOT(src string) (*Graph, error) {
+	tokens, err := lexDOT(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotParser{
+		tokens:    tokens,
+		graph:     newGraph(),
+		nodeAttrs: map[string]string{},
+		edgeAttrs: map[string]string{},
+	}
+
+	if p.peekKeyword("strict") {
+		p.pos++
+	}
+	switch {
+	case p.peekKeyword("digraph"):
+		p.directed = true
+	case p.peekKeyword("graph"):
+	default:
+		return nil, p.unexpected("expected graph or digraph")
+	}
+	p.pos++
+	if t, ok := p.peek(); ok && (t.quoted || t.text != "{") {
+		p.pos++
+	}
+	if _, err := p.parseBlock(); err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, syntaxError(t.line, "unexpected '%s' after the graph", t.text)
+	}
+	return p.graph, nil
+}
+
+func (p *dotParser) peek() (dotToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return dotToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *dotParser) peekPunct(text string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && t.text == text
+}
+
+func (p *dotParser) peekKeyword(keyword string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *dotParser) line() int {
+	if t, ok := p.peek(); ok {
+		return t.line
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].line
+	}
+	return 1
+}
+
+func (p *dotParser) unexpected(expected string) error {
+	t, ok := p.peek()
+	if !ok {
+		return syntaxError(p.line(), "unexpected end of graph, %s", expected)
+	}
+	return syntaxError(t.line, "unexpected '%s', %s", t.text, expected)
+}
+
+func (p *dotParser) expect(text string) error {
+	if !p.peekPunct(text) {
+		return p.unexpected("expected '" + text + "'")
+	}
+	p.pos++
+	return nil
+}
+
+func isDOTID(t dotToken) bool {
+	return t.quoted || !strings.ContainsAny(t.text, "{}[];,=:") && t.text != "->" && t.text != "--"
+}
+
+func (p *dotParser) parseID() (string, error) {
+	t, ok := p.peek()
+	if !ok || !isDOTID(t) {
+		return "", p.unexpected("expected an ID")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// parseBlock parses statements in braces and
+// returns the IDs of the nodes which they mention.
+func (p *dotParser) parseBlock() ([]string, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	// Default attributes are scoped to subgraphs:
+	savedNodeAttrs, savedEdgeAttrs := p.nodeAttrs, p.edgeAttrs
+	p.nodeAttrs, p.edgeAttrs = maps.Clone(p.nodeAttrs), maps.Clone(p.edgeAttrs)
+	defer func() {
+		p.nodeAttrs, p.edgeAttrs = savedNodeAttrs, savedEdgeAttrs
+	}()
+
+	ids := []string{}
+	for !p.peekPunct("}") {
+		if _, ok := p.peek(); !ok {
+			return nil, p.unexpected("expected '}'")
+		}
+		stmtIDs, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, stmtIDs...)
+		if p.peekPunct(";") {
+			p.pos++
+		}
+	}
+	p.pos++
+	return ids, nil
+}
+
+func (p *dotParser) parseStatement() ([]string, error) {
+	switch {
+	case p.peekKeyword("graph"), p.peekKeyword("node"), p.peekKeyword("edge"):
+		kind := strings.ToLower(p.tokens[p.pos].text)
+		p.pos++
+		attrs, err := p.parseAttrLists()
+		if err != nil {
+			return nil, err
+		}
+		target := map[string]map[string]string{"node": p.nodeAttrs, "edge": p.edgeAttrs}[kind]
+		for k, v := range attrs {
+			if target != nil {
+				target[k] = v
+			} else {
+				p.applyGraphAttr(k, v)
+			}
+		}
+		return nil, nil
+	}
+
+	// ID '=' ID sets a graph attribute:
+	if p.pos+1 < len(p.tokens) && isDOTID(p.tokens[p.pos]) && p.tokens[p.pos+1].text == "=" &&
+		!p.tokens[p.pos+1].quoted {
+		key := p.tokens[p.pos].text
+		p.pos += 2
+		value, err := p.parseID()
+		if err != nil {
+			return nil, err
+		}
+		p.applyGraphAttr(key, value)
+		return nil, nil
+	}
+
+	// Node or edge statement:
+	from, err := p.parseEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	ids := append([]string{}, from...)
+	type hop struct{ from, to []string }
+	hops := []hop{}
+	for p.peekPunct("->") || p.peekPunct("--") {
+		op := p.tokens[p.pos]
+		if (op.text == "->") != p.directed {
+			return nil, syntaxError(op.line, "'%s' is not allowed in this kind of graph", op.text)
+		}
+		p.pos++
+		to, err := p.parseEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, hop{from, to})
+		ids = append(ids, to...)
+		from = to
+	}
+	attrs, err := p.parseAttrLists()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hops) == 0 {
+		for _, id := range ids {
+			p.applyNodeAttrs(id, attrs)
+		}
+		return ids, nil
+	}
+	merged := maps.Clone(p.edgeAttrs)
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	for _, h := range hops {
+		for _, f := range h.from {
+			for _, t := range h.to {
+				p.addEdge(f, t, merged)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// parseEndpoint parses a node ID (ports are ignored) or a subgraph.
+func (p *dotParser) parseEndpoint() ([]string, error) {
+	if p.peekKeyword("subgraph") {
+		p.pos++
+		if !p.peekPunct("{") {
+			if _, err := p.parseID(); err != nil {
+				return nil, err
+			}
+		}
+		return p.parseBlock()
+	}
+	if p.peekPunct("{") {
+		return p.parseBlock()
+	}
+	id, err := p.parseID()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekPunct(":") {
+		p.pos++
+		if _, err := p.parseID(); err != nil {
+			return nil, err
+		}
+	}
+	p.ensureNode(id)
+	return []string{id}, nil
+}
+
+func (p *dotParser) parseAttrLists() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.peekPunct("[") {
+		p.pos++
+		for !p.peekPunct("]") {
+			key, err := p.parseID()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.parseID()
+			if err != nil {
+				return nil, err
+			}
+			attrs[strings.ToLower(key)] = value
+			if p.peekPunct(",") || p.peekPunct(";") {
+				p.pos++
+			}
+		}
+		p.pos++
+	}
+	return attrs, nil
+}
+
+func (p *dotParser) applyGraphAttr(key string, value string) {
+	if strings.ToLower(key) != "rankdir" {
+		return
+	}
+	switch strings.ToUpper(value) {
+	case "LR":
+		p.graph.Direction = LeftRight
+	case "RL":
+		p.graph.Direction = RightLeft
+	case "BT":
+		p.graph.Direction = BottomTop
+	default:
+		p.graph.Direction = TopBottom
+	}
+}
+
+func (p *dotParser) ensureNode(id string) {
+	if _, created := p.graph.node(id, Box); created {
+		p.applyNodeAttrs(id, p.nodeAttrs)
+	}
+}
+
+func (p *dotParser) applyNodeAttrs(id string, attrs map[string]string) {
+	n, _ := p.graph.node(id, Box)
+	if label, ok := attrs["label"]; ok {
+		n.Label = label
+	}
+	if shape, ok := attrs["shape"]; ok {
+		switch strings.ToLower(shape) {
+		case "ellipse", "oval":
+			n.Shape = Ellipse
+		case "circle", "doublecircle", "point":
+			n.Shape = Circle
+		case "diamond":
+			n.Shape = Diamond
+		case "plaintext", "plain", "none", "underline":
+			n.Shape = Plain
+		default:
+			n.Shape = Box
+		}
+	}
+	if strings.Contains(strings.ToLower(attrs["style"]), "rounded") && n.Shape == Box {
+		n.Shape = Rounded
+	}
+}
+
+func (p *dotParser) addEdge(from string, to string, attrs map[string]string) {
+	style := strings.ToLower(attrs["style"])
+	arrow := p.directed
+	if dir := strings.ToLower(attrs["dir"]); dir == "none" {
+		arrow = false
+	} else if dir == "forward" || dir == "both" {
+		arrow = true
+	}
+	if strings.ToLower(attrs["arrowhead"]) == "none" {
+		arrow = false
+	}
+	p.graph.Edges = append(p.graph.Edges, &Edge{
+		From:   from,
+		To:     to,
+		Label:  attrs["label"],
+		Dashed: strings.Contains(style, "dashed") || strings.Contains(style, "dotted"),
+		Arrow:  arrow,
+	})
+}
@@ -0,0 +1,104 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe summarises a graph as its direction, nodes
+// (id[label]shape) and edges (from>to|label|dashed,arrow).
+func describe(g *Graph) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d;", g.Direction)
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&sb, " %s[%s]%d", n.ID, n.Label, n.Shape)
+	}
+	sb.WriteString(";")
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, " %s>%s|%s|%t,%t", e.From, e.To, e.Label, e.Dashed, e.Arrow)
+	}
+	return sb.String()
+}
+
+func TestParseDOT(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name:     "chain",
+			src:      "digraph { a -> b -> c }",
+			expected: "0; a[a]0 b[b]0 c[c]0; a>b||false,true b>c||false,true",
+		},
+		{
+			name:     "undirected graph",
+			src:      "graph G { a -- b }",
+			expected: "0; a[a]0 b[b]0; a>b||false,false",
+		},
+		{
+			name: "attributes",
+			src: "digraph {\n rankdir=LR\n node [shape=ellipse]\n a [label=\"Start here\"]\n" +
+				" a -> b [label=\"go\", style=dashed]\n // comment\n b -> c [dir=none]\n}",
+			expected: "2; a[Start here]2 b[b]2 c[c]2; a>b|go|true,true b>c||false,false",
+		},
+		{
+			name:     "shapes",
+			src:      "digraph { a [shape=diamond] b [shape=circle] c [shape=box, style=rounded] d [shape=plaintext] }",
+			expected: "0; a[a]4 b[b]3 c[c]1 d[d]5;",
+		},
+		{
+			name:     "subgraphs are flattened",
+			src:      "digraph { subgraph cluster_x { a; b } a -> {c d} }",
+			expected: "0; a[a]0 b[b]0 c[c]0 d[d]0; a>c||false,true a>d||false,true",
+		},
+		{
+			name:     "self loop",
+			src:      "strict digraph { a -> a }",
+			expected: "0; a[a]0; a>a||false,true",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseDOT(test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := describe(g); actual != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseDOTSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		msg  string
+		line int
+	}{
+		{"foo { }", "unexpected 'foo', expected graph or digraph", 1},
+		{"digraph { a -> }", "unexpected '}', expected an ID", 1},
+		{"digraph {\n a -> b\n c [label=\"x]\n}", "unterminated string", 3},
+		{"digraph {\n a -> b\n", "unexpected end of graph, expected '}'", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := ParseDOT(test.src)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected a SyntaxError, got %v", err)
+			}
+			if syntaxErr.Msg != test.msg {
+				t.Errorf("expected message '%s', got '%s'", test.msg, syntaxErr.Msg)
+			}
+			if syntaxErr.Line != test.line {
+				t.Errorf("expected line %d, got %d", test.line, syntaxErr.Line)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package diagram renders graphs which are written in a subset of the
+// Graphviz DOT language or as Mermaid flowcharts into SVG.
+package diagram
+
+import "fmt"
+
+type Direction int
+
+const (
+	TopBottom Direction = iota
+	BottomTop
+	LeftRight
+	RightLeft
+)
+
+type Shape int
+
+const (
+	Box Shape = iota
+	Rounded
+	Ellipse
+	Circle
+	Diamond
+	Plain
+)
+
+type Node struct {
+	ID    string
+	Label string
+	Shape Shape
+}
+
+type Edge struct {
+	From   string
+	To     string
+	Label  string
+	Dashed bool
+	Arrow  bool
+}
+
+// Graph is a diagram of nodes and edges,
+// independent of the language it was written in.
+type Graph struct {
+	Direction Direction
+	Nodes     []*Node
+	Edges     []*Edge
+	index     map[string]*Node
+}
+
+func newGraph() *Graph {
+	return &Graph{index: map[string]*Node{}}
+}
+
+// node returns the node with the given ID and
+// creates it with the given shape if it doesn't exist yet.
+func (g *Graph) node(id string, shape Shape) (*Node, bool) {
+	if n, ok := g.index[id]; ok {
+		return n, false
+	}
+	n := &Node{ID: id, Label: id, Shape: shape}
+	g.index[id] = n
+	g.Nodes = append(g.Nodes, n)
+	return n, true
+}
+
+// SyntaxError is an error in the source of a diagram.
+// Line is the line number in the source, starting at 1.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Msg
+}
+
+func syntaxError(line int, format string, args ...any) error {
+	return &SyntaxError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Languages which Render supports:
+const (
+	DOT     = "dot"
+	Mermaid = "mermaid"
+)
+
+// Render parses a diagram and renders it as SVG. The id prefixes the IDs
+// of SVG elements and must be unique within an HTML document.
+func Render(language string, source string, id string) (string, error) {
+	var g *Graph
+	var err error
+	switch language {
+	case DOT:
+		g, err = ParseDOT(source)
+	case Mermaid:
+		g, err = ParseMermaid(source)
+	default:
+		return "", fmt.Errorf("unsupported diagram language: %s", language)
+	}
+	if err != nil {
+		return "", err
+	}
+	return g.SVG(id), nil
+}
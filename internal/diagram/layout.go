@@ -0,0 +1,541 @@
+package diagram
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The layout is a simple layered (Sugiyama style) layout: cycles get
+// broken, nodes get assigned to ranks, edges which span multiple ranks
+// get dummy vertices, the order within ranks minimises crossings with
+// the barycenter heuristic and finally nodes are placed next to each
+// other, pulled towards their neighbours.
+//
+// All computations happen in a top to bottom orientation, where "cross"
+// is the axis within a rank and "along" the axis from rank to rank.
+
+const (
+	charWidth   = 7.5
+	lineHeight  = 18.0
+	paddingX    = 16.0
+	paddingY    = 10.0
+	minWidth    = 40.0
+	nodeGap     = 30.0
+	rankGap     = 50.0
+	margin      = 8.0
+	loopSize    = 24.0
+	orderSweeps = 8
+	placeSweeps = 8
+)
+
+type point struct {
+	x float64
+	y float64
+}
+
+type vertex struct {
+	node  *Node // nil for dummy vertices
+	rank  int
+	order int
+	// Size and position of the center in the orientation of the graph:
+	width  float64
+	height float64
+	x      float64
+	y      float64
+	// Position in the top to bottom orientation:
+	cross float64
+	along float64
+	up    []int
+	down  []int
+}
+
+type routedEdge struct {
+	edge *Edge
+	// The vertices which the edge passes, from its source to its target:
+	chain  []int
+	points []point
+	label  point
+}
+
+type layout struct {
+	graph    *Graph
+	vertices []*vertex
+	ranks    [][]int
+	edges    []routedEdge
+	width    float64
+	height   float64
+}
+
+// textSize estimates the size of a label, there are no font metrics.
+func textSize(label string) (float64, float64) {
+	lines := strings.Split(label, "\n")
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, utf8.RuneCountInString(line))
+	}
+	return float64(longest) * charWidth, float64(len(lines)) * lineHeight
+}
+
+func nodeSize(n *Node) (float64, float64) {
+	w, h := textSize(n.Label)
+	w, h = w+2*paddingX, h+2*paddingY
+	switch n.Shape {
+	case Ellipse:
+		w, h = w*1.25, h*1.3
+	case Circle:
+		d := max(w, h)
+		w, h = d, d
+	case Diamond:
+		w, h = w*1.5, h*1.8
+	}
+	return max(w, minWidth), h
+}
+
+func (g *Graph) horizontal() bool {
+	return g.Direction == LeftRight || g.Direction == RightLeft
+}
+
+func newLayout(g *Graph) *layout {
+	l := &layout{graph: g}
+	index := map[string]int{}
+	for i, n := range g.Nodes {
+		w, h := nodeSize(n)
+		l.vertices = append(l.vertices, &vertex{node: n, width: w, height: h})
+		index[n.ID] = i
+	}
+
+	// Break cycles by reversing edges which point back
+	// to a node on the current depth first search path:
+	type link struct {
+		edge     *Edge
+		from, to int
+		reversed bool
+	}
+	links := []*link{}
+	outgoing := make([][]*link, len(g.Nodes))
+	for _, e := range g.Edges {
+		from, to := index[e.From], index[e.To]
+		if from == to {
+			continue
+		}
+		lk := &link{edge: e, from: from, to: to}
+		links = append(links, lk)
+		outgoing[from] = append(outgoing[from], lk)
+	}
+	state := make([]int, len(g.Nodes)) // 0 = new, 1 = on path, 2 = done
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = 1
+		for _, lk := range outgoing[v] {
+			switch state[lk.to] {
+			case 0:
+				visit(lk.to)
+			case 1:
+				lk.reversed = true
+			}
+		}
+		state[v] = 2
+	}
+	for v := range g.Nodes {
+		if state[v] == 0 {
+			visit(v)
+		}
+	}
+
+	// Longest path ranking in topological order:
+	ranks := make([]int, len(g.Nodes))
+	incoming := make([]int, len(g.Nodes))
+	successors := make([][]int, len(g.Nodes))
+	for _, lk := range links {
+		from, to := lk.from, lk.to
+		if lk.reversed {
+			from, to = to, from
+		}
+		successors[from] = append(successors[from], to)
+		incoming[to]++
+	}
+	queue := []int{}
+	for v := range g.Nodes {
+		if incoming[v] == 0 {
+			queue = append(queue, v)
+		}
+	}
+	topological := []int{}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		topological = append(topological, v)
+		for _, s := range successors[v] {
+			ranks[s] = max(ranks[s], ranks[v]+1)
+			incoming[s]--
+			if incoming[s] == 0 {
+				queue = append(queue, s)
+			}
+		}
+	}
+	// Pull sources down next to their first successor:
+	for i := len(topological) - 1; i >= 0; i-- {
+		v := topological[i]
+		if len(successors[v]) == 0 || ranks[v] != 0 {
+			continue
+		}
+		lowest := math.MaxInt
+		for _, s := range successors[v] {
+			lowest = min(lowest, ranks[s])
+		}
+		ranks[v] = lowest - 1
+	}
+	for v, r := range ranks {
+		l.vertices[v].rank = r
+	}
+
+	// Chains of dummy vertices route edges across ranks:
+	for _, lk := range links {
+		from, to := lk.from, lk.to
+		if lk.reversed {
+			from, to = to, from
+		}
+		chain := []int{from}
+		for r := ranks[from] + 1; r < ranks[to]; r++ {
+			l.vertices = append(l.vertices, &vertex{rank: r})
+			chain = append(chain, len(l.vertices)-1)
+		}
+		chain = append(chain, to)
+		for i := 0; i+1 < len(chain); i++ {
+			l.vertices[chain[i]].down = append(l.vertices[chain[i]].down, chain[i+1])
+			l.vertices[chain[i+1]].up = append(l.vertices[chain[i+1]].up, chain[i])
+		}
+		if lk.reversed {
+			slices.Reverse(chain)
+		}
+		l.edges = append(l.edges, routedEdge{edge: lk.edge, chain: chain})
+	}
+	for _, e := range g.Edges {
+		if e.From == e.To {
+			l.edges = append(l.edges, routedEdge{edge: e, chain: []int{index[e.From]}})
+		}
+	}
+
+	maxRank := 0
+	for _, v := range l.vertices {
+		maxRank = max(maxRank, v.rank)
+	}
+	l.ranks = make([][]int, maxRank+1)
+	for i, v := range l.vertices {
+		v.order = len(l.ranks[v.rank])
+		l.ranks[v.rank] = append(l.ranks[v.rank], i)
+	}
+
+	l.orderRanks()
+	l.place()
+	l.route()
+	return l
+}
+
+func barycenter(neighbours []int, vertices []*vertex, fallback float64) float64 {
+	if len(neighbours) == 0 {
+		return fallback
+	}
+	sum := 0.0
+	for _, n := range neighbours {
+		sum += float64(vertices[n].order)
+	}
+	return sum / float64(len(neighbours))
+}
+
+func (l *layout) crossings() int {
+	count := 0
+	for r := 0; r+1 < len(l.ranks); r++ {
+		type segment struct{ from, to int }
+		segments := []segment{}
+		for _, v := range l.ranks[r] {
+			for _, d := range l.vertices[v].down {
+				segments = append(segments, segment{l.vertices[v].order, l.vertices[d].order})
+			}
+		}
+		for i := range segments {
+			for j := i + 1; j < len(segments); j++ {
+				a, b := segments[i], segments[j]
+				if (a.from-b.from)*(a.to-b.to) < 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// orderRanks sorts the vertices within ranks by the average position
+// of their neighbours and keeps the order with the fewest crossings.
+func (l *layout) orderRanks() {
+	best := l.crossings()
+	bestOrder := l.orders()
+	for sweep := 0; sweep < orderSweeps && best > 0; sweep++ {
+		down := sweep%2 == 0
+		for i := range l.ranks {
+			r := i
+			if !down {
+				r = len(l.ranks) - 1 - i
+			}
+			rank := l.ranks[r]
+			weights := map[int]float64{}
+			for _, v := range rank {
+				vx := l.vertices[v]
+				neighbours := vx.up
+				if !down {
+					neighbours = vx.down
+				}
+				weights[v] = barycenter(neighbours, l.vertices, float64(vx.order))
+			}
+			sort.SliceStable(rank, func(a, b int) bool {
+				return weights[rank[a]] < weights[rank[b]]
+			})
+			for order, v := range rank {
+				l.vertices[v].order = order
+			}
+		}
+		if c := l.crossings(); c < best {
+			best = c
+			bestOrder = l.orders()
+		}
+	}
+	for i, order := range bestOrder {
+		l.vertices[i].order = order
+	}
+	for _, rank := range l.ranks {
+		sort.Slice(rank, func(a, b int) bool {
+			return l.vertices[rank[a]].order < l.vertices[rank[b]].order
+		})
+	}
+}
+
+func (l *layout) orders() []int {
+	orders := make([]int, len(l.vertices))
+	for i, v := range l.vertices {
+		orders[i] = v.order
+	}
+	return orders
+}
+
+// crossSize is the size of a vertex within its rank and alongSize
+// the size from rank to rank, which depend on the direction.
+func (l *layout) crossSize(v *vertex) float64 {
+	if l.graph.horizontal() {
+		return v.height
+	}
+	return v.width
+}
+
+func (l *layout) alongSize(v *vertex) float64 {
+	if l.graph.horizontal() {
+		return v.width
+	}
+	return v.height
+}
+
+// place assigns coordinates to all vertices.
+func (l *layout) place() {
+	// Initially all ranks are packed from the left:
+	for _, rank := range l.ranks {
+		pos := 0.0
+		for _, v := range rank {
+			vx := l.vertices[v]
+			vx.cross = pos + l.crossSize(vx)/2
+			pos += l.crossSize(vx) + nodeGap
+		}
+	}
+
+	// Vertices are pulled towards their neighbours in alternating
+	// directions, while keeping the gap to the vertices next to them:
+	for sweep := range placeSweeps {
+		for i := range l.ranks {
+			r := i
+			if sweep%2 == 1 {
+				r = len(l.ranks) - 1 - i
+			}
+			rank := l.ranks[r]
+			desired := make([]float64, len(rank))
+			for j, v := range rank {
+				vx := l.vertices[v]
+				neighbours := append(append([]int{}, vx.up...), vx.down...)
+				desired[j] = vx.cross
+				if len(neighbours) > 0 {
+					sum := 0.0
+					for _, n := range neighbours {
+						sum += l.vertices[n].cross
+					}
+					desired[j] = sum / float64(len(neighbours))
+				}
+			}
+			for j, v := range rank {
+				vx := l.vertices[v]
+				vx.cross = desired[j]
+				if j > 0 {
+					prev := l.vertices[rank[j-1]]
+					vx.cross = max(vx.cross, prev.cross+(l.crossSize(prev)+l.crossSize(vx))/2+nodeGap)
+				}
+			}
+			for j := len(rank) - 2; j >= 0; j-- {
+				vx, next := l.vertices[rank[j]], l.vertices[rank[j+1]]
+				vx.cross = min(vx.cross, next.cross-(l.crossSize(vx)+l.crossSize(next))/2-nodeGap)
+			}
+		}
+	}
+
+	// Ranks are as thick as their largest vertex and edge
+	// labels need room between the ranks:
+	gap := rankGap
+	for _, e := range l.graph.Edges {
+		if len(e.Label) > 0 && e.From != e.To {
+			w, h := textSize(e.Label)
+			if l.graph.horizontal() {
+				gap = max(gap, w+2*nodeGap)
+			} else {
+				gap = max(gap, h+2*nodeGap)
+			}
+		}
+	}
+	pos := 0.0
+	for _, rank := range l.ranks {
+		thickness := 0.0
+		for _, v := range rank {
+			thickness = max(thickness, l.alongSize(l.vertices[v]))
+		}
+		for _, v := range rank {
+			l.vertices[v].along = pos + thickness/2
+		}
+		pos += thickness + gap
+	}
+	totalAlong := pos - gap
+
+	minCross, maxCross := math.Inf(1), math.Inf(-1)
+	for _, v := range l.vertices {
+		minCross = min(minCross, v.cross-l.crossSize(v)/2)
+		maxCross = max(maxCross, v.cross+l.crossSize(v)/2)
+	}
+	// Self loops stick out on the side of their node:
+	for _, e := range l.graph.Edges {
+		if e.From == e.To {
+			maxCross += loopSize
+			break
+		}
+	}
+
+	for _, v := range l.vertices {
+		cross, along := v.cross-minCross+margin, v.along+margin
+		switch l.graph.Direction {
+		case TopBottom:
+			v.x, v.y = cross, along
+		case BottomTop:
+			v.x, v.y = cross, totalAlong-v.along+margin
+		case LeftRight:
+			v.x, v.y = along, cross
+		case RightLeft:
+			v.x, v.y = totalAlong-v.along+margin, cross
+		}
+	}
+	crossExtent, alongExtent := maxCross-minCross+2*margin, totalAlong+2*margin
+	if l.graph.horizontal() {
+		l.width, l.height = alongExtent, crossExtent
+	} else {
+		l.width, l.height = crossExtent, alongExtent
+	}
+}
+
+// route turns the vertex chains of edges into points, which start and
+// end at the borders of their nodes.
+func (l *layout) route() {
+	for i := range l.edges {
+		e := &l.edges[i]
+		if len(e.chain) == 1 {
+			v := l.vertices[e.chain[0]]
+			e.points = l.loopPoints(v)
+			e.label = midpoint(e.points)
+			continue
+		}
+		points := make([]point, len(e.chain))
+		for j, v := range e.chain {
+			points[j] = point{x: l.vertices[v].x, y: l.vertices[v].y}
+		}
+		first := l.vertices[e.chain[0]]
+		last := l.vertices[e.chain[len(e.chain)-1]]
+		points[0] = clip(first, points[0], points[1])
+		points[len(points)-1] = clip(last, points[len(points)-1], points[len(points)-2])
+		e.points = points
+		e.label = midpoint(points)
+	}
+}
+
+// loopPoints draws a self loop on the right or bottom side of a node.
+func (l *layout) loopPoints(v *vertex) []point {
+	if l.graph.horizontal() {
+		y := v.y + v.height/2
+		return []point{
+			{x: v.x - v.width/4, y: y},
+			{x: v.x - v.width/4, y: y + loopSize},
+			{x: v.x + v.width/4, y: y + loopSize},
+			{x: v.x + v.width/4, y: y},
+		}
+	}
+	x := v.x + v.width/2
+	return []point{
+		{x: x, y: v.y - v.height/4},
+		{x: x + loopSize, y: v.y - v.height/4},
+		{x: x + loopSize, y: v.y + v.height/4},
+		{x: x, y: v.y + v.height/4},
+	}
+}
+
+// clip moves the end of an edge from the center of
+// a vertex onto its border, towards the next point.
+func clip(v *vertex, center point, toward point) point {
+	if v.node == nil || v.node.Shape == Plain && len(v.node.Label) == 0 {
+		return center
+	}
+	dx, dy := toward.x-center.x, toward.y-center.y
+	if dx == 0 && dy == 0 {
+		return center
+	}
+	hw, hh := v.width/2, v.height/2
+	var t float64
+	switch v.node.Shape {
+	case Ellipse, Circle:
+		t = 1 / math.Sqrt((dx*dx)/(hw*hw)+(dy*dy)/(hh*hh))
+	case Diamond:
+		t = 1 / (math.Abs(dx)/hw + math.Abs(dy)/hh)
+	default:
+		t = math.Inf(1)
+		if dx != 0 {
+			t = hw / math.Abs(dx)
+		}
+		if dy != 0 {
+			t = min(t, hh/math.Abs(dy))
+		}
+	}
+	t = min(t, 1)
+	return point{x: center.x + dx*t, y: center.y + dy*t}
+}
+
+// midpoint returns the point halfway along a polyline.
+func midpoint(points []point) point {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += math.Hypot(points[i].x-points[i-1].x, points[i].y-points[i-1].y)
+	}
+	remaining := total / 2
+	for i := 1; i < len(points); i++ {
+		length := math.Hypot(points[i].x-points[i-1].x, points[i].y-points[i-1].y)
+		if length >= remaining && length > 0 {
+			t := remaining / length
+			return point{
+				x: points[i-1].x + (points[i].x-points[i-1].x)*t,
+				y: points[i-1].y + (points[i].y-points[i-1].y)*t,
+			}
+		}
+		remaining -= length
+	}
+	return points[0]
+}
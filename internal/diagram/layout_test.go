@@ -0,0 +1,158 @@
+package diagram
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func parse(t *testing.T, src string) *Graph {
+	t.Helper()
+	g, err := ParseDOT(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// vertexOf returns the vertex of the node with the given ID.
+func vertexOf(t *testing.T, l *layout, id string) *vertex {
+	t.Helper()
+	for _, v := range l.vertices {
+		if v.node != nil && v.node.ID == id {
+			return v
+		}
+	}
+	t.Fatalf("missing vertex for node '%s'", id)
+	return nil
+}
+
+func TestLayoutRanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		ranks   map[string]int
+		dummies int
+	}{
+		{
+			name:  "chain",
+			src:   "digraph { a -> b -> c }",
+			ranks: map[string]int{"a": 0, "b": 1, "c": 2},
+		},
+		{
+			name:    "long edge",
+			src:     "digraph { a -> b -> c; a -> c }",
+			ranks:   map[string]int{"a": 0, "b": 1, "c": 2},
+			dummies: 1,
+		},
+		{
+			name:  "cycle",
+			src:   "digraph { a -> b -> c -> a }",
+			ranks: map[string]int{"a": 0, "b": 1, "c": 2},
+			// The reversed edge c -> a spans two ranks:
+			dummies: 1,
+		},
+		{
+			name:  "source next to its successor",
+			src:   "digraph { a -> b -> c -> d; x -> d }",
+			ranks: map[string]int{"a": 0, "b": 1, "c": 2, "d": 3, "x": 2},
+		},
+		{
+			name:  "self loop",
+			src:   "digraph { a -> a }",
+			ranks: map[string]int{"a": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newLayout(parse(t, test.src))
+			for id, rank := range test.ranks {
+				if actual := vertexOf(t, l, id).rank; actual != rank {
+					t.Errorf("expected node '%s' in rank %d, got %d", id, rank, actual)
+				}
+			}
+			dummies := 0
+			for _, v := range l.vertices {
+				if v.node == nil {
+					dummies++
+				}
+			}
+			if dummies != test.dummies {
+				t.Errorf("expected %d dummy vertices, got %d", test.dummies, dummies)
+			}
+		})
+	}
+}
+
+func TestLayoutEdgesConnectTheirNodes(t *testing.T) {
+	l := newLayout(parse(t, "digraph { a -> b -> c -> a; a -> c; c -> c }"))
+	if len(l.edges) != 5 {
+		t.Fatalf("expected 5 edges, got %d", len(l.edges))
+	}
+	for _, e := range l.edges {
+		from, to := l.vertices[e.chain[0]], l.vertices[e.chain[len(e.chain)-1]]
+		if from.node.ID != e.edge.From || to.node.ID != e.edge.To {
+			t.Errorf("expected edge %s -> %s, got a chain from %s to %s", e.edge.From, e.edge.To, from.node.ID, to.node.ID)
+		}
+		if len(e.points) < 2 {
+			t.Errorf("expected edge %s -> %s to have points, got %v", e.edge.From, e.edge.To, e.points)
+			continue
+		}
+		for _, end := range []struct {
+			v *vertex
+			p point
+		}{{from, e.points[0]}, {to, e.points[len(e.points)-1]}} {
+			dx, dy := math.Abs(end.p.x-end.v.x), math.Abs(end.p.y-end.v.y)
+			if dx > end.v.width/2+0.5 || dy > end.v.height/2+0.5 {
+				t.Errorf("expected edge %s -> %s to end at the border of '%s', got %v", e.edge.From, e.edge.To, end.v.node.ID, end.p)
+			}
+			if dx < 0.5 && dy < 0.5 {
+				t.Errorf("expected edge %s -> %s to end at the border of '%s', not its center", e.edge.From, e.edge.To, end.v.node.ID)
+			}
+		}
+	}
+}
+
+func TestLayoutNodesDontOverlap(t *testing.T) {
+	l := newLayout(parse(t, `digraph { a -> {b c d e}; b -> f; c -> f; d -> g; e -> g; x [label="A rather long label"]; a -> x }`))
+	for rank, vertices := range l.ranks {
+		ordered := append([]int{}, vertices...)
+		sort.Slice(ordered, func(i, j int) bool {
+			return l.vertices[ordered[i]].cross < l.vertices[ordered[j]].cross
+		})
+		for i := 0; i+1 < len(ordered); i++ {
+			v, w := l.vertices[ordered[i]], l.vertices[ordered[i+1]]
+			if gap := w.cross - v.cross - (l.crossSize(v)+l.crossSize(w))/2; gap < 0 {
+				t.Errorf("expected vertices of rank %d not to overlap, got a gap of %f", rank, gap)
+			}
+		}
+	}
+	for _, v := range l.vertices {
+		if v.x-v.width/2 < 0 || v.y-v.height/2 < 0 || v.x+v.width/2 > l.width || v.y+v.height/2 > l.height {
+			t.Errorf("expected vertex at (%f, %f) to be within %fx%f", v.x, v.y, l.width, l.height)
+		}
+	}
+}
+
+func TestLayoutDirection(t *testing.T) {
+	tests := []struct {
+		rankdir string
+		after   func(a *vertex, b *vertex) bool
+	}{
+		{"TB", func(a *vertex, b *vertex) bool { return b.y > a.y }},
+		{"BT", func(a *vertex, b *vertex) bool { return b.y < a.y }},
+		{"LR", func(a *vertex, b *vertex) bool { return b.x > a.x }},
+		{"RL", func(a *vertex, b *vertex) bool { return b.x < a.x }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rankdir, func(t *testing.T) {
+			l := newLayout(parse(t, "digraph { rankdir="+test.rankdir+"; a -> b }"))
+			a, b := vertexOf(t, l, "a"), vertexOf(t, l, "b")
+			if !test.after(a, b) {
+				t.Errorf("expected b after a, got a at (%f, %f) and b at (%f, %f)", a.x, a.y, b.x, b.y)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package diagram
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mermaidHeader = regexp.MustCompile(`^(?:flowchart|graph)(?:\s+(TB|TD|BT|LR|RL))?\s*;?$`)
+	mermaidID     = regexp.MustCompile(`^[A-Za-z0-9_]+`)
+	mermaidClass  = regexp.MustCompile(`^:::[A-Za-z0-9_-]+`)
+
+	// Links with the label in the middle, e.g. -- yes --> or -. maybe .->
+	mermaidLabelledLink = regexp.MustCompile(`^(--|==|-\.)\s*([^-=.|>][^|]*?)\s*(-{2,}[>ox]?|={2,}[>ox]?|\.+-[>ox]?)`)
+	// Links like -->, ---, ==>, -.-> and <-->
+	mermaidLink = regexp.MustCompile(`^<?(-{2,}|={2,}|-\.+-)([>ox]?)`)
+	// Labels after links, e.g. -->|yes|
+	mermaidLinkLabel = regexp.MustCompile(`^\|([^|]*)\|`)
+
+	mermaidIgnored = []string{"subgraph", "end", "direction", "classDef", "class", "style", "linkStyle", "click"}
+)
+
+// Node shapes in the order in which they must be matched,
+// e.g. (( before ( and ([ before (:
+var mermaidShapes = []struct {
+	open  string
+	close string
+	shape Shape
+}{
+	{"((", "))", Circle},
+	{"([", "])", Rounded},
+	{"[(", ")]", Rounded},
+	{"[[", "]]", Box},
+	{"{{", "}}", Diamond},
+	{"[", "]", Box},
+	{"(", ")", Rounded},
+	{"{", "}", Diamond},
+	{">", "]", Box},
+}
+
+type mermaidParser struct {
+	graph *Graph
+	line  int
+	text  string
+}
+
+// ParseMermaid parses a Mermaid flowchart with nodes in the shapes
+// box, rounded, stadium, circle and diamond, and links with and without
+// labels, arrows and dashes. Subgraphs are flattened and styling
+// statements such as classDef are ignored.
+func ParseMermaid(src string) (*Graph, error) {
+	p := &mermaidParser{graph: newGraph()}
+	header := false
+	for i, line := range strings.Split(src, "\n") {
+		p.line = i + 1
+		if comment := strings.Index(line, "%%"); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !header {
+			match := mermaidHeader.FindStringSubmatch(line)
+			if match == nil {
+				return nil, syntaxError(p.line, "expected 'flowchart' or 'graph' with an optional direction")
+			}
+			switch match[1] {
+			case "BT":
+				p.graph.Direction = BottomTop
+			case "LR":
+				p.graph.Direction = LeftRight
+			case "RL":
+				p.graph.Direction = RightLeft
+			}
+			header = true
+			continue
+		}
+		for _, statement := range strings.Split(line, ";") {
+			if err := p.parseStatement(strings.TrimSpace(statement)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !header {
+		return nil, syntaxError(1, "the flowchart is empty")
+	}
+	return p.graph, nil
+}
+
+func (p *mermaidParser) skipSpace() {
+	p.text = strings.TrimLeft(p.text, " \t")
+}
+
+func (p *mermaidParser) parseStatement(statement string) error {
+	if len(statement) == 0 {
+		return nil
+	}
+	keyword, _, _ := strings.Cut(statement, " ")
+	for _, ignored := range mermaidIgnored {
+		if keyword == ignored {
+			return nil
+		}
+	}
+
+	p.text = statement
+	from, err := p.parseNodes()
+	if err != nil {
+		return err
+	}
+	for {
+		p.skipSpace()
+		if len(p.text) == 0 {
+			return nil
+		}
+		edge, err := p.parseLink()
+		if err != nil {
+			return err
+		}
+		to, err := p.parseNodes()
+		if err != nil {
+			return err
+		}
+		for _, f := range from {
+			for _, t := range to {
+				e := *edge
+				e.From, e.To = f, t
+				p.graph.Edges = append(p.graph.Edges, &e)
+			}
+		}
+		from = to
+	}
+}
+
+// parseNodes parses one or more nodes separated by &.
+func (p *mermaidParser) parseNodes() ([]string, error) {
+	ids := []string{}
+	for {
+		p.skipSpace()
+		id, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		p.skipSpace()
+		if !strings.HasPrefix(p.text, "&") {
+			return ids, nil
+		}
+		p.text = p.text[1:]
+	}
+}
+
+func (p *mermaidParser) parseNode() (string, error) {
+	id := mermaidID.FindString(p.text)
+	if len(id) == 0 {
+		return "", p.unexpected("expected a node ID")
+	}
+	p.text = p.text[len(id):]
+	n, _ := p.graph.node(id, Box)
+
+	for _, s := range mermaidShapes {
+		if !strings.HasPrefix(p.text, s.open) {
+			continue
+		}
+		p.text = p.text[len(s.open):]
+		label, err := p.parseText(s.close)
+		if err != nil {
+			return "", err
+		}
+		n.Label = label
+		n.Shape = s.shape
+		break
+	}
+	if class := mermaidClass.FindString(p.text); len(class) > 0 {
+		p.text = p.text[len(class):]
+	}
+	return id, nil
+}
+
+// parseText parses the text of a node or link up to the closing delimiter.
+func (p *mermaidParser) parseText(closing string) (string, error) {
+	p.skipSpace()
+	text := ""
+	if strings.HasPrefix(p.text, `"`) {
+		end := strings.Index(p.text[1:], `"`)
+		if end < 0 {
+			return "", syntaxError(p.line, "unterminated string")
+		}
+		text = p.text[1 : end+1]
+		p.text = strings.TrimLeft(p.text[end+2:], " \t")
+		if !strings.HasPrefix(p.text, closing) {
+			return "", p.unexpected("expected '" + closing + "'")
+		}
+	} else {
+		end := strings.Index(p.text, closing)
+		if end < 0 {
+			return "", syntaxError(p.line, "missing '%s'", closing)
+		}
+		text = strings.TrimSpace(p.text[:end])
+	}
+	p.text = p.text[strings.Index(p.text, closing)+len(closing):]
+	return mermaidLineBreaks.Replace(text), nil
+}
+
+var mermaidLineBreaks = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n")
+
+func (p *mermaidParser) parseLink() (*Edge, error) {
+	edge := &Edge{}
+	if match := mermaidLabelledLink.FindStringSubmatch(p.text); match != nil {
+		p.text = p.text[len(match[0]):]
+		edge.Label = mermaidLineBreaks.Replace(match[2])
+		edge.Dashed = match[1] == "-."
+		edge.Arrow = strings.ContainsAny(match[3][len(match[3])-1:], ">ox")
+		return edge, nil
+	}
+	match := mermaidLink.FindStringSubmatch(p.text)
+	if match == nil {
+		return nil, p.unexpected("expected a link such as -->")
+	}
+	p.text = p.text[len(match[0]):]
+	edge.Dashed = strings.Contains(match[1], ".")
+	edge.Arrow = len(match[2]) > 0
+	p.skipSpace()
+	if label := mermaidLinkLabel.FindStringSubmatch(p.text); label != nil {
+		p.text = p.text[len(label[0]):]
+		edge.Label = mermaidLineBreaks.Replace(strings.Trim(strings.TrimSpace(label[1]), `"`))
+	}
+	return edge, nil
+}
+
+func (p *mermaidParser) unexpected(expected string) error {
+	if len(p.text) == 0 {
+		return syntaxError(p.line, "unexpected end of line, %s", expected)
+	}
+	found := []rune(p.text)
+	if len(found) > 10 {
+		found = append(found[:10], '…')
+	}
+	return syntaxError(p.line, "unexpected '%s', %s", string(found), expected)
+}
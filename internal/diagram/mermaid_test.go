@@ -0,0 +1,78 @@
+package diagram
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMermaid(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name: "shapes and links",
+			src: "flowchart LR\n  A[Request] --> B{Cached?}\n  B -->|yes| C(Response)\n  B -- no --> D([Fetch])\n" +
+				"  D -.-> E((Done))\n  E --- F\n  F ==> G",
+			expected: "2; A[Request]0 B[Cached?]4 C[Response]1 D[Fetch]1 E[Done]3 F[F]0 G[G]0;" +
+				" A>B||false,true B>C|yes|false,true B>D|no|false,true D>E||true,true E>F||false,false F>G||false,true",
+		},
+		{
+			name:     "multiple targets",
+			src:      "graph TD\n A --> B & C",
+			expected: "0; A[A]0 B[B]0 C[C]0; A>B||false,true A>C||false,true",
+		},
+		{
+			name:     "subgraphs and styles",
+			src:      "flowchart BT\n subgraph one\n a --> b\n end\n classDef x fill:#f00\n a:::x --> c",
+			expected: "1; a[a]0 b[b]0 c[c]0; a>b||false,true a>c||false,true",
+		},
+		{
+			name:     "comments",
+			src:      "flowchart RL\n %% comment\n A-->B",
+			expected: "3; A[A]0 B[B]0; A>B||false,true",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := ParseMermaid(test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := describe(g); actual != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseMermaidSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		msg  string
+		line int
+	}{
+		{"sequenceDiagram\n A->>B: hi", "expected 'flowchart' or 'graph' with an optional direction", 1},
+		{"flowchart XX\n A-->B", "expected 'flowchart' or 'graph' with an optional direction", 1},
+		{"flowchart LR\n A[unclosed --> B", "missing ']'", 2},
+		{"flowchart LR\n A --> B\n C --> \n", "unexpected end of line, expected a node ID", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := ParseMermaid(test.src)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected a SyntaxError, got %v", err)
+			}
+			if syntaxErr.Msg != test.msg {
+				t.Errorf("expected message '%s', got '%s'", test.msg, syntaxErr.Msg)
+			}
+			if syntaxErr.Line != test.line {
+				t.Errorf("expected line %d, got %d", test.line, syntaxErr.Line)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package diagram
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// num formats a coordinate with at most one decimal.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+// writeText writes a label with one tspan per line, centered on a point.
+func writeText(sb *strings.Builder, class string, p point, label string) {
+	lines := strings.Split(label, "\n")
+	fmt.Fprintf(sb, `<text class="%s" x="%s" y="%s" text-anchor="middle" dominant-baseline="central">`,
+		class, num(p.x), num(p.y))
+	if len(lines) == 1 {
+		sb.WriteString(html.EscapeString(label))
+	} else {
+		for i, line := range lines {
+			dy := lineHeight
+			if i == 0 {
+				dy = -lineHeight * float64(len(lines)-1) / 2
+			}
+			fmt.Fprintf(sb, `<tspan x="%s" dy="%s">%s</tspan>`, num(p.x), num(dy), html.EscapeString(line))
+		}
+	}
+	sb.WriteString("</text>")
+}
+
+func writeShape(sb *strings.Builder, v *vertex) {
+	x, y, w, h := v.x, v.y, v.width, v.height
+	switch v.node.Shape {
+	case Box:
+		fmt.Fprintf(sb, `<rect x="%s" y="%s" width="%s" height="%s"/>`, num(x-w/2), num(y-h/2), num(w), num(h))
+	case Rounded:
+		fmt.Fprintf(sb, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s"/>`,
+			num(x-w/2), num(y-h/2), num(w), num(h), num(min(h/2, 12)))
+	case Ellipse, Circle:
+		fmt.Fprintf(sb, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"/>`, num(x), num(y), num(w/2), num(h/2))
+	case Diamond:
+		fmt.Fprintf(sb, `<polygon points="%s,%s %s,%s %s,%s %s,%s"/>`,
+			num(x), num(y-h/2), num(x+w/2), num(y), num(x), num(y+h/2), num(x-w/2), num(y))
+	}
+}
+
+// path draws a smooth curve through all points.
+func path(points []point) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "M%s,%s", num(points[0].x), num(points[0].y))
+	if len(points) == 2 {
+		fmt.Fprintf(&sb, " L%s,%s", num(points[1].x), num(points[1].y))
+		return sb.String()
+	}
+	// Catmull-Rom spline as cubic Bézier curves:
+	for i := 0; i+1 < len(points); i++ {
+		p0 := points[max(i-1, 0)]
+		p1, p2 := points[i], points[i+1]
+		p3 := points[min(i+2, len(points)-1)]
+		c1 := point{x: p1.x + (p2.x-p0.x)/6, y: p1.y + (p2.y-p0.y)/6}
+		c2 := point{x: p2.x - (p3.x-p1.x)/6, y: p2.y - (p3.y-p1.y)/6}
+		fmt.Fprintf(&sb, " C%s,%s %s,%s %s,%s",
+			num(c1.x), num(c1.y), num(c2.x), num(c2.y), num(p2.x), num(p2.y))
+	}
+	return sb.String()
+}
+
+// SVG lays out the graph and renders it as an SVG element. The id
+// prefixes the IDs of SVG elements and must be unique within an HTML
+// document. Strokes and text use the current colour, so diagrams
+// follow the colour of the surrounding text.
+func (g *Graph) SVG(id string) string {
+	l := newLayout(g)
+	arrowID := id + "-arrow"
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" id="%s" class="diagram" role="img" `+
+		`viewBox="0 0 %s %s" width="%s" height="%s" font-size="14">`,
+		html.EscapeString(id), num(l.width), num(l.height), num(l.width), num(l.height))
+	fmt.Fprintf(&sb, `<defs><marker id="%s" viewBox="0 0 10 10" refX="9" refY="5" `+
+		`markerWidth="8" markerHeight="8" orient="auto-start-reverse">`+
+		`<path d="M0,0 L10,5 L0,10 z" fill="currentColor"/></marker></defs>`,
+		html.EscapeString(arrowID))
+
+	sb.WriteString(`<g class="diagram-edges" fill="none" stroke="currentColor" stroke-width="1.5">`)
+	for _, e := range l.edges {
+		sb.WriteString(`<path d="` + path(e.points) + `"`)
+		if e.edge.Dashed {
+			sb.WriteString(` stroke-dasharray="5,4"`)
+		}
+		if e.edge.Arrow {
+			sb.WriteString(` marker-end="url(#` + html.EscapeString(arrowID) + `)"`)
+		}
+		sb.WriteString("/>")
+	}
+	sb.WriteString("</g>")
+
+	sb.WriteString(`<g class="diagram-nodes" fill="none" stroke="currentColor" stroke-width="1.5">`)
+	for _, v := range l.vertices {
+		if v.node != nil {
+			writeShape(&sb, v)
+		}
+	}
+	sb.WriteString("</g>")
+
+	sb.WriteString(`<g class="diagram-labels" fill="currentColor">`)
+	for _, v := range l.vertices {
+		if v.node != nil {
+			writeText(&sb, "diagram-node-label", point{x: v.x, y: v.y}, v.node.Label)
+		}
+	}
+	for _, e := range l.edges {
+		if len(e.edge.Label) > 0 {
+			writeText(&sb, "diagram-edge-label", e.label, e.edge.Label)
+		}
+	}
+	sb.WriteString("</g></svg>")
+	return sb.String()
+}
@@ -0,0 +1,146 @@
+package disqus
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/comments"
+	"github.com/dustedcodes/blog/internal/sanitize"
+)
+
+const (
+	idPrefix = "disqus-"
+)
+
+// Export is the XML document which Disqus generates
+// when exporting all comments of a forum.
+type Export struct {
+	XMLName xml.Name `xml:"disqus"`
+	Threads []Thread `xml:"thread"`
+	Posts   []Post   `xml:"post"`
+}
+
+type Reference struct {
+	ID string `xml:"http://disqus.com/disqus-internals id,attr"`
+}
+
+type Author struct {
+	Name        string `xml:"name"`
+	Username    string `xml:"username"`
+	IsAnonymous bool   `xml:"isAnonymous"`
+}
+
+type Thread struct {
+	DisqusID   string `xml:"http://disqus.com/disqus-internals id,attr"`
+	Identifier string `xml:"id"`
+	Link       string `xml:"link"`
+	Title      string `xml:"title"`
+}
+
+type Post struct {
+	DisqusID  string     `xml:"http://disqus.com/disqus-internals id,attr"`
+	Message   string     `xml:"message"`
+	CreatedAt string     `xml:"createdAt"`
+	IsDeleted bool       `xml:"isDeleted"`
+	IsSpam    bool       `xml:"isSpam"`
+	Author    Author     `xml:"author"`
+	Thread    Reference  `xml:"thread"`
+	Parent    *Reference `xml:"parent"`
+}
+
+func Parse(r io.Reader) (*Export, error) {
+	export := &Export{}
+	err := xml.NewDecoder(r).Decode(export)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding Disqus export: %w", err)
+	}
+	return export, nil
+}
+
+// postID returns the blog post ID of a Disqus thread.
+// The article template sets the disqus_identifier to the blog post ID,
+// but threads which were created before that use the permalink instead.
+func (t Thread) postID() string {
+	if len(t.Identifier) > 0 && !strings.Contains(t.Identifier, "/") {
+		return t.Identifier
+	}
+
+	link, err := url.Parse(t.Link)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(link.Path, "/")
+}
+
+func (p Post) status() comments.Status {
+	switch {
+	case p.IsSpam:
+		return comments.StatusSpam
+	case p.IsDeleted:
+		return comments.StatusDeleted
+	default:
+		return comments.StatusApproved
+	}
+}
+
+func (a Author) displayName() string {
+	if len(a.Name) > 0 {
+		return a.Name
+	}
+	if len(a.Username) > 0 {
+		return a.Username
+	}
+	return "Anonymous"
+}
+
+// Comments maps all posts of a Disqus export to comments.
+// Comment IDs are derived from the Disqus IDs, which preserves
+// threading and makes repeated imports idempotent.
+func (e *Export) Comments() ([]*comments.Comment, error) {
+	postIDs := map[string]string{}
+	for _, t := range e.Threads {
+		postIDs[t.DisqusID] = t.postID()
+	}
+
+	result := []*comments.Comment{}
+	for _, p := range e.Posts {
+		postID := postIDs[p.Thread.ID]
+		if len(postID) == 0 {
+			return nil, fmt.Errorf("post %s of the Disqus export belongs to unknown thread %s", p.DisqusID, p.Thread.ID)
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, strings.TrimSpace(p.CreatedAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing date of Disqus post %s: %w", p.DisqusID, err)
+		}
+
+		parentID := ""
+		if p.Parent != nil && len(p.Parent.ID) > 0 {
+			parentID = idPrefix + p.Parent.ID
+		}
+
+		content := sanitize.HTML(p.Message)
+		result = append(result, &comments.Comment{
+			ID:        idPrefix + p.DisqusID,
+			PostID:    postID,
+			ParentID:  parentID,
+			Author:    p.Author.displayName(),
+			Content:   content,
+			HTML:      template.HTML(content), //nolint: gosec // sanitized above
+			CreatedAt: createdAt.UTC(),
+			Status:    p.status(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package disqus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/comments"
+)
+
+const testExport = `<?xml version="1.0" encoding="utf-8"?>
+<disqus xmlns="http://disqus.com" xmlns:dsq="http://disqus.com/disqus-internals">
+  <thread dsq:id="100">
+    <id>hello-world</id>
+    <link>https://dusted.codes/hello-world</link>
+    <title>Hello World</title>
+  </thread>
+  <thread dsq:id="200">
+    <id>https://dusted.codes/old-post</id>
+    <link>https://dusted.codes/old-post/</link>
+    <title>Old post</title>
+  </thread>
+  <post dsq:id="2">
+    <message><![CDATA[<p>Thanks!</p><script>alert(1)</script>]]></message>
+    <createdAt>2015-06-02T10:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name></name><username>bob</username><isAnonymous>false</isAnonymous></author>
+    <thread dsq:id="100" />
+    <parent dsq:id="1" />
+  </post>
+  <post dsq:id="1">
+    <message><![CDATA[<p>Great <a href="javascript:alert(1)">post</a></p>]]></message>
+    <createdAt>2015-06-01T10:00:00+02:00</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Alice</name><username>alice</username><isAnonymous>false</isAnonymous></author>
+    <thread dsq:id="100" />
+  </post>
+  <post dsq:id="3">
+    <message><![CDATA[<p>Buy now</p>]]></message>
+    <createdAt>2016-01-01T00:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>true</isSpam>
+    <author><name></name><username></username><isAnonymous>true</isAnonymous></author>
+    <thread dsq:id="200" />
+  </post>
+  <post dsq:id="4">
+    <message><![CDATA[<p>Removed</p>]]></message>
+    <createdAt>2016-01-02T00:00:00Z</createdAt>
+    <isDeleted>true</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Carol</name></author>
+    <thread dsq:id="200" />
+  </post>
+</disqus>`
+
+func TestComments(t *testing.T) {
+	export, err := Parse(strings.NewReader(testExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := export.Comments()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []comments.Comment{
+		{
+			ID:        "disqus-1",
+			PostID:    "hello-world",
+			Author:    "Alice",
+			Content:   "<p>Great <a>post</a></p>",
+			CreatedAt: time.Date(2015, 6, 1, 8, 0, 0, 0, time.UTC),
+			Status:    comments.StatusApproved,
+		},
+		{
+			ID:        "disqus-2",
+			PostID:    "hello-world",
+			ParentID:  "disqus-1",
+			Author:    "bob",
+			Content:   "<p>Thanks!</p>",
+			CreatedAt: time.Date(2015, 6, 2, 10, 0, 0, 0, time.UTC),
+			Status:    comments.StatusApproved,
+		},
+		{
+			ID:        "disqus-3",
+			PostID:    "old-post",
+			Author:    "Anonymous",
+			Content:   "<p>Buy now</p>",
+			CreatedAt: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+			Status:    comments.StatusSpam,
+		},
+		{
+			ID:        "disqus-4",
+			PostID:    "old-post",
+			Author:    "Carol",
+			Content:   "<p>Removed</p>",
+			CreatedAt: time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC),
+			Status:    comments.StatusDeleted,
+		},
+	}
+
+	if len(imported) != len(expected) {
+		t.Fatalf("expected %d comments, got %d", len(expected), len(imported))
+	}
+	for i, c := range imported {
+		e := expected[i]
+		e.HTML = c.HTML
+		if string(c.HTML) != c.Content {
+			t.Errorf("expected the HTML of comment %s to be its content, got %s", c.ID, c.HTML)
+		}
+		if fmt.Sprintf("%+v", *c) != fmt.Sprintf("%+v", e) {
+			t.Errorf("expected:\n%+v\ngot:\n%+v", e, *c)
+		}
+	}
+}
+
+func TestCommentsWithInvalidPosts(t *testing.T) {
+	tests := []struct {
+		name   string
+		export string
+		err    string
+	}{
+		{
+			name: "unknown thread",
+			export: `<disqus xmlns:dsq="http://disqus.com/disqus-internals">
+				<post dsq:id="1"><createdAt>2015-06-01T10:00:00Z</createdAt><thread dsq:id="100" /></post>
+			</disqus>`,
+			err: "belongs to unknown thread 100",
+		},
+		{
+			name: "invalid date",
+			export: `<disqus xmlns:dsq="http://disqus.com/disqus-internals">
+				<thread dsq:id="100"><id>hello-world</id></thread>
+				<post dsq:id="1"><createdAt>yesterday</createdAt><thread dsq:id="100" /></post>
+			</disqus>`,
+			err: "error parsing date of Disqus post 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			export, err := Parse(strings.NewReader(test.export))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = export.Comments()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing '%s', got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestParseInvalidExport(t *testing.T) {
+	_, err := Parse(strings.NewReader("<disqus><thread>"))
+	if err == nil {
+		t.Error("expected an error for a truncated export")
+	}
+}
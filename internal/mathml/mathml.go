@@ -0,0 +1,633 @@
+// Package mathml converts the commonly used subset of TeX math
+// into MathML, which browsers render without any JavaScript.
+package mathml
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError is an error in the TeX source.
+// Pos is the byte offset of the error in the source.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Msg
+}
+
+// Render converts TeX math into a MathML <math> element.
+// Display math is rendered as a block with limits
+// above and below large operators.
+func Render(tex string, display bool) (string, error) {
+	p := &parser{src: tex, end: len(tex), display: display}
+	nodes, err := p.parseExpr()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < p.end {
+		return "", p.unexpected()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		sb.WriteString(` display="block"`)
+	}
+	sb.WriteString(`><semantics>`)
+	sb.WriteString(mrow(nodes))
+	sb.WriteString(`<annotation encoding="application/x-tex">`)
+	sb.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	sb.WriteString(`</annotation></semantics></math>`)
+	return sb.String(), nil
+}
+
+// node is a rendered MathML element.
+type node struct {
+	xml string
+	// limits places scripts above and below in display mode:
+	limits bool
+}
+
+func mrow(nodes []node) string {
+	var sb strings.Builder
+	count := 0
+	for _, n := range nodes {
+		if len(n.xml) > 0 {
+			sb.WriteString(n.xml)
+			count++
+		}
+	}
+	if count == 1 {
+		return sb.String()
+	}
+	return "<mrow>" + sb.String() + "</mrow>"
+}
+
+func element(tag string, content string) node {
+	return node{xml: "<" + tag + ">" + html.EscapeString(content) + "</" + tag + ">"}
+}
+
+type parser struct {
+	src     string
+	pos     int
+	end     int
+	display bool
+	// The font of letters and digits, set by commands like \mathbb:
+	font    *alphabet
+	upright bool
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected() error {
+	if name, ok := p.peekCommand(); ok {
+		return p.errorf(p.pos, `unexpected \%s`, name)
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:p.end])
+	return p.errorf(p.pos, "unexpected '%c'", r)
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < p.end && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position
+// without consuming it. Names are either letters or a single character.
+func (p *parser) peekCommand() (string, bool) {
+	if p.pos >= p.end || p.src[p.pos] != '\\' {
+		return "", false
+	}
+	i := p.pos + 1
+	for i < p.end && isLetter(p.src[i]) {
+		i++
+	}
+	if i == p.pos+1 && i < p.end {
+		_, size := utf8.DecodeRuneInString(p.src[i:p.end])
+		i += size
+	}
+	return p.src[p.pos+1 : i], true
+}
+
+func (p *parser) readCommand() string {
+	name, _ := p.peekCommand()
+	p.pos += 1 + len(name)
+	return name
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// atEndOfExpr reports whether the current position ends an expression.
+func (p *parser) atEndOfExpr() bool {
+	if p.pos >= p.end {
+		return true
+	}
+	switch p.src[p.pos] {
+	case '}', '&':
+		return true
+	}
+	name, ok := p.peekCommand()
+	return ok && (name == "\\" || name == "right" || name == "end")
+}
+
+// parseExpr parses terms until the end of the input, a closing brace,
+// an alignment character, a new row, \right or \end.
+func (p *parser) parseExpr() ([]node, error) {
+	nodes := []node{}
+	for {
+		p.skipSpace()
+		if p.atEndOfExpr() {
+			return nodes, nil
+		}
+		n, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// parseTerm parses an atom and its superscript, subscript and primes.
+func (p *parser) parseTerm() (node, error) {
+	base := node{xml: "<mrow></mrow>"}
+	if c := p.src[p.pos]; c != '^' && c != '_' && c != '\'' {
+		var err error
+		base, err = p.parseAtom(false)
+		if err != nil {
+			return node{}, err
+		}
+	}
+
+	var sup, sub *node
+	primes := ""
+	for {
+		p.skipSpace()
+		if p.pos >= p.end {
+			break
+		}
+		start := p.pos
+		switch p.src[p.pos] {
+		case '\'':
+			p.pos++
+			primes += "′"
+			continue
+		case '^':
+			if sup != nil {
+				return node{}, p.errorf(start, "double superscript")
+			}
+			p.pos++
+			arg, err := p.parseArg("^")
+			if err != nil {
+				return node{}, err
+			}
+			sup = &arg
+			continue
+		case '_':
+			if sub != nil {
+				return node{}, p.errorf(start, "double subscript")
+			}
+			p.pos++
+			arg, err := p.parseArg("_")
+			if err != nil {
+				return node{}, err
+			}
+			sub = &arg
+			continue
+		}
+		break
+	}
+
+	if len(primes) > 0 {
+		prime := element("mo", primes)
+		if sup == nil {
+			sup = &prime
+		} else {
+			sup = &node{xml: mrow([]node{prime, *sup})}
+		}
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if base.limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != nil && sup != nil:
+		return node{xml: "<" + both + ">" + base.xml + sub.xml + sup.xml + "</" + both + ">"}, nil
+	case sub != nil:
+		return node{xml: "<" + under + ">" + base.xml + sub.xml + "</" + under + ">"}, nil
+	case sup != nil:
+		return node{xml: "<" + over + ">" + base.xml + sup.xml + "</" + over + ">"}, nil
+	}
+	return base, nil
+}
+
+// parseArg parses the argument of a command or script,
+// which is either a group or a single character or command.
+func (p *parser) parseArg(command string) (node, error) {
+	p.skipSpace()
+	if p.atEndOfExpr() || p.src[p.pos] == '^' || p.src[p.pos] == '_' {
+		return node{}, p.errorf(p.pos, `missing argument for %s`, command)
+	}
+	return p.parseAtom(true)
+}
+
+// parseGroup parses an expression in braces.
+func (p *parser) parseGroup() ([]node, error) {
+	start := p.pos
+	p.pos++
+	nodes, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= p.end {
+		return nil, p.errorf(start, "missing closing brace")
+	}
+	if p.src[p.pos] != '}' {
+		return nil, p.unexpected()
+	}
+	p.pos++
+	return nodes, nil
+}
+
+// readText reads the raw text of a group, e.g. the argument of \text.
+func (p *parser) readText(command string) (string, error) {
+	p.skipSpace()
+	if p.pos >= p.end || p.src[p.pos] != '{' {
+		return "", p.errorf(p.pos, `missing argument for \%s`, command)
+	}
+	start := p.pos
+	depth := 0
+	for i := p.pos; i < p.end; i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos = i + 1
+				return p.src[start+1 : i], nil
+			}
+		}
+	}
+	return "", p.errorf(start, "missing closing brace")
+}
+
+func (p *parser) parseAtom(single bool) (node, error) {
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		nodes, err := p.parseGroup()
+		if err != nil {
+			return node{}, err
+		}
+		return node{xml: mrow(nodes)}, nil
+	case c == '\\':
+		return p.parseCommand()
+	case isDigit(c) || (c == '.' && p.pos+1 < p.end && isDigit(p.src[p.pos+1])):
+		p.pos++
+		for !single && p.pos < p.end &&
+			(isDigit(p.src[p.pos]) || (p.src[p.pos] == '.' && p.pos+1 < p.end && isDigit(p.src[p.pos+1]))) {
+			p.pos++
+		}
+		return p.number(p.src[start:p.pos]), nil
+	case isLetter(c):
+		p.pos++
+		return p.identifier(rune(c)), nil
+	case c == '~':
+		p.pos++
+		return node{xml: `<mspace width="0.25em"></mspace>`}, nil
+	case c == '\'':
+		p.pos++
+		return element("mo", "′"), nil
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:p.end])
+	p.pos += size
+	if unicode.IsLetter(r) {
+		return p.identifier(r), nil
+	}
+	switch r {
+	case '-':
+		r = '−'
+	case '*':
+		r = '∗'
+	}
+	return element("mo", string(r)), nil
+}
+
+func (p *parser) number(digits string) node {
+	if p.font != nil {
+		digits = strings.Map(p.font.mapRune, digits)
+	}
+	return element("mn", digits)
+}
+
+func (p *parser) identifier(r rune) node {
+	if p.font != nil {
+		return element("mi", string(p.font.mapRune(r)))
+	}
+	if p.upright {
+		return node{xml: `<mi mathvariant="normal">` + html.EscapeString(string(r)) + `</mi>`}
+	}
+	return element("mi", string(r))
+}
+
+func (p *parser) parseCommand() (node, error) {
+	start := p.pos
+	name := p.readCommand()
+
+	if symbol, ok := escapedCharacters[name]; ok {
+		return element("mo", symbol), nil
+	}
+	if width, ok := spaces[name]; ok {
+		return node{xml: `<mspace width="` + width + `"></mspace>`}, nil
+	}
+	if symbol, ok := identifiers[name]; ok {
+		return element("mi", symbol), nil
+	}
+	if symbol, ok := uprightIdentifiers[name]; ok {
+		return node{xml: `<mi mathvariant="normal">` + symbol + `</mi>`}, nil
+	}
+	if symbol, ok := operators[name]; ok {
+		return element("mo", symbol), nil
+	}
+	if op, ok := largeOperators[name]; ok {
+		return node{xml: `<mo largeop="true">` + op.symbol + `</mo>`, limits: op.limits}, nil
+	}
+	if limits, ok := functions[name]; ok {
+		return node{xml: element("mi", name).xml, limits: limits}, nil
+	}
+	if font, ok := alphabets[name]; ok {
+		return p.parseStyled(name, &font, false)
+	}
+	if accent, ok := accents[name]; ok {
+		arg, err := p.parseArg(`\` + name)
+		if err != nil {
+			return node{}, err
+		}
+		if accent.under {
+			return node{xml: `<munder accentunder="true">` + arg.xml + element("mo", accent.symbol).xml + `</munder>`}, nil
+		}
+		return node{xml: `<mover accent="true">` + arg.xml + element("mo", accent.symbol).xml + `</mover>`}, nil
+	}
+
+	switch name {
+	case "!", "displaystyle", "textstyle", "limits", "nolimits":
+		return node{}, nil
+	case "mathrm":
+		return p.parseStyled(name, nil, true)
+	case "mathit":
+		return p.parseStyled(name, nil, false)
+	case "boldsymbol":
+		font := alphabets["mathbf"]
+		return p.parseStyled(name, &font, false)
+	case "text", "textrm", "textit", "textbf", "mbox", "mathtext":
+		text, err := p.readText(name)
+		if err != nil {
+			return node{}, err
+		}
+		return element("mtext", text), nil
+	case "operatorname":
+		text, err := p.readText(name)
+		if err != nil {
+			return node{}, err
+		}
+		return element("mi", text), nil
+	case "frac", "dfrac", "tfrac", "cfrac", "binom":
+		num, err := p.parseArg(`\` + name)
+		if err != nil {
+			return node{}, err
+		}
+		den, err := p.parseArg(`\` + name)
+		if err != nil {
+			return node{}, err
+		}
+		if name == "binom" {
+			return node{xml: `<mrow><mo>(</mo><mfrac linethickness="0">` + num.xml + den.xml + `</mfrac><mo>)</mo></mrow>`}, nil
+		}
+		return node{xml: "<mfrac>" + num.xml + den.xml + "</mfrac>"}, nil
+	case "sqrt":
+		return p.parseSqrt()
+	case "left":
+		return p.parseFenced(start)
+	case "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr":
+		delimiter, err := p.readDelimiter(name)
+		if err != nil {
+			return node{}, err
+		}
+		return element("mo", delimiter), nil
+	case "begin":
+		return p.parseEnvironment(start)
+	case "mod", "bmod":
+		return element("mo", "mod"), nil
+	case "pmod":
+		arg, err := p.parseArg(`\pmod`)
+		if err != nil {
+			return node{}, err
+		}
+		return node{xml: `<mrow><mo>(</mo><mo>mod</mo>` + arg.xml + `<mo>)</mo></mrow>`}, nil
+	case "not":
+		return p.parseNot(start)
+	case "right":
+		return node{}, p.errorf(start, `\right without \left`)
+	case "end":
+		return node{}, p.errorf(start, `\end without \begin`)
+	case "\\":
+		return node{}, p.errorf(start, `unexpected \\`)
+	}
+	return node{}, p.errorf(start, `unknown command \%s`, name)
+}
+
+// parseStyled parses the argument of a font command like \mathbb.
+func (p *parser) parseStyled(name string, font *alphabet, upright bool) (node, error) {
+	savedFont, savedUpright := p.font, p.upright
+	p.font, p.upright = font, upright
+	defer func() {
+		p.font, p.upright = savedFont, savedUpright
+	}()
+	return p.parseArg(`\` + name)
+}
+
+func (p *parser) parseSqrt() (node, error) {
+	p.skipSpace()
+	if p.pos < p.end && p.src[p.pos] == '[' {
+		start := p.pos
+		closing := strings.IndexByte(p.src[p.pos:p.end], ']')
+		if closing < 0 {
+			return node{}, p.errorf(start, "missing closing bracket")
+		}
+		savedEnd := p.end
+		p.pos++
+		p.end = start + closing
+		index, err := p.parseExpr()
+		if err != nil {
+			return node{}, err
+		}
+		if p.pos < p.end {
+			return node{}, p.unexpected()
+		}
+		p.end = savedEnd
+		p.pos++
+		radicand, err := p.parseArg(`\sqrt`)
+		if err != nil {
+			return node{}, err
+		}
+		return node{xml: "<mroot>" + radicand.xml + mrow(index) + "</mroot>"}, nil
+	}
+	radicand, err := p.parseArg(`\sqrt`)
+	if err != nil {
+		return node{}, err
+	}
+	return node{xml: "<msqrt>" + radicand.xml + "</msqrt>"}, nil
+}
+
+// readDelimiter reads the delimiter after commands like \left.
+func (p *parser) readDelimiter(command string) (string, error) {
+	p.skipSpace()
+	start := p.pos
+	token := ""
+	if name, ok := p.peekCommand(); ok {
+		token = `\` + name
+	} else if p.pos < p.end {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:p.end])
+		token = p.src[p.pos : p.pos+size]
+	}
+	delimiter, ok := delimiters[token]
+	if !ok {
+		return "", p.errorf(start, `missing or invalid delimiter after \%s`, command)
+	}
+	p.pos += len(token)
+	return delimiter, nil
+}
+
+func fence(delimiter string, form string) string {
+	if len(delimiter) == 0 {
+		return ""
+	}
+	return `<mo fence="true" form="` + form + `">` + html.EscapeString(delimiter) + `</mo>`
+}
+
+func (p *parser) parseFenced(start int) (node, error) {
+	open, err := p.readDelimiter("left")
+	if err != nil {
+		return node{}, err
+	}
+	nodes, err := p.parseExpr()
+	if err != nil {
+		return node{}, err
+	}
+	if name, ok := p.peekCommand(); !ok || name != "right" {
+		if p.pos < p.end {
+			return node{}, p.unexpected()
+		}
+		return node{}, p.errorf(start, `\left without \right`)
+	}
+	p.readCommand()
+	closing, err := p.readDelimiter("right")
+	if err != nil {
+		return node{}, err
+	}
+	return node{xml: "<mrow>" + fence(open, "prefix") + mrow(nodes) + fence(closing, "postfix") + "</mrow>"}, nil
+}
+
+func (p *parser) parseNot(start int) (node, error) {
+	p.skipSpace()
+	if p.pos < p.end && p.src[p.pos] == '=' {
+		p.pos++
+		return element("mo", "≠"), nil
+	}
+	if name, ok := p.peekCommand(); ok {
+		if symbol, ok := operators[name]; ok {
+			p.readCommand()
+			return element("mo", symbol+"̸"), nil
+		}
+	}
+	return node{}, p.errorf(start, `\not must be followed by a relation`)
+}
+
+// parseEnvironment parses matrices, cases and aligned equations,
+// whose cells are separated by & and rows by \\.
+func (p *parser) parseEnvironment(start int) (node, error) {
+	env, err := p.readText("begin")
+	if err != nil {
+		return node{}, err
+	}
+	fences, isMatrix := matrixFences[env]
+	columnAlign := ""
+	switch {
+	case isMatrix:
+	case env == "cases":
+		fences = [2]string{"{", ""}
+		columnAlign = "left left"
+	case env == "aligned" || env == "align" || env == "align*":
+		columnAlign = "right left"
+	default:
+		return node{}, p.errorf(start, "unknown environment '%s'", env)
+	}
+
+	var table strings.Builder
+	table.WriteString("<mtable")
+	if len(columnAlign) > 0 {
+		table.WriteString(` columnalign="` + columnAlign + `"`)
+	}
+	table.WriteString("><mtr>")
+	for {
+		cell, err := p.parseExpr()
+		if err != nil {
+			return node{}, err
+		}
+		table.WriteString("<mtd>" + mrow(cell) + "</mtd>")
+		if p.pos >= p.end {
+			return node{}, p.errorf(start, `missing \end{%s}`, env)
+		}
+		if p.src[p.pos] == '&' {
+			p.pos++
+			continue
+		}
+		name, ok := p.peekCommand()
+		if !ok {
+			return node{}, p.unexpected()
+		}
+		if name == "\\" {
+			p.readCommand()
+			table.WriteString("</mtr><mtr>")
+			continue
+		}
+		if name != "end" {
+			return node{}, p.unexpected()
+		}
+		endPos := p.pos
+		p.readCommand()
+		endEnv, err := p.readText("end")
+		if err != nil {
+			return node{}, err
+		}
+		if endEnv != env {
+			return node{}, p.errorf(endPos, `\begin{%s} ended by \end{%s}`, env, endEnv)
+		}
+		break
+	}
+	table.WriteString("</mtr></mtable>")
+
+	// A trailing \\ leaves an empty row behind:
+	xml := strings.ReplaceAll(table.String(), "<mtr><mtd></mtd></mtr>", "")
+	return node{xml: "<mrow>" + fence(fences[0], "prefix") + xml + fence(fences[1], "postfix") + "</mrow>"}, nil
+}
@@ -0,0 +1,108 @@
+package mathml
+
+import (
+	"errors"
+	"html"
+	"testing"
+)
+
+func math(display bool, tex string, body string) string {
+	attrs := ""
+	if display {
+		attrs = ` display="block"`
+	}
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML"` + attrs + `><semantics>` + body +
+		`<annotation encoding="application/x-tex">` + html.EscapeString(tex) + `</annotation></semantics></math>`
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		tex      string
+		display  bool
+		expected string
+	}{
+		{`x`, false, `<mi>x</mi>`},
+		{`12.5`, false, `<mn>12.5</mn>`},
+		{`a < b`, false, `<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>`},
+		{`x^2`, false, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{`a_i`, false, `<msub><mi>a</mi><mi>i</mi></msub>`},
+		{`f'(x)`, false, `<mrow><msup><mi>f</mi><mo>′</mo></msup><mo>(</mo><mi>x</mi><mo>)</mo></mrow>`},
+		{`\frac{1}{2}`, false, `<mfrac><mn>1</mn><mn>2</mn></mfrac>`},
+		{`\sqrt{x}`, false, `<msqrt><mi>x</mi></msqrt>`},
+		{`\sqrt[3]{x}`, false, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{`\alpha + \beta`, false, `<mrow><mi>α</mi><mo>+</mo><mi>β</mi></mrow>`},
+		{`\mathbb{R}`, false, `<mi>ℝ</mi>`},
+		{`\not=`, false, `<mo>≠</mo>`},
+		{`\text{if } x`, false, `<mrow><mtext>if </mtext><mi>x</mi></mrow>`},
+		{
+			`\sum_{i=1}^n i`, false,
+			`<mrow><msubsup><mo largeop="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi></mrow>`,
+		},
+		{
+			`\sum_{i=1}^n i`, true,
+			`<mrow><munderover><mo largeop="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi></mrow>`,
+		},
+		{
+			`\left( x \right)`, false,
+			`<mrow><mo fence="true" form="prefix">(</mo><mi>x</mi><mo fence="true" form="postfix">)</mo></mrow>`,
+		},
+		{
+			`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, true,
+			`<mrow><mo fence="true" form="prefix">(</mo><mtable>` +
+				`<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>` +
+				`<mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr>` +
+				`</mtable><mo fence="true" form="postfix">)</mo></mrow>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tex, func(t *testing.T) {
+			actual, err := Render(test.tex, test.display)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := math(test.display, test.tex, test.expected)
+			if actual != expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestRenderSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		tex string
+		msg string
+		pos int
+	}{
+		{`\frac{1}{`, "missing closing brace", 8},
+		{`\frac`, `missing argument for \frac`, 5},
+		{`x^2^3`, "double superscript", 3},
+		{`x_1_2`, "double subscript", 3},
+		{`\foo`, `unknown command \foo`, 0},
+		{`}`, "unexpected '}'", 0},
+		{`\sqrt[3`, "missing closing bracket", 5},
+		{`\left( x`, `\left without \right`, 0},
+		{`\right)`, `unexpected \right`, 0},
+		{`\not x`, `\not must be followed by a relation`, 0},
+		{`\begin{foo}`, "unknown environment 'foo'", 0},
+		{`\begin{matrix} a`, `missing \end{matrix}`, 0},
+		{`\begin{matrix} a \end{cases}`, `\begin{matrix} ended by \end{cases}`, 17},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tex, func(t *testing.T) {
+			_, err := Render(test.tex, false)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected a SyntaxError, got %v", err)
+			}
+			if syntaxErr.Msg != test.msg {
+				t.Errorf("expected message '%s', got '%s'", test.msg, syntaxErr.Msg)
+			}
+			if syntaxErr.Pos != test.pos {
+				t.Errorf("expected position %d, got %d", test.pos, syntaxErr.Pos)
+			}
+		})
+	}
+}
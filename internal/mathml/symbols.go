@@ -0,0 +1,152 @@
+package mathml
+
+// Identifiers which are written as commands, e.g. \alpha:
+var identifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"infty": "∞", "ell": "ℓ", "hbar": "ℏ", "imath": "ı", "jmath": "ȷ",
+	"aleph": "ℵ", "emptyset": "∅", "varnothing": "∅", "partial": "∂",
+	"nabla": "∇", "Re": "ℜ", "Im": "ℑ", "wp": "℘",
+}
+
+// Upper case Greek letters are upright by convention:
+var uprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+}
+
+// Operators, relations and arrows:
+var operators = map[string]string{
+	"cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "odot": "⊙", "wedge": "∧", "land": "∧", "vee": "∨",
+	"lor": "∨", "neg": "¬", "lnot": "¬", "setminus": "∖",
+	"cup": "∪", "cap": "∩", "sqcup": "⊔", "sqcap": "⊓",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"ll": "≪", "gg": "≫", "approx": "≈", "equiv": "≡", "cong": "≅",
+	"sim": "∼", "simeq": "≃", "propto": "∝", "doteq": "≐",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "mid": "∣", "nmid": "∤",
+	"parallel": "∥", "perp": "⊥", "models": "⊨", "vdash": "⊢",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"mapsto": "↦", "longrightarrow": "⟶", "longleftarrow": "⟵",
+	"uparrow": "↑", "downarrow": "↓",
+	"forall": "∀", "exists": "∃", "nexists": "∄",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"colon": ":", "prime": "′", "angle": "∠", "triangle": "△",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "Vert": "‖",
+}
+
+// Large operators take their scripts as limits in display mode,
+// except for integrals:
+var largeOperators = map[string]struct {
+	symbol string
+	limits bool
+}{
+	"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true},
+	"bigcup": {"⋃", true}, "bigcap": {"⋂", true}, "bigoplus": {"⨁", true},
+	"bigotimes": {"⨂", true}, "bigvee": {"⋁", true}, "bigwedge": {"⋀", true},
+	"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false},
+	"oint": {"∮", false},
+}
+
+// Named functions, the ones with limits take their scripts
+// as limits in display mode:
+var functions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false,
+	"csc": false, "arcsin": false, "arccos": false, "arctan": false,
+	"sinh": false, "cosh": false, "tanh": false, "log": false, "ln": false,
+	"lg": false, "exp": false, "deg": false, "dim": false, "ker": false,
+	"hom": false, "arg": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+}
+
+// Characters which can be written as escaped commands, e.g. \{:
+var escapedCharacters = map[string]string{
+	"{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "#": "#", "&": "&",
+	"_": "_",
+}
+
+// Horizontal spaces in em:
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+// Accents over and under their argument:
+var accents = map[string]struct {
+	symbol string
+	under  bool
+}{
+	"hat": {"^", false}, "widehat": {"^", false}, "bar": {"¯", false},
+	"overline": {"¯", false}, "vec": {"→", false}, "tilde": {"~", false},
+	"widetilde": {"~", false}, "dot": {"˙", false}, "ddot": {"¨", false},
+	"overrightarrow": {"→", false}, "underline": {"_", true},
+}
+
+// Delimiters after \left and \right:
+var delimiters = map[string]string{
+	"(": "(", ")": ")", "[": "[", "]": "]", "|": "|", "/": "/",
+	`\{`: "{", `\}`: "}", `\|`: "‖", `\langle`: "⟨", `\rangle`: "⟩",
+	`\lfloor`: "⌊", `\rfloor`: "⌋", `\lceil`: "⌈", `\rceil`: "⌉",
+	`\vert`: "|", `\Vert`: "‖", ".": "",
+}
+
+// Fences of matrix environments:
+var matrixFences = map[string][2]string{
+	"matrix":  {"", ""},
+	"pmatrix": {"(", ")"},
+	"bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"},
+	"vmatrix": {"|", "|"},
+	"Vmatrix": {"‖", "‖"},
+}
+
+// alphabet maps the letters and digits of a font command
+// onto the Mathematical Alphanumeric Symbols block.
+type alphabet struct {
+	upper      rune
+	lower      rune
+	digits     rune
+	exceptions map[rune]rune
+}
+
+var alphabets = map[string]alphabet{
+	"mathbf": {upper: 0x1D400, lower: 0x1D41A, digits: 0x1D7CE},
+	"mathbb": {upper: 0x1D538, lower: 0x1D552, digits: 0x1D7D8, exceptions: map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"mathcal": {upper: 0x1D49C, lower: 0x1D4B6, exceptions: map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"mathfrak": {upper: 0x1D504, lower: 0x1D51E, exceptions: map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+	"mathsf": {upper: 0x1D5A0, lower: 0x1D5BA, digits: 0x1D7E2},
+	"mathtt": {upper: 0x1D670, lower: 0x1D68A, digits: 0x1D7F6},
+}
+
+func (a alphabet) mapRune(r rune) rune {
+	if mapped, ok := a.exceptions[r]; ok {
+		return mapped
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return a.upper + r - 'A'
+	case r >= 'a' && r <= 'z':
+		return a.lower + r - 'a'
+	case r >= '0' && r <= '9' && a.digits != 0:
+		return a.digits + r - '0'
+	}
+	return r
+}
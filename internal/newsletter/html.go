@@ -0,0 +1,230 @@
+package newsletter
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Email clients ignore stylesheets, therefore all styles
+// must be set on the elements themselves.
+var inlineStyles = map[atom.Atom]string{
+	atom.H1:         "font-family:Georgia,serif;font-size:28px;line-height:1.3;color:#222222;",
+	atom.H2:         "font-family:Georgia,serif;font-size:24px;line-height:1.3;color:#222222;",
+	atom.H3:         "font-family:Georgia,serif;font-size:20px;line-height:1.3;color:#222222;",
+	atom.H4:         "font-family:Georgia,serif;font-size:18px;line-height:1.3;color:#222222;",
+	atom.P:          "font-size:16px;line-height:1.6;color:#222222;",
+	atom.Li:         "font-size:16px;line-height:1.6;color:#222222;",
+	atom.A:          "color:#d6336c;",
+	atom.Blockquote: "margin:0;padding:0 16px;border-left:4px solid #dddddd;color:#555555;",
+	atom.Pre:        "padding:12px;overflow-x:auto;border-radius:4px;font-size:14px;line-height:1.4;",
+	atom.Code:       "font-family:Menlo,Consolas,monospace;font-size:14px;",
+	atom.Img:        "max-width:100%;height:auto;",
+	atom.Table:      "border-collapse:collapse;",
+	atom.Th:         "padding:4px 8px;border:1px solid #dddddd;",
+	atom.Td:         "padding:4px 8px;border:1px solid #dddddd;",
+}
+
+// Elements which email clients don't execute or render are dropped
+// including their content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true,
+	atom.Style:  true,
+	atom.Iframe: true,
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func absoluteURL(value string, permalink *url.URL) string {
+	ref, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	return permalink.ResolveReference(ref).String()
+}
+
+// EmailHTML prepares the HTML of a blog post for an email.
+// It inlines styles and turns relative links into absolute URLs.
+func EmailHTML(content string, permalink string) (string, error) {
+	base, err := url.Parse(permalink)
+	if err != nil {
+		return "", fmt.Errorf("invalid permalink '%s': %w", permalink, err)
+	}
+
+	var sb strings.Builder
+	dropped := 0
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return sb.String(), nil
+			}
+			return "", fmt.Errorf("error parsing HTML: %w", z.Err())
+		}
+
+		token := z.Token()
+		if droppedElements[token.DataAtom] {
+			switch tt {
+			case html.StartTagToken:
+				dropped++
+			case html.EndTagToken:
+				dropped--
+			}
+			continue
+		}
+		if dropped > 0 {
+			continue
+		}
+
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			style := inlineStyles[token.DataAtom]
+			attrs := make([]html.Attribute, 0, len(token.Attr)+1)
+			for _, attr := range token.Attr {
+				switch attr.Key {
+				case "style":
+					// Inline styles of highlighted code come last and take precedence:
+					style += attr.Val
+					continue
+				case "href", "src":
+					attr.Val = absoluteURL(attr.Val, base)
+				case "class", "id":
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			if len(style) > 0 {
+				attrs = append(attrs, html.Attribute{Key: "style", Val: style})
+			}
+			token.Attr = attrs
+		}
+		sb.WriteString(token.String())
+	}
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Ul, atom.Ol, atom.Li, atom.Pre, atom.Blockquote, atom.Table, atom.Tr,
+		atom.Figure, atom.Figcaption, atom.Hr:
+		return true
+	default:
+		return false
+	}
+}
+
+// PlainText converts HTML into a readable plain text alternative.
+// Links are followed by their absolute URL in brackets.
+func PlainText(content string, permalink string) (string, error) {
+	base, err := url.Parse(permalink)
+	if err != nil {
+		return "", fmt.Errorf("invalid permalink '%s': %w", permalink, err)
+	}
+
+	var sb strings.Builder
+	inPre := false
+	dropped := 0
+	hrefs := []string{}
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return "", fmt.Errorf("error parsing HTML: %w", z.Err())
+			}
+			break
+		}
+		token := z.Token()
+		if droppedElements[token.DataAtom] {
+			switch tt {
+			case html.StartTagToken:
+				dropped++
+			case html.EndTagToken:
+				dropped--
+			}
+			continue
+		}
+		if dropped > 0 {
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			if inPre {
+				sb.WriteString(token.Data)
+				continue
+			}
+			text := strings.Join(strings.Fields(token.Data), " ")
+			if len(text) == 0 {
+				if len(token.Data) > 0 && !strings.HasSuffix(sb.String(), "\n") {
+					sb.WriteString(" ")
+				}
+				continue
+			}
+			if token.Data[0] == ' ' || token.Data[0] == '\n' {
+				text = " " + text
+			}
+			if last := token.Data[len(token.Data)-1]; last == ' ' || last == '\n' {
+				text += " "
+			}
+			sb.WriteString(text)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.DataAtom {
+			case atom.Pre:
+				inPre = true
+			case atom.Br:
+				sb.WriteString("\n")
+			case atom.Li:
+				sb.WriteString("\n- ")
+				continue
+			case atom.A:
+				href := ""
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+				hrefs = append(hrefs, href)
+			case atom.Img:
+				for _, attr := range token.Attr {
+					if attr.Key == "alt" && len(attr.Val) > 0 {
+						sb.WriteString("[" + attr.Val + "]")
+					}
+				}
+			}
+			if isBlock(token.DataAtom) {
+				sb.WriteString("\n\n")
+			}
+
+		case html.EndTagToken:
+			switch token.DataAtom {
+			case atom.Pre:
+				inPre = false
+			case atom.A:
+				if len(hrefs) > 0 {
+					href := hrefs[len(hrefs)-1]
+					hrefs = hrefs[:len(hrefs)-1]
+					if len(href) > 0 && !strings.HasPrefix(href, "#") {
+						sb.WriteString(" (" + absoluteURL(href, base) + ")")
+					}
+				}
+			}
+			if isBlock(token.DataAtom) && token.DataAtom != atom.Li {
+				sb.WriteString("\n\n")
+			}
+		}
+	}
+
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text) + "\n", nil
+}
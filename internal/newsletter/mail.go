@@ -0,0 +1,241 @@
+package newsletter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Message is a multipart email with an HTML and a plain text body.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+
+	// Headers are added to the message as they are, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer delivers emails. The SMTP mailer is used in production and can
+// be pointed at a local mock SMTP server during development.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+func writePart(w *multipart.Writer, contentType string, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	domain := "localhost"
+	if _, after, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimSuffix(after, ">")
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
+
+func encodeQuotedPrintable(body string) ([]byte, error) {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Bytes encodes the message in the MIME format. Messages without
+// an HTML body are sent as plain text only.
+func (m *Message) Bytes() ([]byte, error) {
+	contentType := "text/plain; charset=utf-8"
+	var body []byte
+
+	if len(m.HTML) > 0 {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		if err := writePart(w, "text/plain; charset=utf-8", m.Text); err != nil {
+			return nil, fmt.Errorf("error writing plain text part: %w", err)
+		}
+		if err := writePart(w, "text/html; charset=utf-8", m.HTML); err != nil {
+			return nil, fmt.Errorf("error writing HTML part: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("error closing multipart message: %w", err)
+		}
+		contentType = fmt.Sprintf("multipart/alternative; boundary=%q", w.Boundary())
+		body = buf.Bytes()
+	} else {
+		text, err := encodeQuotedPrintable(m.Text)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding plain text body: %w", err)
+		}
+		body = text
+	}
+
+	var msg bytes.Buffer
+	header := func(key string, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(m.From))
+	header("MIME-Version", "1.0")
+	for _, key := range slices.Sorted(maps.Keys(m.Headers)) {
+		header(key, m.Headers[key])
+	}
+	header("Content-Type", contentType)
+	if len(m.HTML) == 0 {
+		header("Content-Transfer-Encoding", "quoted-printable")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body)
+
+	return msg.Bytes(), nil
+}
+
+// smtpTimeout limits how long sending a single email may take
+// when the context doesn't have an earlier deadline.
+const smtpTimeout = time.Minute
+
+// SMTPMailer delivers emails through an SMTP server. It upgrades the
+// connection with STARTTLS when the server supports it and only
+// authenticates when a username has been configured.
+type SMTPMailer struct {
+	addr     string
+	username string
+	password string
+}
+
+func NewSMTPMailer(addr string, username string, password string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     addr,
+		username: username,
+		password: password,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender '%s': %w", msg.From, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient '%s': %w", msg.To, err)
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	err = m.send(ctx, from.Address, to.Address, data)
+	if err != nil {
+		return fmt.Errorf("error sending email to '%s': %w", to.Address, err)
+	}
+	return nil
+}
+
+// send does the same as smtp.SendMail, but the connection
+// gets closed when the context is done or the timeout is up.
+func (m *SMTPMailer) send(ctx context.Context, from string, to string, data []byte) error {
+	host, _, err := net.SplitHostPort(m.addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address '%s': %w", m.addr, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if len(m.username) > 0 {
+		err = c.Auth(smtp.PlainAuth("", m.username, m.password, host))
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,197 @@
+package newsletter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockSMTPServer accepts emails without any extensions and records them.
+type mockSMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	from     []string
+	rcpt     []string
+	messages []string
+}
+
+func newMockSMTPServer(t *testing.T, handle func(s *mockSMTPServer, conn net.Conn)) *mockSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mockSMTPServer{listener: listener}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				handle(s, conn)
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *mockSMTPServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// serve speaks just enough SMTP to receive an email.
+func (s *mockSMTPServer) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP mock")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = append(s.from, arg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, arg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newMockSMTPServer(t, (*mockSMTPServer).serve)
+
+	mailer := NewSMTPMailer(server.addr(), "", "")
+	err := mailer.Send(context.Background(), &Message{
+		From:    "Dusted Codes <newsletter@dusted.codes>",
+		To:      "reader@example.org",
+		Subject: "New blog posts",
+		HTML:    "<p>Hello</p>",
+		Text:    "Hello",
+		Headers: map[string]string{"List-Unsubscribe": "<https://dusted.codes/unsubscribe>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.from) != 1 || server.from[0] != "FROM:<newsletter@dusted.codes>" {
+		t.Errorf("unexpected MAIL commands %v", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "TO:<reader@example.org>" {
+		t.Errorf("unexpected RCPT commands %v", server.rcpt)
+	}
+	if len(server.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(server.messages))
+	}
+	for _, expected := range []string{
+		"Subject: New blog posts\n",
+		"List-Unsubscribe: <https://dusted.codes/unsubscribe>\n",
+		"Content-Type: text/html; charset=utf-8\n",
+		"<p>Hello</p>",
+	} {
+		if !strings.Contains(server.messages[0], expected) {
+			t.Errorf("expected the message to contain %q, got:\n%s", expected, server.messages[0])
+		}
+	}
+}
+
+func TestSMTPMailerSendFailsWithRejectedRecipient(t *testing.T) {
+	server := newMockSMTPServer(t, func(_ *mockSMTPServer, conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP mock")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "RCPT") {
+				_ = tp.PrintfLine("550 No such user")
+				continue
+			}
+			_ = tp.PrintfLine("250 OK")
+		}
+	})
+
+	err := NewSMTPMailer(server.addr(), "", "").Send(context.Background(), &Message{
+		From: "newsletter@dusted.codes",
+		To:   "nobody@example.org",
+		Text: "Hello",
+	})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("expected the rejected recipient to fail, got %v", err)
+	}
+}
+
+func TestSMTPMailerSendStopsWhenTheContextIsDone(t *testing.T) {
+	// The server never greets the client:
+	server := newMockSMTPServer(t, func(_ *mockSMTPServer, conn net.Conn) {
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := NewSMTPMailer(server.addr(), "", "").Send(ctx, &Message{
+		From: "newsletter@dusted.codes",
+		To:   "reader@example.org",
+		Text: "Hello",
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected Send to stop after the context is done, took %s", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = NewSMTPMailer(server.addr(), "", "").Send(cancelled, &Message{
+		From: "newsletter@dusted.codes",
+		To:   "reader@example.org",
+		Text: "Hello",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
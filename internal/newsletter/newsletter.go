@@ -0,0 +1,267 @@
+package newsletter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+const (
+	DefaultTemplatePath = "dist/templates/emails"
+)
+
+// DigestPost is a blog post as it appears in a digest email.
+type DigestPost struct {
+	Title       string
+	Permalink   string
+	PublishedOn string
+	HTML        htmltemplate.HTML
+	Text        string
+}
+
+// Digest is the model of the digest email templates.
+type Digest struct {
+	BaseURL        string
+	UnsubscribeURL string
+	Posts          []DigestPost
+}
+
+// Confirmation is the model of the double opt-in email template.
+type Confirmation struct {
+	BaseURL    string
+	ConfirmURL string
+}
+
+// Newsletter manages subscriptions and sends emails to subscribers.
+type Newsletter struct {
+	store   *Store
+	mailer  Mailer
+	from    string
+	baseURL string
+
+	digestHTML   *htmltemplate.Template
+	digestText   *texttemplate.Template
+	confirmation *texttemplate.Template
+}
+
+func New(
+	store *Store,
+	mailer Mailer,
+	templatePath string,
+	from string,
+	baseURL string,
+) (*Newsletter, error) {
+	digestHTML, err := htmltemplate.ParseFiles(filepath.Join(templatePath, "digest.html"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing digest HTML template: %w", err)
+	}
+	digestText, err := texttemplate.ParseFiles(filepath.Join(templatePath, "digest.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing digest text template: %w", err)
+	}
+	confirmation, err := texttemplate.ParseFiles(filepath.Join(templatePath, "confirm.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing confirmation template: %w", err)
+	}
+	return &Newsletter{
+		store:        store,
+		mailer:       mailer,
+		from:         from,
+		baseURL:      baseURL,
+		digestHTML:   digestHTML,
+		digestText:   digestText,
+		confirmation: confirmation,
+	}, nil
+}
+
+func (n *Newsletter) ConfirmURL(token string) string {
+	return n.baseURL + "/newsletter/confirm?token=" + url.QueryEscape(token)
+}
+
+func (n *Newsletter) UnsubscribeURL(token string) string {
+	return n.baseURL + "/newsletter/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// Subscribe stores a pending subscription and emails
+// the confirmation link to the subscriber.
+func (n *Newsletter) Subscribe(ctx context.Context, email string) error {
+	subscriber, err := n.store.Subscribe(email, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if subscriber.Status == StatusConfirmed {
+		return nil
+	}
+
+	var text bytes.Buffer
+	err = n.confirmation.Execute(&text, &Confirmation{
+		BaseURL:    n.baseURL,
+		ConfirmURL: n.ConfirmURL(subscriber.ConfirmToken),
+	})
+	if err != nil {
+		return fmt.Errorf("error rendering confirmation email: %w", err)
+	}
+
+	return n.mailer.Send(ctx, &Message{
+		From:    n.from,
+		To:      subscriber.Email,
+		Subject: "Please confirm your subscription",
+		Text:    text.String(),
+	})
+}
+
+func (n *Newsletter) Confirm(token string) (*Subscriber, error) {
+	return n.store.Confirm(token, time.Now().UTC())
+}
+
+func (n *Newsletter) Unsubscribe(token string) (*Subscriber, error) {
+	return n.store.Unsubscribe(token)
+}
+
+// unsentPosts returns all blog posts which have been published since the
+// store was created and haven't been included in a digest yet.
+func (n *Newsletter) unsentPosts(blogPosts []*blog.Post) ([]*blog.Post, error) {
+	createdAt, err := n.store.CreatedAt()
+	if err != nil {
+		return nil, err
+	}
+	since := createdAt.Truncate(24 * time.Hour)
+
+	unsent := []*blog.Post{}
+	for _, blogPost := range blogPosts {
+		if blogPost.Retired || blogPost.PublishDate.Before(since) {
+			continue
+		}
+		sent, err := n.store.Sent(blogPost.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !sent {
+			unsent = append(unsent, blogPost)
+		}
+	}
+	sort.Slice(unsent, func(i, j int) bool {
+		return unsent[i].PublishDate.Before(unsent[j].PublishDate)
+	})
+	return unsent, nil
+}
+
+func (n *Newsletter) digestPosts(blogPosts []*blog.Post) ([]DigestPost, error) {
+	posts := []DigestPost{}
+	for _, blogPost := range blogPosts {
+		permalink := n.baseURL + "/" + blogPost.ID
+		content, err := EmailHTML(string(blog.StripHeadingAnchors(blog.InlineSyntaxStyles(blogPost.HTML))), permalink)
+		if err != nil {
+			return nil, fmt.Errorf("error preparing blog post '%s' for email: %w", blogPost.ID, err)
+		}
+		text, err := PlainText(string(blogPost.HTML), permalink)
+		if err != nil {
+			return nil, fmt.Errorf("error converting blog post '%s' to plain text: %w", blogPost.ID, err)
+		}
+		posts = append(posts, DigestPost{
+			Title:       blogPost.Title,
+			Permalink:   permalink,
+			PublishedOn: blogPost.PublishDate.Format("02 Jan 2006"),
+			HTML:        htmltemplate.HTML(content), //nolint: gosec // blog posts are trusted
+			Text:        text,
+		})
+	}
+	return posts, nil
+}
+
+func (n *Newsletter) subject(posts []DigestPost) string {
+	if len(posts) == 1 {
+		return posts[0].Title
+	}
+	return fmt.Sprintf("%s and %d more new posts", posts[0].Title, len(posts)-1)
+}
+
+// SendDigest emails all blog posts which have been published since the
+// last digest to every confirmed subscriber. Blog posts are marked as sent
+// even when the delivery to some subscribers failed, because repeating the
+// digest would send it twice to everyone else.
+func (n *Newsletter) SendDigest(
+	ctx context.Context,
+	logger *slog.Logger,
+	blogPosts []*blog.Post,
+) error {
+	unsent, err := n.unsentPosts(blogPosts)
+	if err != nil {
+		return err
+	}
+	if len(unsent) == 0 {
+		logger.Info("No new blog posts since the last digest.")
+		return nil
+	}
+
+	posts, err := n.digestPosts(unsent)
+	if err != nil {
+		return err
+	}
+
+	subscribers, err := n.store.Confirmed()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, subscriber := range subscribers {
+		digest := &Digest{
+			BaseURL:        n.baseURL,
+			UnsubscribeURL: n.UnsubscribeURL(subscriber.UnsubscribeToken),
+			Posts:          posts,
+		}
+
+		var html, text bytes.Buffer
+		if err := n.digestHTML.Execute(&html, digest); err != nil {
+			return fmt.Errorf("error rendering digest HTML: %w", err)
+		}
+		if err := n.digestText.Execute(&text, digest); err != nil {
+			return fmt.Errorf("error rendering digest text: %w", err)
+		}
+
+		err := n.mailer.Send(ctx, &Message{
+			From:    n.from,
+			To:      subscriber.Email,
+			Subject: n.subject(posts),
+			HTML:    html.String(),
+			Text:    text.String(),
+			Headers: map[string]string{
+				"List-Unsubscribe":      "<" + digest.UnsubscribeURL + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+		})
+		if err != nil {
+			failed++
+			logger.Error("Failed to send digest.",
+				"error", err,
+				"subscribedAt", subscriber.SubscribedAt)
+		}
+	}
+
+	postIDs := []string{}
+	for _, blogPost := range unsent {
+		postIDs = append(postIDs, blogPost.ID)
+	}
+	err = n.store.MarkSent(postIDs, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send the digest to %d of %d subscribers", failed, len(subscribers))
+	}
+
+	logger.Info("Finished sending digest.",
+		"blogPosts", len(posts),
+		"subscribers", len(subscribers))
+	return nil
+}
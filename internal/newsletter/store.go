@@ -0,0 +1,357 @@
+package newsletter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultStorePath = "data/newsletter.db"
+
+	maxEmailLen = 254
+	openTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid newsletter token")
+
+	subscribersBucket = []byte("subscribers")
+	tokensBucket      = []byte("tokens")
+	sentBucket        = []byte("sent")
+	metaBucket        = []byte("meta")
+	createdAtKey      = []byte("createdAt")
+	lastSentAtKey     = []byte("lastSentAt")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+)
+
+type Subscriber struct {
+	Email            string
+	Status           Status
+	ConfirmToken     string
+	UnsubscribeToken string
+	SubscribedAt     time.Time
+	ConfirmedAt      time.Time
+}
+
+// ParseEmail validates and normalises a user submitted email address.
+func ParseEmail(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return "", errors.New("please enter your email address")
+	}
+	if len(value) > maxEmailLen {
+		return "", fmt.Errorf("email address must not be longer than %d characters", maxEmailLen)
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value || !strings.Contains(value, ".") {
+		return "", errors.New("please enter a valid email address")
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Store persists newsletter subscribers in an embedded bbolt database.
+// Unlike the other stores it only opens the database for the duration
+// of a single operation, which allows the send-digest command to run
+// while the web server is accepting new subscriptions.
+type Store struct {
+	path string
+}
+
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for newsletter store: %w", err)
+	}
+
+	s := &Store{path: path}
+	err = s.update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{subscribersBucket, tokensBucket, sentBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(metaBucket)
+		if meta.Get(createdAtKey) != nil {
+			return nil
+		}
+		createdAt, err := time.Now().UTC().MarshalText()
+		if err != nil {
+			return err
+		}
+		return meta.Put(createdAtKey, createdAt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error initialising newsletter store: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{
+		Timeout:  openTimeout,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening newsletter store '%s': %w", s.path, err)
+	}
+	return db, nil
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.Update(fn)
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.View(fn)
+}
+
+func getSubscriber(tx *bolt.Tx, email string) (*Subscriber, error) {
+	value := tx.Bucket(subscribersBucket).Get([]byte(email))
+	if value == nil {
+		return nil, nil
+	}
+	subscriber := &Subscriber{}
+	if err := json.Unmarshal(value, subscriber); err != nil {
+		return nil, fmt.Errorf("error deserialising subscriber: %w", err)
+	}
+	return subscriber, nil
+}
+
+func putSubscriber(tx *bolt.Tx, subscriber *Subscriber) error {
+	value, err := json.Marshal(subscriber)
+	if err != nil {
+		return fmt.Errorf("error serialising subscriber: %w", err)
+	}
+	return tx.Bucket(subscribersBucket).Put([]byte(subscriber.Email), value)
+}
+
+func subscriberByToken(tx *bolt.Tx, token string) (*Subscriber, error) {
+	if len(token) == 0 {
+		return nil, ErrInvalidToken
+	}
+	email := tx.Bucket(tokensBucket).Get([]byte(token))
+	if email == nil {
+		return nil, ErrInvalidToken
+	}
+	subscriber, err := getSubscriber(tx, string(email))
+	if err != nil {
+		return nil, err
+	}
+	if subscriber == nil {
+		return nil, ErrInvalidToken
+	}
+	return subscriber, nil
+}
+
+// Subscribe registers a pending subscription and returns the subscriber,
+// whose confirm token must be sent to the email address. Subscribing an
+// address again issues a new confirm token, but doesn't revert an
+// already confirmed subscription.
+func (s *Store) Subscribe(email string, now time.Time) (*Subscriber, error) {
+	confirmToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	unsubscribeToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriber *Subscriber
+	err = s.update(func(tx *bolt.Tx) error {
+		existing, err := getSubscriber(tx, email)
+		if err != nil {
+			return err
+		}
+		tokens := tx.Bucket(tokensBucket)
+		if existing != nil {
+			if err := tokens.Delete([]byte(existing.ConfirmToken)); err != nil {
+				return err
+			}
+			subscriber = existing
+			subscriber.ConfirmToken = confirmToken
+		} else {
+			subscriber = &Subscriber{
+				Email:            email,
+				Status:           StatusPending,
+				ConfirmToken:     confirmToken,
+				UnsubscribeToken: unsubscribeToken,
+				SubscribedAt:     now,
+			}
+			if err := tokens.Put([]byte(unsubscribeToken), []byte(email)); err != nil {
+				return err
+			}
+		}
+		if err := tokens.Put([]byte(confirmToken), []byte(email)); err != nil {
+			return err
+		}
+		return putSubscriber(tx, subscriber)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error storing subscriber: %w", err)
+	}
+	return subscriber, nil
+}
+
+// Confirm completes the double opt-in of a subscription.
+func (s *Store) Confirm(token string, now time.Time) (*Subscriber, error) {
+	var subscriber *Subscriber
+	err := s.update(func(tx *bolt.Tx) error {
+		var err error
+		subscriber, err = subscriberByToken(tx, token)
+		if err != nil {
+			return err
+		}
+		if subscriber.ConfirmToken != token {
+			return ErrInvalidToken
+		}
+		if subscriber.Status != StatusConfirmed {
+			subscriber.Status = StatusConfirmed
+			subscriber.ConfirmedAt = now
+		}
+		return putSubscriber(tx, subscriber)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error confirming subscriber: %w", err)
+	}
+	return subscriber, nil
+}
+
+// Unsubscribe deletes a subscriber and all its tokens.
+func (s *Store) Unsubscribe(token string) (*Subscriber, error) {
+	var subscriber *Subscriber
+	err := s.update(func(tx *bolt.Tx) error {
+		var err error
+		subscriber, err = subscriberByToken(tx, token)
+		if err != nil {
+			return err
+		}
+		if subscriber.UnsubscribeToken != token {
+			return ErrInvalidToken
+		}
+		tokens := tx.Bucket(tokensBucket)
+		if err := tokens.Delete([]byte(subscriber.ConfirmToken)); err != nil {
+			return err
+		}
+		if err := tokens.Delete([]byte(subscriber.UnsubscribeToken)); err != nil {
+			return err
+		}
+		return tx.Bucket(subscribersBucket).Delete([]byte(subscriber.Email))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error removing subscriber: %w", err)
+	}
+	return subscriber, nil
+}
+
+// Confirmed returns all subscribers which completed the double opt-in.
+func (s *Store) Confirmed() ([]*Subscriber, error) {
+	subscribers := []*Subscriber{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(subscribersBucket).ForEach(func(_, value []byte) error {
+			subscriber := &Subscriber{}
+			if err := json.Unmarshal(value, subscriber); err != nil {
+				return fmt.Errorf("error deserialising subscriber: %w", err)
+			}
+			if subscriber.Status == StatusConfirmed {
+				subscribers = append(subscribers, subscriber)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing subscribers: %w", err)
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].ConfirmedAt.Before(subscribers[j].ConfirmedAt)
+	})
+	return subscribers, nil
+}
+
+// CreatedAt returns when the store was initialised. Digests only
+// include blog posts from that day onwards, so that the first digest
+// doesn't contain every blog post which has ever been published.
+func (s *Store) CreatedAt() (time.Time, error) {
+	var createdAt time.Time
+	err := s.view(func(tx *bolt.Tx) error {
+		return createdAt.UnmarshalText(tx.Bucket(metaBucket).Get(createdAtKey))
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading creation date of newsletter store: %w", err)
+	}
+	return createdAt, nil
+}
+
+// Sent reports whether a blog post has been included in a digest before.
+func (s *Store) Sent(postID string) (bool, error) {
+	found := false
+	err := s.view(func(tx *bolt.Tx) error {
+		found = tx.Bucket(sentBucket).Get([]byte(postID)) != nil
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error reading sent blog posts: %w", err)
+	}
+	return found, nil
+}
+
+// MarkSent records the blog posts of a digest which has been sent.
+func (s *Store) MarkSent(postIDs []string, sentAt time.Time) error {
+	value, err := sentAt.UTC().MarshalText()
+	if err != nil {
+		return fmt.Errorf("error serialising digest date: %w", err)
+	}
+	err = s.update(func(tx *bolt.Tx) error {
+		sent := tx.Bucket(sentBucket)
+		for _, postID := range postIDs {
+			if err := sent.Put([]byte(postID), value); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(lastSentAtKey, value)
+	})
+	if err != nil {
+		return fmt.Errorf("error marking blog posts as sent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ogimage
+
+import (
+	"sync"
+
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+// Cache keeps the rendered images of blog posts in memory.
+// Images are cached by the hash code of a blog post, which
+// changes when its title, publish date or tags change.
+type Cache struct {
+	renderer *Renderer
+	images   sync.Map
+}
+
+func NewCache(renderer *Renderer) *Cache {
+	return &Cache{renderer: renderer}
+}
+
+// PostImage returns the image of a blog post.
+func (c *Cache) PostImage(blogPost *blog.Post) ([]byte, error) {
+	if img, ok := c.images.Load(blogPost.HashCode); ok {
+		return img.([]byte), nil
+	}
+	img, err := c.renderer.Render(Card{
+		Title: blogPost.Title,
+		Date:  blogPost.PublishDate,
+		Tags:  blogPost.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.images.Store(blogPost.HashCode, img)
+	return img, nil
+}
+
+// Assign sets the generated image as the Open Graph image
+// of a blog post which doesn't specify an image of its own.
+func (c *Cache) Assign(blogPost *blog.Post, url string) error {
+	if blogPost.OpenGraphImage.Complete() {
+		return nil
+	}
+	img, err := c.PostImage(blogPost)
+	if err != nil {
+		return err
+	}
+	blogPost.OpenGraphImage = blog.OpenGraphImage{
+		URL:      url,
+		Width:    Width,
+		Height:   Height,
+		Size:     len(img),
+		MimeType: MimeType,
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package ogimage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dustedcodes/blog/internal/blog"
+)
+
+func TestCacheInvalidatesChangedBlogPosts(t *testing.T) {
+	renderer, err := NewRenderer("Dusted Codes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(renderer)
+	blogPost := &blog.Post{
+		ID:          "hello-world",
+		Title:       "Hello World",
+		PublishDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Tags:        []string{"golang"},
+		HashCode:    "v1",
+	}
+
+	original, err := cache.PostImage(blogPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a new hash code the cached image is returned:
+	blogPost.Title = "Goodbye World"
+	cached, err := cache.PostImage(blogPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, cached) {
+		t.Error("expected the cached image for the same hash code")
+	}
+
+	blogPost.HashCode = "v2"
+	changed, err := cache.PostImage(blogPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(original, changed) {
+		t.Error("expected a new image for a new hash code")
+	}
+	expected, err := renderer.Render(Card{Title: "Goodbye World", Date: blogPost.PublishDate, Tags: blogPost.Tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, changed) {
+		t.Error("expected the image of the changed title")
+	}
+}
+
+func TestAssign(t *testing.T) {
+	renderer, err := NewRenderer("Dusted Codes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(renderer)
+
+	blogPost := &blog.Post{ID: "hello-world", Title: "Hello World", HashCode: "v1"}
+	err = cache.Assign(blogPost, "https://dusted.codes/hello-world/og.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blogPost.OpenGraphImage.URL != "https://dusted.codes/hello-world/og.png" {
+		t.Errorf("expected the generated image, got %s", blogPost.OpenGraphImage.URL)
+	}
+	if blogPost.OpenGraphImage.Size == 0 || blogPost.OpenGraphImage.MimeType != MimeType {
+		t.Errorf("expected the size and MIME type of the image, got %+v", blogPost.OpenGraphImage)
+	}
+
+	own := blog.OpenGraphImage{URL: "https://cdn.dusted.codes/own.png", Width: 1200, Height: 630, Size: 1, MimeType: "image/png"}
+	blogPost = &blog.Post{ID: "own-image", Title: "Own image", HashCode: "v1", OpenGraphImage: own}
+	err = cache.Assign(blogPost, "https://dusted.codes/own-image/og.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blogPost.OpenGraphImage != own {
+		t.Errorf("expected the own image to be kept, got %+v", blogPost.OpenGraphImage)
+	}
+}
@@ -0,0 +1,135 @@
+package ogimage
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/image/vector"
+)
+
+// The logo from dist/templates/svgs/logos/logo.svg, which is drawn
+// in a viewBox of 1240x1224 with the transformation
+// translate(0,1224) scale(0.1,-0.1).
+const (
+	logoWidth  = 1240
+	logoHeight = 1224
+	logoPath   = "M7589 8259 c-843 -1640 -1565 -3037 -1602 -3103 -132 -232 -263 -409 -414 -558 -319 -316 -662 -414 -1051 -302 -182 52 -397 174 -533 301 -131 124 -250 314 -308 493 -48 147 -63 255 -58 410 8 200 38 312 134 490 172 320 454 527 826 607 129 28 353 23 478 -10 179 -47 335 -129 451 -236 38 -35 71 -61 74 -58 8 7 1444 2800 1444 2807 0 11 -374 217 -505 277 -487 226 -937 341 -1465 374 -919 57 -2026 -273 -2795 -834 -557 -406 -965 -900 -1290 -1562 -231 -470 -359 -892 -422 -1393 -24 -190 -24 -690 0 -882 91 -725 339 -1393 734 -1975 241 -355 601 -726 963 -992 623 -458 1294 -737 2010 -838 1041 -146 2015 61 2835 601 241 158 417 304 651 538 358 359 642 746 912 1242 135 248 3182 6178 3179 6187 -3 9 -2665 1384 -2698 1394 -11 3 -376 -697 -1550 -2978z"
+)
+
+var pathTokens = regexp.MustCompile(`[MmLlCcZz]|-?(?:[0-9]+\.?[0-9]*|\.[0-9]+)`)
+
+// rasterizeLogo renders the logo as an alpha mask of the given height.
+func rasterizeLogo(height int) (*image.Alpha, error) {
+	scale := float32(height) / logoHeight
+	width := int(logoWidth*scale + 0.5)
+	z := vector.NewRasterizer(width, height)
+
+	// Maps a point of the path onto the mask:
+	point := func(x, y float32) (float32, float32) {
+		return x * 0.1 * scale, (logoHeight - y*0.1) * scale
+	}
+
+	err := tracePath(logoPath, func(op byte, args []float32) {
+		switch op {
+		case 'M':
+			z.MoveTo(point(args[0], args[1]))
+		case 'L':
+			z.LineTo(point(args[0], args[1]))
+		case 'C':
+			x1, y1 := point(args[0], args[1])
+			x2, y2 := point(args[2], args[3])
+			x3, y3 := point(args[4], args[5])
+			z.CubeTo(x1, y1, x2, y2, x3, y3)
+		case 'Z':
+			z.ClosePath()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, nil
+}
+
+// tracePath parses the subset of the SVG path syntax which the logo
+// uses (moveto, lineto, curveto and closepath) and emits each segment
+// with absolute coordinates.
+func tracePath(path string, emit func(op byte, args []float32)) error {
+	tokens := pathTokens.FindAllString(path, -1)
+
+	var cmd byte
+	var x, y, startX, startY float32
+	args := func(i int, n int) ([]float32, error) {
+		if i+n > len(tokens) {
+			return nil, fmt.Errorf("path command '%c' is missing arguments", cmd)
+		}
+		values := make([]float32, n)
+		for j := range n {
+			value, err := strconv.ParseFloat(tokens[i+j], 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path argument '%s': %w", tokens[i+j], err)
+			}
+			values[j] = float32(value)
+		}
+		return values, nil
+	}
+
+	for i := 0; i < len(tokens); {
+		token := tokens[i]
+		switch token {
+		case "M", "m", "L", "l", "C", "c":
+			cmd = token[0]
+			i++
+			continue
+		case "Z", "z":
+			emit('Z', nil)
+			x, y = startX, startY
+			i++
+			continue
+		}
+
+		relative := cmd >= 'a'
+		switch cmd {
+		case 'M', 'm', 'L', 'l':
+			values, err := args(i, 2)
+			if err != nil {
+				return err
+			}
+			i += 2
+			if relative {
+				values[0] += x
+				values[1] += y
+			}
+			x, y = values[0], values[1]
+			if cmd == 'M' || cmd == 'm' {
+				emit('M', values)
+				startX, startY = x, y
+				// Subsequent coordinate pairs are implicit lineto commands:
+				cmd -= 'M' - 'L'
+				continue
+			}
+			emit('L', values)
+		case 'C', 'c':
+			values, err := args(i, 6)
+			if err != nil {
+				return err
+			}
+			i += 6
+			if relative {
+				for j := 0; j < 6; j += 2 {
+					values[j] += x
+					values[j+1] += y
+				}
+			}
+			x, y = values[4], values[5]
+			emit('C', values)
+		default:
+			return fmt.Errorf("unsupported path data at '%s'", token)
+		}
+	}
+	return nil
+}
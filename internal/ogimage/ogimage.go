@@ -0,0 +1,217 @@
+package ogimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	Width    = 1200
+	Height   = 630
+	MimeType = "image/png"
+
+	margin        = 96
+	accentWidth   = 24
+	logoSize      = 72
+	maxTitleLines = 3
+)
+
+// The colours of the site's Tailwind theme:
+var (
+	paper  = color.RGBA{R: 0xf7, G: 0xf6, B: 0xf4, A: 0xff}
+	accent = color.RGBA{R: 0xcc, G: 0x7a, B: 0x3d, A: 0xff}
+	ink5   = color.RGBA{R: 0x71, G: 0x70, B: 0x6d, A: 0xff}
+	ink7   = color.RGBA{R: 0x3f, G: 0x3e, B: 0x3c, A: 0xff}
+	ink8   = color.RGBA{R: 0x27, G: 0x27, B: 0x26, A: 0xff}
+
+	// Title sizes in points from the largest to the smallest,
+	// the first size which fits the title into three lines wins:
+	titleSizes = []float64{72, 66, 60, 54, 48}
+)
+
+// Card describes the content of an Open Graph image.
+type Card struct {
+	Title string
+	Date  time.Time
+	Tags  []string
+}
+
+// Renderer draws Open Graph images for blog posts.
+// It is safe for concurrent use.
+type Renderer struct {
+	siteName string
+	regular  *opentype.Font
+	bold     *opentype.Font
+	logo     *image.Alpha
+}
+
+func NewRenderer(siteName string) (*Renderer, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing regular font: %w", err)
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing bold font: %w", err)
+	}
+	logo, err := rasterizeLogo(logoSize)
+	if err != nil {
+		return nil, fmt.Errorf("error rasterizing logo: %w", err)
+	}
+	return &Renderer{
+		siteName: siteName,
+		regular:  regular,
+		bold:     bold,
+		logo:     logo,
+	}, nil
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating font face: %w", err)
+	}
+	return face, nil
+}
+
+// wrap breaks text into lines which don't exceed the given width.
+// Words which are wider than a line are not broken up.
+func wrap(face font.Face, text string, maxWidth fixed.Int26_6) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if len(line) == 0 {
+			line = word
+			continue
+		}
+		if font.MeasureString(face, line+" "+word) > maxWidth {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncate shortens the text with an ellipsis until it fits.
+func truncate(face font.Face, text string, maxWidth fixed.Int26_6) string {
+	runes := []rune(text)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…") > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x int, y int, text string) fixed.Int26_6 {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+	return d.Dot.X
+}
+
+// layoutTitle picks the largest font size which fits the title into
+// three lines and truncates the title if it doesn't fit at all.
+func (r *Renderer) layoutTitle(title string, maxWidth fixed.Int26_6) (font.Face, []string, error) {
+	var face font.Face
+	var lines []string
+	for _, size := range titleSizes {
+		if face != nil {
+			_ = face.Close()
+		}
+		var err error
+		face, err = newFace(r.bold, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines = wrap(face, title, maxWidth)
+		if len(lines) <= maxTitleLines {
+			return face, lines, nil
+		}
+	}
+	lines = lines[:maxTitleLines]
+	lines[maxTitleLines-1] = truncate(face, lines[maxTitleLines-1], maxWidth)
+	return face, lines, nil
+}
+
+// Render draws the Open Graph image of a card and encodes it as PNG.
+func (r *Renderer) Render(card Card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(paper), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, accentWidth, Height), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	maxWidth := fixed.I(Width - 2*margin)
+
+	// Logo and site name:
+	// ---
+	logoBounds := r.logo.Bounds().Add(image.Pt(margin, margin-24))
+	draw.DrawMask(img, logoBounds, image.NewUniform(accent), image.Point{}, r.logo, image.Point{}, draw.Over)
+	siteFace, err := newFace(r.bold, 36)
+	if err != nil {
+		return nil, err
+	}
+	defer siteFace.Close()
+	drawText(img, siteFace, ink7, logoBounds.Max.X+24, logoBounds.Min.Y+logoSize/2+13, r.siteName)
+
+	// Title:
+	// ---
+	titleFace, lines, err := r.layoutTitle(card.Title, maxWidth)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	lineHeight := titleFace.Metrics().Height.Ceil() * 6 / 5
+	y := 200 + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range lines {
+		drawText(img, titleFace, ink8, margin, y, line)
+		y += lineHeight
+	}
+
+	// Publish date and tags:
+	// ---
+	metaFace, err := newFace(r.regular, 30)
+	if err != nil {
+		return nil, err
+	}
+	defer metaFace.Close()
+	baseline := Height - margin + 24
+	x := drawText(img, metaFace, ink5, margin, baseline, card.Date.Format("02 Jan 2006"))
+	for _, tag := range card.Tags {
+		label := "#" + tag
+		width := font.MeasureString(metaFace, "   "+label)
+		if x+width > fixed.I(Width-margin) {
+			break
+		}
+		x = drawText(img, metaFace, accent, (x + font.MeasureString(metaFace, "   ")).Round(), baseline, label)
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,177 @@
+package redirects
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultRedirectsPath = "dist/redirects.txt"
+
+	wildcard = "*"
+)
+
+type Rule struct {
+	From       string
+	To         string
+	StatusCode int
+}
+
+func (r Rule) isPrefix() bool {
+	return strings.HasSuffix(r.From, wildcard)
+}
+
+func (r Rule) match(path string) (string, bool) {
+	if !r.isPrefix() {
+		if path != r.From {
+			return "", false
+		}
+		return strings.TrimSuffix(r.To, wildcard), true
+	}
+
+	prefix := strings.TrimSuffix(r.From, wildcard)
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+
+	if !strings.HasSuffix(r.To, wildcard) {
+		return r.To, true
+	}
+
+	return strings.TrimSuffix(r.To, wildcard) + strings.TrimPrefix(path, prefix), true
+}
+
+// Table holds all redirect rules.
+// Exact rules take precedence over prefix rules,
+// and prefix rules are evaluated in the order they were added.
+type Table struct {
+	exact    map[string]Rule
+	prefixes []Rule
+}
+
+func NewTable() *Table {
+	return &Table{
+		exact:    map[string]Rule{},
+		prefixes: []Rule{},
+	}
+}
+
+func (t *Table) Add(rule Rule) error {
+	if !strings.HasPrefix(rule.From, "/") {
+		return fmt.Errorf("redirect source must be an absolute path: %s", rule.From)
+	}
+	if len(rule.To) == 0 {
+		return fmt.Errorf("redirect target is missing for: %s", rule.From)
+	}
+	if rule.StatusCode == 0 {
+		rule.StatusCode = http.StatusMovedPermanently
+	}
+	if rule.StatusCode < 300 || rule.StatusCode > 399 {
+		return fmt.Errorf("invalid redirect status code %d for: %s", rule.StatusCode, rule.From)
+	}
+
+	if rule.isPrefix() {
+		t.prefixes = append(t.prefixes, rule)
+		return nil
+	}
+
+	if _, ok := t.exact[rule.From]; ok {
+		return fmt.Errorf("duplicate redirect source: %s", rule.From)
+	}
+	t.exact[rule.From] = rule
+	return nil
+}
+
+// Match returns the redirect target and status code for a given path.
+// A rule which would redirect a path to itself never matches.
+func (t *Table) Match(path string) (string, int, bool) {
+	if rule, ok := t.exact[path]; ok {
+		if target, ok := rule.match(path); ok && target != path {
+			return target, rule.StatusCode, true
+		}
+	}
+
+	for _, rule := range t.prefixes {
+		if target, ok := rule.match(path); ok && target != path {
+			return target, rule.StatusCode, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// CheckShadowing makes sure that no rule redirects one of the given
+// paths, because redirects take precedence over routes and the pages
+// at these paths would become unreachable.
+func (t *Table) CheckShadowing(paths []string) error {
+	for _, path := range paths {
+		if target, _, ok := t.Match(path); ok {
+			return fmt.Errorf("redirect from '%s' to '%s' shadows an existing page", path, target)
+		}
+	}
+	return nil
+}
+
+func parseRule(line string) (Rule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Rule{}, fmt.Errorf("expected '<from> <to> [status code]' but got '%s'", line)
+	}
+
+	rule := Rule{
+		From: fields[0],
+		To:   fields[1],
+	}
+
+	if len(fields) == 3 {
+		statusCode, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return Rule{}, fmt.Errorf("error parsing status code '%s': %w", fields[2], err)
+		}
+		rule.StatusCode = statusCode
+	}
+
+	return rule, nil
+}
+
+func Parse(buffer []byte) (*Table, error) {
+	table := NewTable()
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(bytes.NewReader(buffer))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing redirect rule on line %d: %w", lineNumber, err)
+		}
+
+		err = table.Add(rule)
+		if err != nil {
+			return nil, fmt.Errorf("error adding redirect rule on line %d: %w", lineNumber, err)
+		}
+	}
+
+	return table, nil
+}
+
+func Load(path string) (*Table, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading redirects file '%s': %w", path, err)
+	}
+
+	return Parse(buffer)
+}
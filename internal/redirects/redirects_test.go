@@ -0,0 +1,105 @@
+package redirects
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testRules = `# Comment
+
+/old-post         /new-post
+/moved            https://example.com/moved 302
+/docs/*           /documentation/*
+/archive/*        /blog
+/loop             /loop
+/prefix-loop/*    /prefix-loop/*
+/docs/legacy      /documentation/legacy-docs 308
+`
+
+func TestMatch(t *testing.T) {
+	table, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		target     string
+		statusCode int
+		ok         bool
+	}{
+		{"exact rule", "/old-post", "/new-post", http.StatusMovedPermanently, true},
+		{"exact rule with status code", "/moved", "https://example.com/moved", http.StatusFound, true},
+		{"exact rule doesn't match a prefix", "/old-post/comments", "", 0, false},
+		{"prefix rule with remainder", "/docs/getting-started", "/documentation/getting-started", http.StatusMovedPermanently, true},
+		{"prefix rule without remainder", "/docs/", "/documentation/", http.StatusMovedPermanently, true},
+		{"prefix rule with fixed target", "/archive/2015/01", "/blog", http.StatusMovedPermanently, true},
+		{"exact rule before prefix rule", "/docs/legacy", "/documentation/legacy-docs", http.StatusPermanentRedirect, true},
+		{"prefix doesn't match without slash", "/docs", "", 0, false},
+		{"self redirect", "/loop", "", 0, false},
+		{"prefix self redirect", "/prefix-loop/page", "", 0, false},
+		{"unknown path", "/about", "", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, statusCode, ok := table.Match(test.path)
+			if ok != test.ok || target != test.target || statusCode != test.statusCode {
+				t.Errorf("expected (%s, %d, %t), got (%s, %d, %t)",
+					test.target, test.statusCode, test.ok, target, statusCode, ok)
+			}
+		})
+	}
+}
+
+func TestParseInvalidRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{"missing target", "/old-post", "line 1: expected '<from> <to> [status code]'"},
+		{"too many fields", "/a /b 301 extra", "line 1: expected '<from> <to> [status code]'"},
+		{"relative source", "old-post /new-post", "line 1: redirect source must be an absolute path"},
+		{"status code which isn't a number", "/a /b permanent", "line 1: error parsing status code 'permanent'"},
+		{"status code which isn't a redirect", "/a /b 200", "line 1: invalid redirect status code 200"},
+		{"duplicate source", "# Rules\n/a /b\n/a /c", "line 3: duplicate redirect source: /a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.rules))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing '%s', got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckShadowing(t *testing.T) {
+	table, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = table.CheckShadowing([]string{"/new-post", "/docs", "/loop"})
+	if err != nil {
+		t.Errorf("expected no shadowed paths, got %v", err)
+	}
+
+	tests := []struct {
+		path string
+		err  string
+	}{
+		{"/old-post", "redirect from '/old-post' to '/new-post' shadows an existing page"},
+		{"/docs/intro", "redirect from '/docs/intro' to '/documentation/intro' shadows an existing page"},
+	}
+	for _, test := range tests {
+		err := table.CheckShadowing([]string{"/new-post", test.path})
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error '%s', got %v", test.err, err)
+		}
+	}
+}
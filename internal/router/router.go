@@ -0,0 +1,219 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	staticSegment segmentKind = iota
+	paramSegment
+	catchAllSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+type route struct {
+	pattern  string
+	segments []segment
+	handlers map[string]http.HandlerFunc
+}
+
+// Router dispatches requests to handlers registered for a
+// path pattern and an HTTP method.
+//
+// Patterns are rooted paths where a segment in the form of {name}
+// matches exactly one path segment and {name...} matches the
+// remainder of the path. Matched values are accessible via
+// http.Request.PathValue. When multiple patterns match a path then
+// static segments take precedence over parameters and parameters
+// take precedence over a catch-all.
+type Router struct {
+	routes           []*route
+	NotFound         http.HandlerFunc
+	MethodNotAllowed http.HandlerFunc
+}
+
+func New(
+	notFound http.HandlerFunc,
+	methodNotAllowed http.HandlerFunc,
+) *Router {
+	return &Router{
+		routes:           []*route{},
+		NotFound:         notFound,
+		MethodNotAllowed: methodNotAllowed,
+	}
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("route pattern must be a rooted path: %s", pattern)
+	}
+
+	segments := []segment{}
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			segments = append(segments, segment{kind: staticSegment, value: part})
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(part, "{"), "}")
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("catch-all parameter must be the last segment: %s", pattern)
+			}
+			segments = append(segments, segment{kind: catchAllSegment, value: strings.TrimSuffix(name, "...")})
+			continue
+		}
+
+		segments = append(segments, segment{kind: paramSegment, value: name})
+	}
+
+	return segments, nil
+}
+
+// Handle registers a handler for the given method and pattern.
+// It panics if the pattern is invalid or the combination of
+// method and pattern has already been registered.
+func (rt *Router) Handle(method string, pattern string, handler http.HandlerFunc) {
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	var r *route
+	for _, existing := range rt.routes {
+		if existing.pattern == pattern {
+			r = existing
+			break
+		}
+	}
+
+	if r == nil {
+		r = &route{
+			pattern:  pattern,
+			segments: segments,
+			handlers: map[string]http.HandlerFunc{},
+		}
+		rt.routes = append(rt.routes, r)
+	}
+
+	if _, ok := r.handlers[method]; ok {
+		panic(fmt.Sprintf("route has already been registered: %s %s", method, pattern))
+	}
+	r.handlers[method] = handler
+}
+
+// GET registers a handler for GET and HEAD requests.
+func (rt *Router) GET(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, handler)
+	rt.Handle(http.MethodHead, pattern, handler)
+}
+
+func (rt *Router) POST(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, handler)
+}
+
+func (r *route) match(path string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 1 && len(parts[0]) == 0 {
+		parts = []string{}
+	}
+
+	params := map[string]string{}
+	for i, s := range r.segments {
+		if s.kind == catchAllSegment {
+			params[s.value] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		if s.kind == staticSegment && s.value != parts[i] {
+			return nil, false
+		}
+		if s.kind == paramSegment {
+			if len(parts[i]) == 0 {
+				return nil, false
+			}
+			params[s.value] = parts[i]
+		}
+	}
+
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// moreSpecific reports whether route a should be preferred over route b.
+func moreSpecific(a *route, b *route) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if a.segments[i].kind != b.segments[i].kind {
+			return a.segments[i].kind < b.segments[i].kind
+		}
+	}
+	return len(a.segments) > len(b.segments)
+}
+
+func (r *route) allowedMethods() []string {
+	methods := []string{http.MethodOptions}
+	for method := range r.handlers {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var matched *route
+	var matchedParams map[string]string
+
+	for _, candidate := range rt.routes {
+		params, ok := candidate.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if matched == nil || moreSpecific(candidate, matched) {
+			matched = candidate
+			matchedParams = params
+		}
+	}
+
+	if matched == nil {
+		rt.NotFound(w, r)
+		return
+	}
+
+	for name, value := range matchedParams {
+		r.SetPathValue(name, value)
+	}
+
+	if handler, ok := matched.handlers[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+
+	allow := strings.Join(matched.allowedMethods(), ", ")
+	w.Header().Set("Allow", allow)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	rt.MethodNotAllowed(w, r)
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	rt := New(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		},
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte("method not allowed"))
+		})
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body := name
+			for _, param := range []string{"post", "tag", "comment", "path"} {
+				if value := r.PathValue(param); len(value) > 0 {
+					body += " " + param + "=" + value
+				}
+			}
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	rt.GET("/", handler("index"))
+	rt.GET("/blog", handler("blog"))
+	rt.GET("/tagged/{tag}", handler("tagged"))
+	rt.GET("/{post}", handler("blogPost"))
+	rt.POST("/{post}/comments", handler("postComment"))
+	rt.GET("/{post}/og.png", handler("openGraphImage"))
+	rt.POST("/webmention", handler("webmention"))
+	rt.POST("/admin/comments/{post}/{comment}", handler("moderateComment"))
+	rt.GET("/files/{path...}", handler("files"))
+	rt.GET("/files/readme", handler("readme"))
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{"root", http.MethodGet, "/", http.StatusOK, "index", ""},
+		{"trailing slash", http.MethodGet, "/blog/", http.StatusOK, "blog", ""},
+		{"head is get", http.MethodHead, "/blog", http.StatusOK, "blog", ""},
+		{"static before param", http.MethodGet, "/blog", http.StatusOK, "blog", ""},
+		{"param", http.MethodGet, "/hello-world", http.StatusOK, "blogPost post=hello-world", ""},
+		{"param with static suffix", http.MethodGet, "/hello-world/og.png", http.StatusOK,
+			"openGraphImage post=hello-world", ""},
+		{"static prefix with param", http.MethodGet, "/tagged/go", http.StatusOK, "tagged tag=go", ""},
+		{"missing param", http.MethodGet, "/tagged", http.StatusOK, "blogPost post=tagged", ""},
+		{"two params", http.MethodPost, "/admin/comments/hello-world/42", http.StatusOK,
+			"moderateComment post=hello-world comment=42", ""},
+		{"catch-all", http.MethodGet, "/files/a/b/c.txt", http.StatusOK, "files path=a/b/c.txt", ""},
+		{"static before catch-all", http.MethodGet, "/files/readme", http.StatusOK, "readme", ""},
+		{"empty catch-all", http.MethodGet, "/files", http.StatusOK, "files", ""},
+		{"unknown path", http.MethodGet, "/a/b/c", http.StatusNotFound, "not found", ""},
+		{"too many segments", http.MethodGet, "/tagged/go/more", http.StatusNotFound, "not found", ""},
+		{"unknown path and method", http.MethodPut, "/a/b/c", http.StatusNotFound, "not found", ""},
+		{"method not allowed", http.MethodPost, "/blog", http.StatusMethodNotAllowed,
+			"method not allowed", "GET, HEAD, OPTIONS"},
+		{"method not allowed for param", http.MethodGet, "/hello-world/comments", http.StatusMethodNotAllowed,
+			"method not allowed", "OPTIONS, POST"},
+		{"method not allowed with params", http.MethodDelete, "/hello-world", http.StatusMethodNotAllowed,
+			"method not allowed", "GET, HEAD, OPTIONS"},
+		{"options", http.MethodOptions, "/webmention", http.StatusNoContent, "", "OPTIONS, POST"},
+		{"options of unknown path", http.MethodOptions, "/a/b/c", http.StatusNotFound, "not found", ""},
+	}
+
+	rt := newTestRouter()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+			if body := w.Body.String(); body != test.body {
+				t.Errorf("expected body '%s', got '%s'", test.body, body)
+			}
+			if allow := w.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("expected Allow header '%s', got '%s'", test.allow, allow)
+			}
+		})
+	}
+}
+
+func TestRouterPathValuesOfOtherRoutesAreNotSet(t *testing.T) {
+	rt := newTestRouter()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/tagged/go", nil)
+	rt.ServeHTTP(w, r)
+	if post := r.PathValue("post"); len(post) > 0 {
+		t.Errorf("expected no post path value, got '%s'", post)
+	}
+}
+
+func TestHandlePanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(rt *Router)
+	}{
+		{"relative pattern", func(rt *Router) {
+			rt.GET("blog", func(http.ResponseWriter, *http.Request) {})
+		}},
+		{"catch-all before the last segment", func(rt *Router) {
+			rt.GET("/{path...}/edit", func(http.ResponseWriter, *http.Request) {})
+		}},
+		{"duplicate route", func(rt *Router) {
+			rt.GET("/blog", func(http.ResponseWriter, *http.Request) {})
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			test.register(newTestRouter())
+		})
+	}
+}
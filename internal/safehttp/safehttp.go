@@ -0,0 +1,71 @@
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout    = 5 * time.Second
+	requestTimeout = 15 * time.Second
+	maxRedirects   = 5
+)
+
+var (
+	ErrForbiddenAddress = errors.New("connections to private network addresses are not allowed")
+)
+
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast()
+}
+
+// NewClient creates a HTTP client for requests to URLs which were
+// supplied by third parties, for example when verifying a webmention.
+// Unless allowPrivateNetworks is set the client refuses to connect to
+// loopback, private and link-local addresses, so that it cannot be
+// abused to reach internal services.
+func NewClient(allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+	}
+
+	if !allowPrivateNetworks {
+		dialer.Control = func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return fmt.Errorf("error parsing remote address '%s': %w", address, err)
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublic(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		}
+	}
+
+	//nolint: forcetypeassert // the default transport is always a *http.Transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
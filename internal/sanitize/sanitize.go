@@ -0,0 +1,148 @@
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	allowedTags = map[atom.Atom]bool{
+		atom.A:          true,
+		atom.B:          true,
+		atom.Blockquote: true,
+		atom.Br:         true,
+		atom.Code:       true,
+		atom.Em:         true,
+		atom.I:          true,
+		atom.Li:         true,
+		atom.Ol:         true,
+		atom.P:          true,
+		atom.Pre:        true,
+		atom.S:          true,
+		atom.Strong:     true,
+		atom.U:          true,
+		atom.Ul:         true,
+	}
+
+	// droppedTags are removed together with their contents.
+	droppedTags = map[atom.Atom]bool{
+		atom.Iframe:   true,
+		atom.Noscript: true,
+		atom.Object:   true,
+		atom.Script:   true,
+		atom.Style:    true,
+		atom.Template: true,
+	}
+)
+
+func safeURL(value string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String(), true
+	default:
+		return "", false
+	}
+}
+
+func lastIndex(tags []atom.Atom, tag atom.Atom) int {
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i] == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// HTML reduces third party HTML to a small set of formatting tags.
+// All attributes are removed except for the href of links, which
+// must use a http, https or mailto scheme. Links get marked as
+// user generated content. Tags are always balanced, so that the
+// result cannot break out of the surrounding markup.
+func HTML(input string) string {
+	var sb strings.Builder
+
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(input))
+	dropDepth := 0
+	open := []atom.Atom{}
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == nethtml.ErrorToken {
+			for i := len(open) - 1; i >= 0; i-- {
+				sb.WriteString("</" + open[i].String() + ">")
+			}
+			return sb.String()
+		}
+
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if droppedTags[token.DataAtom] {
+				if tokenType == nethtml.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 || !allowedTags[token.DataAtom] {
+				continue
+			}
+			if token.DataAtom == atom.A {
+				href := ""
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						if value, ok := safeURL(attr.Val); ok {
+							href = value
+						}
+					}
+				}
+				open = append(open, atom.A)
+				if len(href) == 0 {
+					sb.WriteString("<a>")
+					continue
+				}
+				sb.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow ugc noopener">`)
+				continue
+			}
+			if token.DataAtom == atom.Br {
+				sb.WriteString("<br>")
+				continue
+			}
+			open = append(open, token.DataAtom)
+			sb.WriteString("<" + token.DataAtom.String() + ">")
+		case nethtml.EndTagToken:
+			if droppedTags[token.DataAtom] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 || !allowedTags[token.DataAtom] || token.DataAtom == atom.Br {
+				continue
+			}
+			// Close all tags up to the matching start tag,
+			// or ignore the end tag if it was never opened:
+			i := lastIndex(open, token.DataAtom)
+			if i < 0 {
+				continue
+			}
+			for j := len(open) - 1; j >= i; j-- {
+				sb.WriteString("</" + open[j].String() + ">")
+			}
+			open = open[:i]
+		case nethtml.TextToken:
+			if dropDepth > 0 {
+				continue
+			}
+			sb.WriteString(html.EscapeString(token.Data))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "text",
+			input:    "Tom & Jerry",
+			expected: "Tom &amp; Jerry",
+		},
+		{
+			name:     "formatting",
+			input:    "<p><b>bold</b>, <em>em</em> and <code>x &lt; y</code></p>",
+			expected: "<p><b>bold</b>, <em>em</em> and <code>x &lt; y</code></p>",
+		},
+		{
+			name:     "attributes",
+			input:    `<p class="x" onclick="alert(1)" style="color:red">text</p>`,
+			expected: "<p>text</p>",
+		},
+		{
+			name:     "link",
+			input:    `<a href="https://dusted.codes/about" title="About" target="_blank">About</a>`,
+			expected: `<a href="https://dusted.codes/about" rel="nofollow ugc noopener">About</a>`,
+		},
+		{
+			name:     "mailto link",
+			input:    `<a href="mailto:hello@dusted.codes">Email</a>`,
+			expected: `<a href="mailto:hello@dusted.codes" rel="nofollow ugc noopener">Email</a>`,
+		},
+		{
+			name:     "javascript link",
+			input:    `<a href="javascript:alert(1)">click</a>`,
+			expected: "<a>click</a>",
+		},
+		{
+			name:     "upper case javascript link",
+			input:    `<a href=" JaVaScRiPt:alert(1)">click</a>`,
+			expected: "<a>click</a>",
+		},
+		{
+			name:     "data link",
+			input:    `<a href="data:text/html;base64,PHNjcmlwdD4=">click</a>`,
+			expected: "<a>click</a>",
+		},
+		{
+			name:     "escaped href",
+			input:    `<a href="https://example.com/?a=1&b=&quot;2&quot;">x</a>`,
+			expected: `<a href="https://example.com/?a=1&amp;b=&#34;2&#34;" rel="nofollow ugc noopener">x</a>`,
+		},
+		{
+			name:     "script",
+			input:    "before<script>alert(1)</script>after",
+			expected: "beforeafter",
+		},
+		{
+			name:     "nested dropped tags",
+			input:    "a<style><script>x</script>y</style>b",
+			expected: "ab",
+		},
+		{
+			name:     "unknown tags",
+			input:    `<div><img src="x" onerror="alert(1)"><span>text</span></div>`,
+			expected: "text",
+		},
+		{
+			name:     "line breaks",
+			input:    "one<br>two<br/>three</br>",
+			expected: "one<br>two<br>three",
+		},
+		{
+			name:     "unclosed tags",
+			input:    "<blockquote><p><strong>quote",
+			expected: "<blockquote><p><strong>quote</strong></p></blockquote>",
+		},
+		{
+			name:     "misnested tags",
+			input:    "<b><i>text</b> more</i>",
+			expected: "<b><i>text</i></b> more",
+		},
+		{
+			name:     "stray end tags",
+			input:    "</p></blockquote>text</ul>",
+			expected: "text",
+		},
+		{
+			name:     "closing the surrounding markup",
+			input:    "</div></li><script>alert(1)</script>",
+			expected: "",
+		},
+		{
+			name:     "lists",
+			input:    "<ul><li>one<li>two</ul>",
+			expected: "<ul><li>one<li>two</li></li></ul>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := HTML(test.input); actual != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
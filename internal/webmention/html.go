@@ -0,0 +1,123 @@
+package webmention
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type document struct {
+	links    []string
+	endpoint string
+	title    string
+}
+
+func hasRel(attrs []html.Attribute, rel string) bool {
+	for _, attr := range attrs {
+		if attr.Key == "rel" && containsField(attr.Val, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsField(value string, field string) bool {
+	for _, f := range strings.Fields(value) {
+		if strings.EqualFold(f, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(attrs []html.Attribute, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// parseDocument extracts all link targets, the first webmention
+// endpoint and the title of an HTML document.
+func parseDocument(r io.Reader) *document {
+	doc := &document{}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return doc
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Title:
+				inTitle = len(doc.title) == 0
+			case atom.A, atom.Link:
+				href, ok := attr(token.Attr, "href")
+				if !ok {
+					continue
+				}
+				if len(doc.endpoint) == 0 && hasRel(token.Attr, "webmention") {
+					// An empty href is a valid endpoint which refers to the document itself:
+					doc.endpoint = href
+					if len(href) == 0 {
+						doc.endpoint = "."
+					}
+				}
+				if token.DataAtom == atom.A && len(href) > 0 {
+					doc.links = append(doc.links, href)
+				}
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().DataAtom == atom.Title {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle {
+				doc.title += string(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// endpointFromHeader returns the first webmention endpoint
+// advertised in a HTTP Link header.
+func endpointFromHeader(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			if len(parts) < 2 {
+				continue
+			}
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(key, "rel") && containsField(strings.Trim(value, `"`), "webmention") {
+					return strings.Trim(target, "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeURL removes the fragment of a URL,
+// so that deep links count as a link to the page.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,209 @@
+package webmention
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxSourceSize       = 1 << 20
+	queueSize           = 100
+	maxPendingPerSource = 10
+)
+
+var (
+	ErrInvalidMention  = errors.New("invalid webmention")
+	ErrTooManyMentions = errors.New("too many webmentions from the same source are awaiting verification")
+)
+
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("%w: '%s' is not an absolute http or https URL", ErrInvalidMention, rawURL)
+	}
+	return u, nil
+}
+
+// Validate checks the source and target parameters of an incoming webmention.
+func Validate(source string, target string) error {
+	if _, err := parseHTTPURL(source); err != nil {
+		return err
+	}
+	if _, err := parseHTTPURL(target); err != nil {
+		return err
+	}
+	if normalizeURL(source) == normalizeURL(target) {
+		return fmt.Errorf("%w: source and target must be different", ErrInvalidMention)
+	}
+	return nil
+}
+
+// Receiver queues incoming webmentions and verifies them asynchronously
+// by checking that the source document actually links to the target.
+type Receiver struct {
+	store     *Store
+	client    *http.Client
+	userAgent string
+	queue     chan *Mention
+}
+
+func NewReceiver(store *Store, client *http.Client, userAgent string) *Receiver {
+	return &Receiver{
+		store:     store,
+		client:    client,
+		userAgent: userAgent,
+		queue:     make(chan *Mention, queueSize),
+	}
+}
+
+// Accept stores a new webmention and queues it for verification.
+// A source can only have a limited number of pending webmentions,
+// because each of them has to be fetched.
+func (rc *Receiver) Accept(source string, target string, postID string) error {
+	err := Validate(source, target)
+	if err != nil {
+		return err
+	}
+
+	pending, err := rc.store.PendingFrom(source)
+	if err != nil {
+		return err
+	}
+	if len(pending) >= maxPendingPerSource &&
+		!slices.ContainsFunc(pending, func(m *Mention) bool { return m.Target == target }) {
+		return ErrTooManyMentions
+	}
+
+	m := &Mention{
+		Source:     source,
+		Target:     target,
+		PostID:     postID,
+		Status:     StatusPending,
+		ReceivedAt: time.Now().UTC(),
+	}
+	err = rc.store.Save(m)
+	if err != nil {
+		return err
+	}
+
+	// When the queue is full the mention remains pending
+	// and gets verified the next time the receiver starts.
+	select {
+	case rc.queue <- m:
+	default:
+	}
+	return nil
+}
+
+// Run verifies all pending webmentions and then keeps verifying
+// newly accepted ones until the context gets cancelled.
+func (rc *Receiver) Run(ctx context.Context, logger *slog.Logger) {
+	pending, err := rc.store.Pending()
+	if err != nil {
+		logger.Error("Failed to load pending webmentions.", "error", err)
+	}
+	for _, m := range pending {
+		rc.process(ctx, logger, m)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-rc.queue:
+			rc.process(ctx, logger, m)
+		}
+	}
+}
+
+func (rc *Receiver) process(ctx context.Context, logger *slog.Logger, m *Mention) {
+	err := rc.verify(ctx, m)
+	m.VerifiedAt = time.Now().UTC()
+	if err != nil {
+		m.Status = StatusRejected
+		m.Error = err.Error()
+	} else {
+		m.Status = StatusVerified
+		m.Error = ""
+	}
+
+	err = rc.store.Save(m)
+	if err != nil {
+		logger.Error("Failed to store verified webmention.",
+			"error", err,
+			"source", m.Source,
+			"target", m.Target)
+		return
+	}
+
+	logger.Info("Processed webmention.",
+		"source", m.Source,
+		"target", m.Target,
+		"status", m.Status,
+		"reason", m.Error)
+}
+
+func (rc *Receiver) verify(ctx context.Context, m *Mention) error {
+	sourceURL, err := parseHTTPURL(m.Source)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.Source, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", rc.userAgent)
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9, */*;q=0.1")
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching source: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusGone {
+		return errors.New("source has been deleted")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("source responded with status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize))
+	if err != nil {
+		return fmt.Errorf("error reading source: %w", err)
+	}
+
+	target := normalizeURL(m.Target)
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		if bytes.Contains(body, []byte(target)) {
+			return nil
+		}
+		return errors.New("source does not mention the target")
+	}
+
+	doc := parseDocument(bytes.NewReader(body))
+	for _, link := range doc.links {
+		resolved, err := sourceURL.Parse(link)
+		if err != nil {
+			continue
+		}
+		if normalizeURL(resolved.String()) == target {
+			m.Title = strings.TrimSpace(doc.title)
+			return nil
+		}
+	}
+	return errors.New("source does not link to the target")
+}
@@ -0,0 +1,240 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testTarget = "https://dusted.codes/hello-world"
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "webmentions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	return store
+}
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestReceiverVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		contentType string
+		body        string
+		status      Status
+		title       string
+		reason      string
+	}{
+		{
+			name:        "link to target",
+			statusCode:  http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			body:        `<html><head><title> A reply </title></head><body><a href="` + testTarget + `">post</a></body></html>`,
+			status:      StatusVerified,
+			title:       "A reply",
+		},
+		{
+			name:        "link to a section of the target",
+			statusCode:  http.StatusOK,
+			contentType: "text/html",
+			body:        `<a href="` + testTarget + `#comments">post</a>`,
+			status:      StatusVerified,
+		},
+		{
+			name:        "no link to target",
+			statusCode:  http.StatusOK,
+			contentType: "text/html",
+			body:        `<p>` + testTarget + `</p><a href="https://dusted.codes/about">about</a>`,
+			status:      StatusRejected,
+			reason:      "source does not link to the target",
+		},
+		{
+			name:        "plain text mentions target",
+			statusCode:  http.StatusOK,
+			contentType: "text/plain",
+			body:        "Read " + testTarget,
+			status:      StatusVerified,
+		},
+		{
+			name:        "plain text without target",
+			statusCode:  http.StatusOK,
+			contentType: "text/plain",
+			body:        "Nothing to see",
+			status:      StatusRejected,
+			reason:      "source does not mention the target",
+		},
+		{
+			name:       "deleted source",
+			statusCode: http.StatusGone,
+			status:     StatusRejected,
+			reason:     "source has been deleted",
+		},
+		{
+			name:       "failing source",
+			statusCode: http.StatusInternalServerError,
+			status:     StatusRejected,
+			reason:     "source responded with status code 500",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ua := r.Header.Get("User-Agent"); ua != "test-agent" {
+					t.Errorf("expected User-Agent 'test-agent', got '%s'", ua)
+				}
+				w.Header().Set("Content-Type", test.contentType)
+				w.WriteHeader(test.statusCode)
+				_, _ = fmt.Fprint(w, test.body)
+			}))
+			defer source.Close()
+
+			store := newTestStore(t)
+			rc := NewReceiver(store, source.Client(), "test-agent")
+			err := rc.Accept(source.URL+"/reply", testTarget, "hello-world")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revision := store.Revision(); revision != 0 {
+				t.Errorf("expected a pending webmention to keep the revision, got %d", revision)
+			}
+
+			m := <-rc.queue
+			rc.process(context.Background(), newTestLogger(), m)
+
+			if m.Status != test.status {
+				t.Errorf("expected status '%s', got '%s'", test.status, m.Status)
+			}
+			if m.Title != test.title {
+				t.Errorf("expected title '%s', got '%s'", test.title, m.Title)
+			}
+			if m.Error != test.reason {
+				t.Errorf("expected reason '%s', got '%s'", test.reason, m.Error)
+			}
+
+			verified, err := store.Verified("hello-world")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedVerified, expectedRevision := 0, uint64(0)
+			if test.status == StatusVerified {
+				expectedVerified, expectedRevision = 1, 1
+			}
+			if len(verified) != expectedVerified {
+				t.Errorf("expected %d verified webmentions, got %d", expectedVerified, len(verified))
+			}
+			if revision := store.Revision(); revision != expectedRevision {
+				t.Errorf("expected revision %d, got %d", expectedRevision, revision)
+			}
+		})
+	}
+}
+
+func TestReceiverRunVerifiesPendingMentions(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, `<a href="%s">post</a>`, testTarget)
+	}))
+	defer source.Close()
+
+	store := newTestStore(t)
+	err := store.Save(&Mention{
+		Source: source.URL + "/reply",
+		Target: testTarget,
+		PostID: "hello-world",
+		Status: StatusPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewReceiver(store, source.Client(), "test-agent").Run(ctx, newTestLogger())
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		verified, err := store.Verified("hello-world")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verified) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected the pending webmention to be verified")
+}
+
+func TestAcceptRejectsInvalidMentions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		target string
+	}{
+		{"relative source", "/reply", testTarget},
+		{"ftp source", "ftp://example.org/reply", testTarget},
+		{"missing target", "https://example.org/reply", ""},
+		{"same source and target", testTarget + "#reply", testTarget},
+	}
+
+	rc := NewReceiver(newTestStore(t), http.DefaultClient, "test-agent")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := rc.Accept(test.source, test.target, "hello-world")
+			if !errors.Is(err, ErrInvalidMention) {
+				t.Errorf("expected ErrInvalidMention, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAcceptLimitsPendingMentionsPerSource(t *testing.T) {
+	rc := NewReceiver(newTestStore(t), http.DefaultClient, "test-agent")
+	source := "https://example.org/reply"
+	for i := range maxPendingPerSource {
+		err := rc.Accept(source, fmt.Sprintf("%s-%d", testTarget, i), "hello-world")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := rc.Accept(source, testTarget, "hello-world")
+	if !errors.Is(err, ErrTooManyMentions) {
+		t.Errorf("expected ErrTooManyMentions, got %v", err)
+	}
+
+	// Sending a pending webmention again replaces it:
+	err = rc.Accept(source, testTarget+"-0", "hello-world")
+	if err != nil {
+		t.Errorf("expected a repeated webmention to be accepted, got %v", err)
+	}
+
+	err = rc.Accept("https://example.com/other-reply", testTarget, "hello-world")
+	if err != nil {
+		t.Errorf("expected a different source to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package webmention
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sender notifies other sites about links from our blog posts.
+type Sender struct {
+	store     *Store
+	client    *http.Client
+	userAgent string
+}
+
+func NewSender(store *Store, client *http.Client, userAgent string) *Sender {
+	return &Sender{
+		store:     store,
+		client:    client,
+		userAgent: userAgent,
+	}
+}
+
+// outboundLinks returns the distinct absolute links of a HTML
+// document which point to a different host than the source.
+func outboundLinks(source *url.URL, content string) []string {
+	seen := map[string]bool{}
+	links := []string{}
+	for _, link := range parseDocument(strings.NewReader(content)).links {
+		resolved, err := source.Parse(link)
+		if err != nil {
+			continue
+		}
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			continue
+		}
+		if strings.EqualFold(resolved.Host, source.Host) {
+			continue
+		}
+		target := normalizeURL(resolved.String())
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		links = append(links, target)
+	}
+	return links
+}
+
+// Send sends a webmention for every outbound link of a page which
+// hasn't been notified before. Failures to reach a target are logged
+// and retried the next time, whereas targets which don't support
+// webmentions get recorded so that they aren't checked again.
+func (s *Sender) Send(ctx context.Context, logger *slog.Logger, source string, content string) error {
+	sourceURL, err := parseHTTPURL(source)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range outboundLinks(sourceURL, content) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.store.Delivered(source, target) {
+			continue
+		}
+
+		delivery, err := s.deliver(ctx, source, target)
+		if err != nil {
+			logger.Warn("Failed to send webmention.",
+				"error", err,
+				"source", source,
+				"target", target)
+			continue
+		}
+
+		err = s.store.SaveDelivery(delivery)
+		if err != nil {
+			return err
+		}
+
+		if len(delivery.Endpoint) == 0 {
+			logger.Debug("Target does not support webmentions.",
+				"source", source,
+				"target", target)
+			continue
+		}
+
+		logger.Info("Sent webmention.",
+			"source", source,
+			"target", target,
+			"endpoint", delivery.Endpoint,
+			"statusCode", delivery.StatusCode)
+	}
+	return nil
+}
+
+func (s *Sender) deliver(ctx context.Context, source string, target string) (*Delivery, error) {
+	delivery := &Delivery{
+		Source: source,
+		Target: target,
+		SentAt: time.Now().UTC(),
+	}
+
+	endpoint, err := s.discoverEndpoint(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoint) == 0 {
+		delivery.Error = "target does not advertise a webmention endpoint"
+		return delivery, nil
+	}
+	delivery.Endpoint = endpoint
+
+	form := url.Values{}
+	form.Set("source", source)
+	form.Set("target", target)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webmention request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending webmention: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxSourceSize))
+
+	// Server errors are worth another attempt the next time:
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("endpoint responded with status code %d", resp.StatusCode)
+	}
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		delivery.Error = fmt.Sprintf("endpoint responded with status code %d", resp.StatusCode)
+	}
+	return delivery, nil
+}
+
+// discoverEndpoint looks for a webmention endpoint in the Link header
+// and then in the HTML of the target. The endpoint gets resolved
+// against the final URL after following redirects.
+func (s *Sender) discoverEndpoint(ctx context.Context, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating discovery request: %w", err)
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching target: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", nil
+	}
+
+	endpoint := endpointFromHeader(resp.Header.Values("Link"))
+	if len(endpoint) == 0 && strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		endpoint = parseDocument(io.LimitReader(resp.Body, maxSourceSize)).endpoint
+	}
+	if len(endpoint) == 0 {
+		return "", nil
+	}
+
+	resolved, err := resp.Request.URL.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("error resolving webmention endpoint '%s': %w", endpoint, err)
+	}
+	return resolved.String(), nil
+}
@@ -0,0 +1,124 @@
+package webmention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+const testSource = "https://dusted.codes/hello-world"
+
+func TestSenderSend(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]int{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /endpoint", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if source := r.PostForm.Get("source"); source != testSource {
+			t.Errorf("expected source '%s', got '%s'", testSource, source)
+		}
+		mu.Lock()
+		received[r.PostForm.Get("target")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("POST /failing", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("POST /rejecting", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	mux.HandleFunc("GET /header", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Link", `</endpoint>; rel="webmention"`)
+		w.Header().Set("Content-Type", "text/html")
+	})
+	mux.HandleFunc("GET /html", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><head><link rel="webmention" href="/endpoint"></head></html>`)
+	})
+	mux.HandleFunc("GET /redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/html", http.StatusFound)
+	})
+	mux.HandleFunc("GET /none", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, `<html><body>No webmentions here</body></html>`)
+	})
+	mux.HandleFunc("GET /broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Link", `</failing>; rel="webmention"`)
+	})
+	mux.HandleFunc("GET /bad-request", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Link", `</rejecting>; rel="webmention"`)
+	})
+	targets := httptest.NewServer(mux)
+	defer targets.Close()
+
+	content := fmt.Sprintf(`
+<p><a href="%[1]s/header">header</a> and again <a href="%[1]s/header#section">header</a></p>
+<p><a href="%[1]s/html">html</a> <a href="%[1]s/redirect">redirect</a></p>
+<p><a href="%[1]s/none">none</a> <a href="%[1]s/broken">broken</a> <a href="%[1]s/bad-request">bad</a></p>
+<p><a href="/about">relative</a> <a href="https://dusted.codes/blog">same host</a> <a href="mailto:a@b.c">mail</a></p>`,
+		targets.URL)
+
+	store := newTestStore(t)
+	sender := NewSender(store, targets.Client(), "test-agent")
+	for range 2 {
+		err := sender.Send(context.Background(), newTestLogger(), testSource, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]int{
+		targets.URL + "/header":   1,
+		targets.URL + "/html":     1,
+		targets.URL + "/redirect": 1,
+	}
+	if len(received) != len(expected) {
+		t.Errorf("expected webmentions for %v, got %v", expected, received)
+	}
+	for target, count := range expected {
+		if received[target] != count {
+			t.Errorf("expected %d webmentions for '%s', got %d", count, target, received[target])
+		}
+	}
+
+	delivered := map[string]bool{
+		"/header":      true,
+		"/html":        true,
+		"/redirect":    true,
+		"/none":        true,
+		"/bad-request": true,
+		// Server errors get retried the next time:
+		"/broken": false,
+	}
+	for path, expected := range delivered {
+		if actual := store.Delivered(testSource, targets.URL+path); actual != expected {
+			t.Errorf("expected delivered to be %t for '%s', got %t", expected, path, actual)
+		}
+	}
+}
+
+func TestSenderSendStopsWhenCancelled(t *testing.T) {
+	requests := 0
+	targets := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+	}))
+	defer targets.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sender := NewSender(newTestStore(t), targets.Client(), "test-agent")
+	err := sender.Send(ctx, newTestLogger(), testSource, fmt.Sprintf(`<a href="%s/a">a</a>`, targets.URL))
+	if err == nil {
+		t.Error("expected an error")
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+}
@@ -0,0 +1,226 @@
+package webmention
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultStorePath = "data/webmentions.db"
+
+	openTimeout = 3 * time.Second
+)
+
+var (
+	receivedBucket = []byte("received")
+	sentBucket     = []byte("sent")
+	metaBucket     = []byte("meta")
+	revisionKey    = []byte("revision")
+)
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusVerified Status = "verified"
+	StatusRejected Status = "rejected"
+)
+
+// Mention is a webmention which another site sent to one of our blog posts.
+type Mention struct {
+	Source     string
+	Target     string
+	PostID     string
+	Status     Status
+	Title      string
+	Error      string
+	ReceivedAt time.Time
+	VerifiedAt time.Time
+}
+
+func (m *Mention) key() []byte {
+	return []byte(m.Source + "\n" + m.Target)
+}
+
+// Delivery records a webmention which we sent to another site.
+type Delivery struct {
+	Source     string
+	Target     string
+	Endpoint   string
+	StatusCode int
+	Error      string
+	SentAt     time.Time
+}
+
+func deliveryKey(source string, target string) []byte {
+	return []byte(source + "\n" + target)
+}
+
+// Store persists received and sent webmentions in an embedded bbolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating directory for webmention store: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening webmention store '%s': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{receivedBucket, sentBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error initialising webmention store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func incrementRevision(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	revision := uint64(0)
+	if value := meta.Get(revisionKey); value != nil {
+		revision = binary.BigEndian.Uint64(value)
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, revision+1)
+	return meta.Put(revisionKey, value)
+}
+
+// Save inserts or replaces a received webmention.
+// A source which mentions the same target again replaces the
+// previous record, which re-triggers its verification.
+// Only verified webmentions are visible on the blog, so the
+// revision doesn't change for pending or rejected ones.
+func (s *Store) Save(m *Mention) error {
+	value, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error serialising webmention: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		received := tx.Bucket(receivedBucket)
+		wasVerified := false
+		if previous := received.Get(m.key()); previous != nil {
+			p := &Mention{}
+			if err := json.Unmarshal(previous, p); err != nil {
+				return fmt.Errorf("error deserialising webmention: %w", err)
+			}
+			wasVerified = p.Status == StatusVerified
+		}
+		if err := received.Put(m.key(), value); err != nil {
+			return err
+		}
+		if !wasVerified && m.Status != StatusVerified {
+			return nil
+		}
+		return incrementRevision(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("error storing webmention: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) filter(match func(m *Mention) bool) ([]*Mention, error) {
+	mentions := []*Mention{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(receivedBucket).ForEach(func(_, value []byte) error {
+			m := &Mention{}
+			if err := json.Unmarshal(value, m); err != nil {
+				return fmt.Errorf("error deserialising webmention: %w", err)
+			}
+			if match(m) {
+				mentions = append(mentions, m)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing webmentions: %w", err)
+	}
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].ReceivedAt.Before(mentions[j].ReceivedAt)
+	})
+	return mentions, nil
+}
+
+// Pending returns all webmentions which still need to be verified.
+func (s *Store) Pending() ([]*Mention, error) {
+	return s.filter(func(m *Mention) bool {
+		return m.Status == StatusPending
+	})
+}
+
+// PendingFrom returns the webmentions of a source which still need to be verified.
+func (s *Store) PendingFrom(source string) ([]*Mention, error) {
+	return s.filter(func(m *Mention) bool {
+		return m.Source == source && m.Status == StatusPending
+	})
+}
+
+// Verified returns the verified webmentions of a blog post.
+func (s *Store) Verified(postID string) ([]*Mention, error) {
+	return s.filter(func(m *Mention) bool {
+		return m.PostID == postID && m.Status == StatusVerified
+	})
+}
+
+// Revision changes every time a webmention gets stored
+// and can be used to invalidate cached pages.
+func (s *Store) Revision() uint64 {
+	revision := uint64(0)
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(metaBucket).Get(revisionKey); value != nil {
+			revision = binary.BigEndian.Uint64(value)
+		}
+		return nil
+	})
+	return revision
+}
+
+// Delivered reports whether a webmention from source to target has been sent before.
+func (s *Store) Delivered(source string, target string) bool {
+	found := false
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(sentBucket).Get(deliveryKey(source, target)) != nil
+		return nil
+	})
+	return found
+}
+
+func (s *Store) SaveDelivery(d *Delivery) error {
+	value, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("error serialising webmention delivery: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sentBucket).Put(deliveryKey(d.Source, d.Target), value)
+	})
+	if err != nil {
+		return fmt.Errorf("error storing webmention delivery: %w", err)
+	}
+	return nil
+}
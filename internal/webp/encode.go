@@ -0,0 +1,462 @@
+// Package webp encodes lossless WebP images (VP8L) in pure Go.
+//
+// The encoder applies the subtract green and predictor transforms,
+// compresses the pixels with LZ77 backward references and a colour
+// cache and codes them with one set of Huffman codes. It doesn't search
+// for the best possible compression like libwebp, but screenshots and
+// diagrams end up considerably smaller than PNGs.
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	maxDimension = 1 << 14
+
+	predictorBits  = 4
+	colorCacheBits = 10
+
+	numLiteralCodes  = 256
+	numLengthCodes   = 24
+	numDistanceCodes = 40
+
+	colorCacheMultiplier = 0x1e35a7bd
+
+	minMatchLength = 3
+	maxMatchLength = 4096
+	maxDistance    = 1<<20 - 120
+	hashBits       = 16
+	maxChainLength = 64
+)
+
+const (
+	transformPredictor     = 0
+	transformSubtractGreen = 2
+)
+
+// Encode writes the image as a lossless WebP.
+func Encode(w io.Writer, m image.Image) error {
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxDimension || height > maxDimension {
+		return errors.New("webp: invalid image size")
+	}
+
+	argb := make([]uint32, width*height)
+	opaque := true
+	for y := range height {
+		for x := range width {
+			c := color.NRGBAModel.Convert(m.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			argb[y*width+x] = uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint64(width-1), 14)
+	bw.write(uint64(height-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3)
+
+	bw.write(1, 1)
+	bw.write(transformSubtractGreen, 2)
+	subtractGreen(argb)
+
+	bw.write(1, 1)
+	bw.write(transformPredictor, 2)
+	bw.write(predictorBits-2, 3)
+	modes, tilesX, tilesY := predict(argb, width, height)
+	writePixels(bw, modes, tilesX, tilesY, 0, false)
+
+	bw.write(0, 1)
+	writePixels(bw, argb, width, height, colorCacheBits, true)
+	data := bw.bytes()
+
+	// The RIFF container has a single VP8L chunk, chunks have an even size:
+	size := len(data) + len(data)%2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+size))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+func (w *bitWriter) write(value uint64, n uint) {
+	w.bits |= value << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+	return w.buf
+}
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := p >> 8 & 0xff
+		r := (p>>16 - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+// predict replaces the pixels with the residuals of the predictor which
+// works best for each tile and returns the predictors as an image. The
+// first pixel is predicted as opaque black, the rest of the first row
+// from the left and the first column from the top.
+func predict(argb []uint32, width, height int) ([]uint32, int, int) {
+	tilesX := (width + 1<<predictorBits - 1) >> predictorBits
+	tilesY := (height + 1<<predictorBits - 1) >> predictorBits
+	modes := make([]uint32, tilesX*tilesY)
+
+	residuals := make([]uint32, len(argb))
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			switch {
+			case y == 0 && x == 0:
+				residuals[i] = sub(argb[i], 0xff000000)
+			case y == 0:
+				residuals[i] = sub(argb[i], argb[i-1])
+			case x == 0:
+				residuals[i] = sub(argb[i], argb[i-width])
+			}
+		}
+	}
+
+	for ty := range tilesY {
+		for tx := range tilesX {
+			best, bestCost := 0, -1
+			for mode := range 14 {
+				cost := 0
+				for y := max(ty<<predictorBits, 1); y < min((ty+1)<<predictorBits, height); y++ {
+					for x := max(tx<<predictorBits, 1); x < min((tx+1)<<predictorBits, width); x++ {
+						i := y*width + x
+						cost += residualCost(sub(argb[i], predictor(mode, argb, i, width)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			// The mode is stored in the green channel:
+			modes[ty*tilesX+tx] = 0xff000000 | uint32(best)<<8
+			for y := max(ty<<predictorBits, 1); y < min((ty+1)<<predictorBits, height); y++ {
+				for x := max(tx<<predictorBits, 1); x < min((tx+1)<<predictorBits, width); x++ {
+					i := y*width + x
+					residuals[i] = sub(argb[i], predictor(best, argb, i, width))
+				}
+			}
+		}
+	}
+	copy(argb, residuals)
+	return modes, tilesX, tilesY
+}
+
+func residualCost(residual uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(int8(residual >> shift))
+		cost += max(v, -v)
+	}
+	return cost
+}
+
+// predictor returns the prediction of a pixel which is neither in the first
+// row nor the first column. The top right pixel of the last column is the
+// first pixel of the current row, just like in the decoder.
+func predictor(mode int, argb []uint32, i, width int) uint32 {
+	l, t, tl, tr := argb[i-1], argb[i-width], argb[i-width-1], argb[i-width+1]
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return average(average(l, tr), t)
+	case 6:
+		return average(l, tl)
+	case 7:
+		return average(l, t)
+	case 8:
+		return average(tl, t)
+	case 9:
+		return average(t, tr)
+	case 10:
+		return average(average(l, tl), average(t, tr))
+	case 11:
+		return selectPredictor(l, t, tl)
+	case 12:
+		return channels(func(s uint) uint32 {
+			return clamp(int(l>>s&0xff) + int(t>>s&0xff) - int(tl>>s&0xff))
+		})
+	default:
+		a := average(l, t)
+		return channels(func(s uint) uint32 {
+			x := int(a >> s & 0xff)
+			return clamp(x + (x-int(tl>>s&0xff))/2)
+		})
+	}
+}
+
+func channels(f func(shift uint) uint32) uint32 {
+	return f(24)<<24 | f(16)<<16 | f(8)<<8 | f(0)
+}
+
+func average(a, b uint32) uint32 {
+	return channels(func(s uint) uint32 {
+		return (a>>s&0xff + b>>s&0xff) / 2
+	})
+}
+
+func clamp(x int) uint32 {
+	return uint32(min(max(x, 0), 255))
+}
+
+func selectPredictor(l, t, tl uint32) uint32 {
+	distance := func(a, b uint32) int {
+		d := 0
+		for s := uint(0); s < 32; s += 8 {
+			v := int(a>>s&0xff) - int(b>>s&0xff)
+			d += max(v, -v)
+		}
+		return d
+	}
+	if distance(tl, t) < distance(tl, l) {
+		return l
+	}
+	return t
+}
+
+// sub subtracts each channel modulo 256.
+func sub(a, b uint32) uint32 {
+	return channels(func(s uint) uint32 {
+		return (a>>s - b>>s) & 0xff
+	})
+}
+
+// symbol is a literal pixel, a colour cache index or a backward reference.
+type symbol struct {
+	kind     int
+	argb     uint32
+	index    int
+	length   int
+	distance int
+}
+
+const (
+	literal = iota
+	cacheHit
+	backwardReference
+)
+
+// writePixels compresses an image and writes its Huffman codes and data.
+// Only the main image has the bit for the meta Huffman codes.
+func writePixels(w *bitWriter, argb []uint32, width, height int, cacheBits int, main bool) {
+	symbols := compress(argb, width, cacheBits)
+
+	if cacheBits > 0 {
+		w.write(1, 1)
+		w.write(uint64(cacheBits), 4)
+	} else {
+		w.write(0, 1)
+	}
+	if main {
+		w.write(0, 1)
+	}
+
+	cacheSize := 0
+	if cacheBits > 0 {
+		cacheSize = 1 << cacheBits
+	}
+	green := make([]int, numLiteralCodes+numLengthCodes+cacheSize)
+	red := make([]int, numLiteralCodes)
+	blue := make([]int, numLiteralCodes)
+	alpha := make([]int, numLiteralCodes)
+	distance := make([]int, numDistanceCodes)
+	for _, s := range symbols {
+		switch s.kind {
+		case literal:
+			green[s.argb>>8&0xff]++
+			red[s.argb>>16&0xff]++
+			blue[s.argb&0xff]++
+			alpha[s.argb>>24]++
+		case cacheHit:
+			green[numLiteralCodes+numLengthCodes+s.index]++
+		case backwardReference:
+			code, _, _ := prefixCode(s.length)
+			green[numLiteralCodes+code]++
+			code, _, _ = prefixCode(distanceCode(s.distance, width))
+			distance[code]++
+		}
+	}
+	codes := [5]*huffmanCode{}
+	for i, counts := range [][]int{green, red, blue, alpha, distance} {
+		codes[i] = newHuffmanCode(counts, maxCodeLength)
+		writeHuffmanCode(w, codes[i])
+	}
+
+	for _, s := range symbols {
+		switch s.kind {
+		case literal:
+			codes[0].write(w, int(s.argb>>8&0xff))
+			codes[1].write(w, int(s.argb>>16&0xff))
+			codes[2].write(w, int(s.argb&0xff))
+			codes[3].write(w, int(s.argb>>24))
+		case cacheHit:
+			codes[0].write(w, numLiteralCodes+numLengthCodes+s.index)
+		case backwardReference:
+			code, n, extra := prefixCode(s.length)
+			codes[0].write(w, numLiteralCodes+code)
+			w.write(uint64(extra), n)
+			code, n, extra = prefixCode(distanceCode(s.distance, width))
+			codes[4].write(w, code)
+			w.write(uint64(extra), n)
+		}
+	}
+}
+
+// compress finds backward references with hash chains and looks up the
+// remaining pixels in the colour cache, which holds all previous pixels.
+func compress(argb []uint32, width int, cacheBits int) []symbol {
+	hash := func(i int) int {
+		return int((argb[i]*colorCacheMultiplier ^ argb[i+1]*0x9e3779b1) >> (32 - hashBits))
+	}
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, len(argb))
+	insert := func(i int) {
+		if i+1 < len(argb) {
+			h := hash(i)
+			chain[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+
+	var cache []uint32
+	if cacheBits > 0 {
+		cache = make([]uint32, 1<<cacheBits)
+	}
+	cacheIndex := func(p uint32) int {
+		return int((p * colorCacheMultiplier) >> (32 - cacheBits))
+	}
+
+	symbols := []symbol{}
+	for i := 0; i < len(argb); {
+		length, distance := 0, 0
+		if i+minMatchLength <= len(argb) {
+			// The pixels to the left and above are the cheapest to refer to:
+			for _, d := range []int{1, width} {
+				if d <= i {
+					if n := matchLength(argb, i-d, i); n > length {
+						length, distance = n, d
+					}
+				}
+			}
+			for j, steps := head[hash(i)], 0; j >= 0 && steps < maxChainLength; steps++ {
+				if i-int(j) > maxDistance {
+					break
+				}
+				if n := matchLength(argb, int(j), i); n > length {
+					length, distance = n, i-int(j)
+				}
+				j = chain[j]
+			}
+		}
+
+		n := 1
+		if length >= minMatchLength {
+			symbols = append(symbols, symbol{kind: backwardReference, length: length, distance: distance})
+			n = length
+		} else if cache != nil && cache[cacheIndex(argb[i])] == argb[i] {
+			symbols = append(symbols, symbol{kind: cacheHit, index: cacheIndex(argb[i])})
+		} else {
+			symbols = append(symbols, symbol{kind: literal, argb: argb[i]})
+		}
+		for range n {
+			if cache != nil {
+				cache[cacheIndex(argb[i])] = argb[i]
+			}
+			insert(i)
+			i++
+		}
+	}
+	return symbols
+}
+
+func matchLength(argb []uint32, from, to int) int {
+	n := 0
+	for to+n < len(argb) && n < maxMatchLength && argb[from+n] == argb[to+n] {
+		n++
+	}
+	return n
+}
+
+// distanceCode maps a distance to the short codes for the pixel to the
+// left and the pixel above or to the distance plus 120.
+func distanceCode(distance, width int) int {
+	switch distance {
+	case 1:
+		return 2
+	case width:
+		return 1
+	default:
+		return distance + 120
+	}
+}
+
+// prefixCode returns the prefix code of a length or distance code
+// and the number and value of its extra bits.
+func prefixCode(value int) (int, uint, int) {
+	if value <= 4 {
+		return value - 1, 0, 0
+	}
+	v := value - 1
+	highest := 0
+	for v>>(highest+1) > 0 {
+		highest++
+	}
+	second := v >> (highest - 1) & 1
+	extraBits := uint(highest - 1)
+	return 2*highest + second, extraBits, v & (1<<extraBits - 1)
+}
@@ -0,0 +1,121 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func newImage(width, height int, pixel func(x, y int) color.NRGBA) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			m.SetNRGBA(x, y, pixel(x, y))
+		}
+	}
+	return m
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	tests := []struct {
+		name  string
+		image image.Image
+	}{
+		{
+			name:  "zero",
+			image: image.NewNRGBA(image.Rect(0, 0, 64, 64)),
+		},
+		{
+			name: "gradient",
+			image: newImage(256, 128, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x), G: uint8(y * 2), B: uint8(x + y), A: 0xff}
+			}),
+		},
+		{
+			name: "transparent gradient",
+			image: newImage(128, 128, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 2), G: 0x80, B: uint8(y * 2), A: uint8(x + y)}
+			}),
+		},
+		{
+			name: "random noise",
+			image: newImage(100, 80, func(int, int) color.NRGBA {
+				v := random.Uint32()
+				return color.NRGBA{R: uint8(v), G: uint8(v >> 8), B: uint8(v >> 16), A: uint8(v >> 24)}
+			}),
+		},
+		{
+			name: "repeating stripes",
+			image: newImage(300, 200, func(x, y int) color.NRGBA {
+				if (x/7+y/5)%3 == 0 {
+					return color.NRGBA{R: 0x1d, G: 0x1d, B: 0x1d, A: 0xff}
+				}
+				return color.NRGBA{R: 0xfa, G: 0xb3, B: 0x87, A: 0xff}
+			}),
+		},
+		{
+			name: "odd size",
+			image: newImage(37, 19, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 0xff}
+			}),
+		},
+		{
+			name: "1x1",
+			image: newImage(1, 1, func(int, int) color.NRGBA {
+				return color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0x78}
+			}),
+		},
+		{
+			name: "sub image",
+			image: newImage(50, 50, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 5), G: uint8(y * 5), B: 0x40, A: 0xff}
+			}).SubImage(image.Rect(10, 20, 45, 33)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Encode(&buf, test.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("expected a valid WebP, got %v", err)
+			}
+
+			bounds := test.image.Bounds()
+			if decoded.Bounds().Dx() != bounds.Dx() || decoded.Bounds().Dy() != bounds.Dy() {
+				t.Fatalf("expected size %dx%d, got %dx%d",
+					bounds.Dx(), bounds.Dy(), decoded.Bounds().Dx(), decoded.Bounds().Dy())
+			}
+			for y := range bounds.Dy() {
+				for x := range bounds.Dx() {
+					expected := color.NRGBAModel.Convert(test.image.At(bounds.Min.X+x, bounds.Min.Y+y))
+					actual := color.NRGBAModel.Convert(decoded.At(decoded.Bounds().Min.X+x, decoded.Bounds().Min.Y+y))
+					if expected != actual {
+						t.Fatalf("expected pixel %v at %d,%d, got %v", expected, x, y, actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeInvalidSize(t *testing.T) {
+	for _, rect := range []image.Rectangle{
+		image.Rect(0, 0, 0, 10),
+		image.Rect(0, 0, maxDimension+1, 1),
+	} {
+		err := Encode(&bytes.Buffer{}, image.NewNRGBA(rect))
+		if err == nil {
+			t.Errorf("expected an error for size %v", rect.Size())
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package webp
+
+import (
+	"slices"
+)
+
+// maxCodeLength is the longest Huffman code which VP8L permits,
+// the codes of the code lengths are limited to 7 bits.
+const (
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+)
+
+// codeLengthCodeOrder is the order in which the lengths of the
+// code length codes are written.
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// huffmanCode is a canonical Huffman code. A code with a single
+// symbol takes no bits at all.
+type huffmanCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool
+}
+
+func (c *huffmanCode) write(w *bitWriter, symbol int) {
+	if c.single {
+		return
+	}
+	w.write(uint64(c.codes[symbol]), uint(c.lengths[symbol]))
+}
+
+// newHuffmanCode builds a length limited Huffman code from the
+// frequencies of the symbols.
+func newHuffmanCode(counts []int, limit int) *huffmanCode {
+	c := &huffmanCode{
+		lengths: codeLengths(counts, limit),
+		codes:   make([]uint16, len(counts)),
+	}
+	used := 0
+	for _, l := range c.lengths {
+		if l > 0 {
+			used++
+		}
+	}
+	c.single = used <= 1
+
+	// Codes are assigned in the order of their lengths and symbols and get
+	// written starting with the most significant bit, which means reversed
+	// in the least significant bit first stream:
+	var counted [maxCodeLength + 1]int
+	for _, l := range c.lengths {
+		counted[l]++
+	}
+	counted[0] = 0
+	var next [maxCodeLength + 1]int
+	code := 0
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + counted[l-1]) << 1
+		next[l] = code
+	}
+	for symbol, l := range c.lengths {
+		if l == 0 {
+			continue
+		}
+		c.codes[symbol] = reverse(uint16(next[l]), int(l))
+		next[l]++
+	}
+	return c
+}
+
+func reverse(code uint16, length int) uint16 {
+	reversed := uint16(0)
+	for range length {
+		reversed = reversed<<1 | code&1
+		code >>= 1
+	}
+	return reversed
+}
+
+// codeLengths returns the lengths of a Huffman code whose longest code
+// doesn't exceed the limit. A single symbol gets the length 1, which
+// marks it as used. Codes which get too long are flattened by raising
+// the lowest counts until the tree is shallow enough.
+func codeLengths(counts []int, limit int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	symbols := []int{}
+	for symbol, count := range counts {
+		if count > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	type node struct {
+		weight int
+		parent int
+	}
+	for minCount := 1; ; minCount *= 2 {
+		nodes := make([]node, 0, 2*len(symbols)-1)
+		for _, symbol := range symbols {
+			nodes = append(nodes, node{weight: max(counts[symbol], minCount), parent: -1})
+		}
+		leaves := make([]int, len(symbols))
+		for i := range leaves {
+			leaves[i] = i
+		}
+		slices.SortStableFunc(leaves, func(a, b int) int {
+			return nodes[a].weight - nodes[b].weight
+		})
+
+		// Two queues build the tree in linear time after sorting, the
+		// internal nodes get created in the order of their weights:
+		internal := []int{}
+		pop := func() int {
+			if len(internal) == 0 || len(leaves) > 0 && nodes[leaves[0]].weight <= nodes[internal[0]].weight {
+				n := leaves[0]
+				leaves = leaves[1:]
+				return n
+			}
+			n := internal[0]
+			internal = internal[1:]
+			return n
+		}
+		for len(leaves)+len(internal) > 1 {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, parent: -1})
+			nodes[a].parent = len(nodes) - 1
+			nodes[b].parent = len(nodes) - 1
+			internal = append(internal, len(nodes)-1)
+		}
+
+		// Parents come after their children, so the depths can be
+		// computed from the root downwards:
+		depths := make([]int, len(nodes))
+		longest := 0
+		for i := len(nodes) - 2; i >= 0; i-- {
+			depths[i] = depths[nodes[i].parent] + 1
+			if i < len(symbols) {
+				longest = max(longest, depths[i])
+			}
+		}
+		if longest > limit {
+			continue
+		}
+		for i, symbol := range symbols {
+			lengths[symbol] = uint8(depths[i])
+		}
+		return lengths
+	}
+}
+
+// writeHuffmanCode writes a code in the simple form for one or two
+// symbols below 256 and with the lengths of all symbols otherwise.
+func writeHuffmanCode(w *bitWriter, c *huffmanCode) {
+	used := []int{}
+	for symbol, l := range c.lengths {
+		if l > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = append(used, 0)
+	}
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		w.write(1, 1)
+		w.write(uint64(len(used)-1), 1)
+		if used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint64(used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint64(used[0]), 8)
+		}
+		if len(used) == 2 {
+			w.write(uint64(used[1]), 8)
+		}
+		return
+	}
+
+	// The lengths are run length encoded: 16 repeats the previous length
+	// 3 to 6 times, 17 repeats zeros 3 to 10 times and 18 11 to 138 times.
+	type token struct {
+		symbol int
+		extra  int
+	}
+	tokens := []token{}
+	for i := 0; i < len(c.lengths); {
+		l := c.lengths[i]
+		run := 1
+		for i+run < len(c.lengths) && c.lengths[i+run] == l {
+			run++
+		}
+		i += run
+		if l == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, token{18, n - 11})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, token{17, run - 3})
+				run = 0
+			}
+			for ; run > 0; run-- {
+				tokens = append(tokens, token{0, 0})
+			}
+			continue
+		}
+		tokens = append(tokens, token{int(l), 0})
+		run--
+		for run >= 3 {
+			n := min(run, 6)
+			tokens = append(tokens, token{16, n - 3})
+			run -= n
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, token{int(l), 0})
+		}
+	}
+
+	counts := make([]int, len(codeLengthCodeOrder))
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	lengthCode := newHuffmanCode(counts, maxCodeLengthCodeLength)
+	n := 4
+	for i, symbol := range codeLengthCodeOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			n = max(n, i+1)
+		}
+	}
+	w.write(0, 1)
+	w.write(uint64(n-4), 4)
+	for _, symbol := range codeLengthCodeOrder[:n] {
+		w.write(uint64(lengthCode.lengths[symbol]), 3)
+	}
+	// All lengths get written, there is no shorter maximum symbol:
+	w.write(0, 1)
+	for _, t := range tokens {
+		lengthCode.write(w, t.symbol)
+		switch t.symbol {
+		case 16:
+			w.write(uint64(t.extra), 2)
+		case 17:
+			w.write(uint64(t.extra), 3)
+		case 18:
+			w.write(uint64(t.extra), 7)
+		}
+	}
+}